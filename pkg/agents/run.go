@@ -13,6 +13,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/schema"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
@@ -157,7 +158,12 @@ func (a *Agents) populateRequest(ctx context.Context, run *run, previousRun *run
 
 const previousRunKey = "previous_run"
 
-func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts ...types.CompletionOptions) (*types.CompletionResponse, error) {
+func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts ...types.CompletionOptions) (_ *types.CompletionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "agent "+req.Model, "nanobot.agent", req.Model)
+	defer func() {
+		span.End(err)
+	}()
+
 	var (
 		previousRunKey = previousRunKey + "/" + req.Model
 		session        = mcp.SessionFromContext(ctx)
@@ -180,12 +186,8 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		previousRun, _ = session.Get(previousRunKey).(*run)
 	}
 
-	for {
-		if err := a.run(ctx, currentRun, previousRun, opts); err != nil {
-			return nil, err
-		}
-
-		if err := a.toolCalls(ctx, currentRun, opts); err != nil {
+	for i := 0; ; i++ {
+		if err := a.turn(ctx, i, currentRun, previousRun, opts); err != nil {
 			return nil, err
 		}
 
@@ -204,6 +206,20 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 	}
 }
 
+func (a *Agents) turn(ctx context.Context, iteration int, run *run, prev *run, opts []types.CompletionOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "agent turn", "nanobot.agent", run.Request.Model, "nanobot.agent.iteration", iteration)
+	defer func() {
+		span.SetAttributes("nanobot.agent.tool_calls", len(run.ToolOutputs))
+		span.End(err)
+	}()
+
+	if err := a.run(ctx, run, prev, opts); err != nil {
+		return err
+	}
+
+	return a.toolCalls(ctx, run, opts)
+}
+
 func (a *Agents) run(ctx context.Context, run *run, prev *run, opts []types.CompletionOptions) error {
 	completionRequest, toolMapping, err := a.populateRequest(ctx, run, prev)
 	if err != nil {
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func record(sessionID, parentID, method string) mcp.Record {
+	msg, _ := json.Marshal(mcp.Message{JSONRPC: "2.0", Method: method})
+	return mcp.Record{
+		SessionID:       sessionID,
+		ParentSessionID: parentID,
+		Direction:       mcp.DirectionIn,
+		Message:         msg,
+	}
+}
+
+func TestRotateAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewFileRecorder(dir, Options{MaxSize: 200, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		r.Record(context.Background(), record("session", "", fmt.Sprintf("method/%d", i)))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files to be kept, got %d", len(files))
+	}
+
+	var methods []string
+	err = Read(dir, Filter{}, func(_ mcp.Record, msg mcp.Message) error {
+		methods = append(methods, msg.Method)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) == 0 || methods[len(methods)-1] != "method/9" || !slices.IsSorted(methods) {
+		t.Errorf("expected the newest records in order, got %v", methods)
+	}
+	if slices.Contains(methods, "method/0") {
+		t.Errorf("expected the oldest records to be pruned, got %v", methods)
+	}
+}
+
+func TestFilterSession(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewFileRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []mcp.Record{
+		record("a", "", "initialize"),
+		record("ab", "", "initialize"),
+		record("b", "a", "tools/list"),
+		record("c", "b", "tools/call"),
+		record("d", "ab", "tools/call"),
+		record("a", "", "tools/call"),
+	} {
+		r.Record(context.Background(), rec)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessions []string
+	err = Read(dir, Filter{SessionID: "a", Method: "tools/"}, func(record mcp.Record, _ mcp.Message) error {
+		sessions = append(sessions, record.SessionID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c", "a"}; !slices.Equal(sessions, want) {
+		t.Errorf("expected sessions %v, got %v", want, sessions)
+	}
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+type Filter struct {
+	// SessionID matches the session and all of its descendant sessions
+	SessionID string
+	Server    string
+	Method    string
+	Direction string
+	Since     time.Time
+	Until     time.Time
+
+	// sessions are SessionID and the descendants of it seen so far
+	sessions map[string]bool
+}
+
+// Matches returns whether the record matches the filter. Records must be passed in the order they
+// were recorded, the descendants of SessionID are found through the parent of each record.
+func (f *Filter) Matches(record mcp.Record, msg mcp.Message) bool {
+	if f.SessionID != "" {
+		if f.sessions == nil {
+			f.sessions = map[string]bool{f.SessionID: true}
+		}
+		if !f.sessions[record.SessionID] {
+			if record.ParentSessionID == "" || !f.sessions[record.ParentSessionID] {
+				return false
+			}
+			f.sessions[record.SessionID] = true
+		}
+	}
+	if f.Server != "" && record.Server != f.Server {
+		return false
+	}
+	if f.Method != "" && !strings.HasPrefix(msg.Method, f.Method) {
+		return false
+	}
+	if f.Direction != "" && record.Direction != f.Direction {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read calls fn for every record in the audit files at path that matches the filter. Path can be
+// a directory of audit files or a single file.
+func Read(path string, filter Filter, fn func(mcp.Record, mcp.Message) error) error {
+	files, err := Files(path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := readFile(file, &filter, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(file string, filter *Filter, fn func(mcp.Record, mcp.Message) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lines := bufio.NewScanner(f)
+	lines.Buffer(make([]byte, 0, 1024), 10*1024*1024)
+	for i := 1; lines.Scan(); i++ {
+		var (
+			record mcp.Record
+			msg    mcp.Message
+		)
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil {
+			return fmt.Errorf("failed to parse %s line %d: %w", file, i, err)
+		}
+		if len(record.Message) > 0 {
+			if err := json.Unmarshal(record.Message, &msg); err != nil {
+				return fmt.Errorf("failed to parse message in %s line %d: %w", file, i, err)
+			}
+		}
+		if !filter.Matches(record, msg) {
+			continue
+		}
+		if err := fn(record, msg); err != nil {
+			return err
+		}
+	}
+
+	return lines.Err()
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const (
+	filePrefix = "audit-"
+	fileSuffix = ".jsonl"
+)
+
+var _ mcp.Recorder = (*FileRecorder)(nil)
+
+// FileRecorder writes every recorded message as a line of JSON to a set of rotating files in a directory.
+type FileRecorder struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+type Options struct {
+	// MaxSize is the size in bytes at which the current file is rotated.
+	MaxSize int64
+	// MaxFiles is the number of files to keep in the directory, zero means keep everything.
+	MaxFiles int
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.MaxSize = complete.Last(o.MaxSize, other.MaxSize)
+	result.MaxFiles = complete.Last(o.MaxFiles, other.MaxFiles)
+	return
+}
+
+func (o Options) Complete() Options {
+	if o.MaxSize == 0 {
+		o.MaxSize = 10 * 1024 * 1024
+	}
+	return o
+}
+
+func NewFileRecorder(dir string, opts ...Options) (*FileRecorder, error) {
+	opt := complete.Complete(opts...)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory %s: %w", dir, err)
+	}
+	return &FileRecorder{
+		dir:      dir,
+		maxSize:  opt.MaxSize,
+		maxFiles: opt.MaxFiles,
+	}, nil
+}
+
+func (f *FileRecorder) Record(ctx context.Context, record mcp.Record) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf(ctx, "failed to marshal audit record: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := f.rotate(int64(len(data))); err != nil {
+		log.Errorf(ctx, "failed to rotate audit file: %v", err)
+		return
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		log.Errorf(ctx, "failed to write audit record: %v", err)
+	}
+}
+
+func (f *FileRecorder) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *FileRecorder) rotate(next int64) error {
+	if f.file != nil && f.size+next <= f.maxSize {
+		return nil
+	}
+
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+
+	name := filepath.Join(f.dir, filePrefix+time.Now().UTC().Format("20060102T150405.000000000")+fileSuffix)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	f.file = file
+	f.size = 0
+
+	return f.prune()
+}
+
+func (f *FileRecorder) prune() error {
+	if f.maxFiles <= 0 {
+		return nil
+	}
+
+	files, err := Files(f.dir)
+	if err != nil {
+		return err
+	}
+
+	for len(files) > f.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Files returns the audit files in the directory sorted from oldest to newest. If path is a file,
+// only that file is returned.
+func Files(path string) ([]string, error) {
+	s, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !s.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), filePrefix) && strings.HasSuffix(entry.Name(), fileSuffix) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	// The file names contain a sortable timestamp
+	slices.Sort(files)
+	return files, nil
+}
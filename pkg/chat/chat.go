@@ -17,22 +17,27 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/printer"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"golang.org/x/term"
 )
 
+// Chat connects to the nanobot listening on the address and chats with it. When env variables are
+// missing or invalid and stdin is a terminal, the user is asked for them, they are passed to setEnv
+// along with whether to save them to envFile, and the chat connects again.
 func Chat(ctx context.Context, listenAddress string, confirmations *confirm.Service,
-	autoConfirm bool, prompt, output string, reload func(*mcp.Client) error) error {
+	autoConfirm bool, prompt, output string, reload func(*mcp.Client) error,
+	envFile string, setEnv func(values map[string]string, save bool) error) error {
 	progressToken := uuid.String()
 
 	promptDone, promptDoneCancel := context.WithCancel(ctx)
 	defer promptDoneCancel()
 
-	c, err := mcp.NewClient(ctx, "nanobot", mcp.Server{
-		BaseURL: "http://" + listenAddress,
-		Headers: nil,
-	}, mcp.ClientOption{
+	clientOpt := mcp.ClientOption{
 		OnLogging: func(ctx context.Context, logMsg mcp.LoggingMessage) error {
 			return handleLog(logMsg, confirmations, autoConfirm)
 		},
+		OnElicit: func(ctx context.Context, elicit mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			return handleElicit(ctx, os.Stdin, os.Stderr, elicit, autoConfirm)
+		},
 		OnNotify: func(ctx context.Context, msg mcp.Message) error {
 			if llm.PrintProgress(msg.Params) {
 				return nil
@@ -40,9 +45,34 @@ func Chat(ctx context.Context, listenAddress string, confirmations *confirm.Serv
 			printToolCall(msg.Params, promptDoneCancel)
 			return nil
 		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create chat client: %w", err)
+	}
+
+	var (
+		c   *mcp.Client
+		err error
+	)
+	for {
+		c, err = mcp.NewClient(ctx, "nanobot", mcp.Server{
+			BaseURL: "http://" + listenAddress,
+			Headers: nil,
+		}, clientOpt)
+		if err == nil {
+			break
+		}
+		problems := envProblems(err)
+		if len(problems) == 0 || setEnv == nil || !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("failed to create chat client: %w", err)
+		}
+		if c != nil {
+			c.Session.Close()
+		}
+		values, save, err := askEnv(problems, envFile)
+		if err != nil {
+			return err
+		}
+		if err := setEnv(values, save); err != nil {
+			return err
+		}
 	}
 
 	if prompt != "" {
@@ -135,6 +165,10 @@ func handleConfirm(data map[string]any, confirmations *confirm.Service, autoConf
 	invocation, _ := request["invocation"].(map[string]any)
 	args, _ := invocation["arguments"].(string)
 
+	if message, _ := request["message"].(string); message != "" {
+		return handleApprove(id, message, confirmations)
+	}
+
 	if _, ok := always[mcpServer+"/"+toolName]; ok {
 		confirmations.Reply(id, true)
 		return nil
@@ -180,6 +214,25 @@ func handleConfirm(data map[string]any, confirmations *confirm.Service, autoConf
 	}
 }
 
+func handleApprove(id, message string, confirmations *confirm.Service) error {
+	_, _ = fmt.Fprintf(os.Stderr, "! %s\n", message)
+	for {
+		_, _ = fmt.Fprintf(os.Stderr, "!  (y/n) ? ")
+		line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		switch strings.TrimSpace(strings.ToLower(string(line))) {
+		case "y", "yes":
+			confirmations.Reply(id, true)
+			return nil
+		case "n", "no":
+			confirmations.Reply(id, false)
+			return nil
+		}
+	}
+}
+
 func printToolCall(params json.RawMessage, seenAgentOut func()) {
 	var toolCall struct {
 		Data struct {
@@ -0,0 +1,117 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+type elicitSchema struct {
+	Properties map[string]struct {
+		Type        string `json:"type"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Enum        []any  `json:"enum"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+// handleElicit prompts for the answer on out and reads it from stdin.
+func handleElicit(_ context.Context, stdin io.Reader, out io.Writer, req mcp.ElicitRequest, autoConfirm bool) (mcp.ElicitResult, error) {
+	var schema elicitSchema
+	if len(req.RequestedSchema) > 0 {
+		if err := json.Unmarshal(req.RequestedSchema, &schema); err != nil {
+			return mcp.ElicitResult{}, fmt.Errorf("failed to parse requested schema: %w", err)
+		}
+	}
+
+	if len(schema.Properties) == 0 && autoConfirm {
+		return mcp.ElicitResult{Action: mcp.ElicitActionAccept}, nil
+	}
+
+	in := bufio.NewReader(stdin)
+	_, _ = fmt.Fprintf(out, "? %s\n", req.Message)
+
+	if len(schema.Properties) == 0 {
+		for {
+			_, _ = fmt.Fprintf(out, "?  (y/n) ? ")
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return mcp.ElicitResult{}, err
+			}
+			switch strings.TrimSpace(strings.ToLower(line)) {
+			case "y", "yes":
+				return mcp.ElicitResult{Action: mcp.ElicitActionAccept}, nil
+			case "n", "no":
+				return mcp.ElicitResult{Action: mcp.ElicitActionDecline}, nil
+			}
+		}
+	}
+
+	content := map[string]any{}
+	keys := make([]string, 0, len(schema.Properties))
+	for key := range schema.Properties {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		prop := schema.Properties[key]
+		label := key
+		if prop.Description != "" {
+			label += " (" + prop.Description + ")"
+		}
+		if len(prop.Enum) > 0 {
+			label += fmt.Sprintf(" %v", prop.Enum)
+		}
+		required := slices.Contains(schema.Required, key)
+
+		for {
+			_, _ = fmt.Fprintf(out, "?  %s: ", label)
+			line, err := in.ReadString('\n')
+			if err != nil {
+				return mcp.ElicitResult{}, err
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				if required {
+					continue
+				}
+				break
+			}
+
+			value, err := parseElicitValue(prop.Type, line)
+			if err != nil {
+				_, _ = fmt.Fprintf(out, "?  %v\n", err)
+				continue
+			}
+			content[key] = value
+			break
+		}
+	}
+
+	return mcp.ElicitResult{
+		Action:  mcp.ElicitActionAccept,
+		Content: content,
+	}, nil
+}
+
+func parseElicitValue(valueType, value string) (any, error) {
+	switch valueType {
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "integer":
+		return strconv.Atoi(value)
+	case "boolean":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
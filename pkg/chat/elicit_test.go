@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func TestHandleElicitApproval(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		autoConfirm bool
+		action      string
+	}{
+		{name: "yes", input: "y\n", action: mcp.ElicitActionAccept},
+		{name: "no", input: "No\n", action: mcp.ElicitActionDecline},
+		{name: "asks again", input: "maybe\nyes\n", action: mcp.ElicitActionAccept},
+		{name: "auto confirm", autoConfirm: true, action: mcp.ElicitActionAccept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := handleElicit(context.Background(), strings.NewReader(tt.input), io.Discard, mcp.ElicitRequest{
+				Message: "Deploy?",
+			}, tt.autoConfirm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Action != tt.action {
+				t.Errorf("expected %s, got %s", tt.action, result.Action)
+			}
+		})
+	}
+
+	if _, err := handleElicit(context.Background(), strings.NewReader(""), io.Discard, mcp.ElicitRequest{}, false); err == nil {
+		t.Error("expected an error when the input ends before an answer")
+	}
+}
+
+func TestHandleElicitInput(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"count": {"type": "integer"},
+			"env": {"type": "string", "enum": ["dev", "prod"]},
+			"force": {"type": "boolean"},
+			"note": {"type": "string"}
+		},
+		"required": ["count", "env"]
+	}`)
+
+	var out strings.Builder
+	// The empty and invalid count are asked again, the optional force and note are skipped
+	result, err := handleElicit(context.Background(), strings.NewReader("\nten\n10\nprod\n\n\n"), &out, mcp.ElicitRequest{
+		Message:         "Deploy",
+		RequestedSchema: schema,
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{"count": 10, "env": "prod"}
+	if result.Action != mcp.ElicitActionAccept || !reflect.DeepEqual(result.Content, expected) {
+		t.Errorf("expected %v, got %s %v", expected, result.Action, result.Content)
+	}
+	if !strings.Contains(out.String(), "env [dev prod]") {
+		t.Errorf("expected the options of env in the prompt, got %q", out.String())
+	}
+}
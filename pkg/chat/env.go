@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"golang.org/x/term"
+)
+
+// envProblems returns the missing and invalid env variables of the error initializing the session.
+func envProblems(err error) []types.EnvProblem {
+	var rpcErr *mcp.RPCError
+	if !errors.As(err, &rpcErr) || len(rpcErr.Data) == 0 {
+		return nil
+	}
+	var data struct {
+		MissingEnv []types.EnvProblem `json:"missingEnv"`
+		InvalidEnv []types.EnvProblem `json:"invalidEnv"`
+	}
+	if err := json.Unmarshal(rpcErr.Data, &data); err != nil {
+		return nil
+	}
+	return append(data.MissingEnv, data.InvalidEnv...)
+}
+
+// askEnv prompts for the values of the env variables, checking them against their definitions,
+// and then asks whether to save them to the env file.
+func askEnv(problems []types.EnvProblem, envFile string) (values map[string]string, save bool, err error) {
+	in := bufio.NewReader(os.Stdin)
+	values = map[string]string{}
+
+	for _, problem := range problems {
+		def := problem.Def()
+		label := problem.Name
+		if problem.Description != "" {
+			label += " (" + problem.Description + ")"
+		}
+		if len(problem.Options) > 0 {
+			label += fmt.Sprintf(" %v", []string(problem.Options))
+		}
+		if problem.Default != "" && !problem.Sensitive {
+			label += fmt.Sprintf(" [%s]", problem.Default)
+		}
+		if problem.Error != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "! %s %s\n", problem.Name, problem.Error)
+		}
+
+		for {
+			_, _ = fmt.Fprintf(os.Stderr, "? %s: ", label)
+			line, err := readEnvValue(in, problem.Sensitive)
+			if err != nil {
+				return nil, false, err
+			}
+			if line == "" {
+				line = problem.Default
+			}
+			if line == "" {
+				continue
+			}
+			if err := def.Check(line); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "!  %s %v\n", problem.Name, err)
+				continue
+			}
+			values[problem.Name] = line
+			break
+		}
+	}
+
+	if envFile == "" {
+		return values, false, nil
+	}
+	for {
+		_, _ = fmt.Fprintf(os.Stderr, "? Save to %s (y/N) ? ", envFile)
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, false, err
+		}
+		switch strings.TrimSpace(strings.ToLower(line)) {
+		case "y", "yes":
+			return values, true, nil
+		case "", "n", "no":
+			return values, false, nil
+		}
+	}
+}
+
+// readEnvValue reads a line, without echoing it if the value is sensitive and stdin is a terminal.
+func readEnvValue(in *bufio.Reader, sensitive bool) (string, error) {
+	if sensitive && term.IsTerminal(int(os.Stdin.Fd())) {
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(data)), err
+	}
+	line, err := in.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
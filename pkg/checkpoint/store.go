@@ -0,0 +1,209 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var ErrNotFound = errors.New("flow run not found")
+
+// Run is the persisted state of a single flow execution.
+type Run struct {
+	ID     string `json:"id"`
+	Flow   string `json:"flow"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// FlowHash is the hash of the definition of the flow the run was started with, a run is only
+	// resumed by the same definition.
+	FlowHash string         `json:"flowHash"`
+	Data     map[string]any `json:"data"`
+	// Redacted is set if sensitive values were replaced in the data or the results of the steps,
+	// the run can not be resumed without them.
+	Redacted bool `json:"redacted,omitempty"`
+	// Steps are the completed top level steps of the flow, keyed by index
+	Steps   map[int]Step `json:"steps,omitempty"`
+	Created time.Time    `json:"created"`
+	Updated time.Time    `json:"updated"`
+}
+
+// Step is a completed top level step of a flow run.
+type Step struct {
+	// ID is the id of the step in the config, empty if it has none
+	ID     string              `json:"id,omitempty"`
+	Result *mcp.CallToolResult `json:"result"`
+}
+
+// Store persists flow runs as one JSON file per run in a directory.
+type Store struct {
+	dir    string
+	maxAge time.Duration
+}
+
+// NewStore returns a store in dir that prunes the runs that were not updated for maxAge, 0 keeps
+// the runs forever.
+func NewStore(dir string, maxAge time.Duration) *Store {
+	return &Store{
+		dir:    dir,
+		maxAge: maxAge,
+	}
+}
+
+// DefaultDir returns the flows directory in the nanobot user cache directory.
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "nanobot", "flows"), nil
+}
+
+func (s *Store) file(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid flow run id %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save writes the run to the store, replacing the given values, such as the values of sensitive
+// env vars, with [REDACTED] so they are never written to disk. The run is marked as redacted if
+// any of them were found.
+func (s *Store) Save(run *Run, redact ...string) error {
+	file, err := s.file(run.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory %s: %w", s.dir, err)
+	}
+
+	now := time.Now()
+	if run.Created.IsZero() {
+		run.Created = now
+	}
+	run.Updated = now
+
+	data, err := marshal(run, redact)
+	if err != nil {
+		return fmt.Errorf("failed to marshal flow run %s: %w", run.ID, err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a partial checkpoint behind
+	tmp, err := os.CreateTemp(s.dir, "."+run.ID+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func marshal(run *Run, redact []string) ([]byte, error) {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	redacted := mcp.Redact(data, redact)
+	if run.Redacted || bytes.Equal(redacted, data) {
+		return redacted, nil
+	}
+
+	run.Redacted = true
+	data, err = json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.Redact(data, redact), nil
+}
+
+func (s *Store) Load(id string) (*Run, error) {
+	file, err := s.file(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read flow run %s: %w", id, err)
+	}
+
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse flow run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+// Prune removes the runs that were not updated for the max age of the store.
+func (s *Store) Prune() error {
+	if s.maxAge <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if time.Since(info.ModTime()) > s.maxAge {
+			if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List returns all runs in the store, most recently updated first.
+func (s *Store) List() ([]Run, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	for _, file := range files {
+		run, err := s.Load(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	slices.SortFunc(runs, func(a, b Run) int {
+		return b.Updated.Compare(a.Updated)
+	})
+	return runs, nil
+}
@@ -0,0 +1,127 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func TestSaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir(), 0)
+
+	run := &Run{
+		ID:       "run1",
+		Flow:     "flow",
+		Status:   StatusRunning,
+		FlowHash: "abc",
+		Data: map[string]any{
+			"input": map[string]any{"token": "Bearer s3cr3t-value"},
+		},
+		Steps: map[int]Step{
+			0: {ID: "first", Result: &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "s3cr3t-value"}}}},
+		},
+	}
+	if err := store.Save(run, "s3cr3t-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(store.dir, "run1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t-value") {
+		t.Errorf("expected the sensitive value to be redacted, got %s", data)
+	}
+
+	loaded, err := store.Load("run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.FlowHash != "abc" || loaded.Steps[0].ID != "first" || loaded.Created.IsZero() {
+		t.Errorf("unexpected run %+v", loaded)
+	}
+	if !loaded.Redacted {
+		t.Errorf("expected the run to be marked as redacted")
+	}
+	if text := loaded.Steps[0].Result.Content[0].Text; text != "[REDACTED]" {
+		t.Errorf("expected the step result to be redacted, got %q", text)
+	}
+	if token := loaded.Data["input"].(map[string]any)["token"]; token != "Bearer [REDACTED]" {
+		t.Errorf("expected the input to be redacted, got %q", token)
+	}
+
+	plain := &Run{ID: "run2", Data: map[string]any{"input": "value"}}
+	if err := store.Save(plain, "s3cr3t-value"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := store.Load("run2"); err != nil || loaded.Redacted {
+		t.Errorf("expected a run without sensitive values to not be marked as redacted, got %v", err)
+	}
+
+	if _, err := store.Load("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	for _, id := range []string{"", ".", "..", "../run1", `a\b`} {
+		if _, err := store.Load(id); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("expected %q to be an invalid id, got %v", id, err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	store := NewStore(t.TempDir(), 0)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(&Run{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	if strings.Join(ids, ",") != "c,b,a" {
+		t.Errorf("expected the most recently updated run first, got %v", ids)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"old", "new"} {
+		if err := NewStore(dir, 0).Save(&Run{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewStore(dir, 0).Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := NewStore(dir, 0).List(); len(runs) != 2 {
+		t.Errorf("expected a max age of 0 to keep all runs, got %d", len(runs))
+	}
+
+	store := NewStore(dir, time.Hour)
+	if err := store.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the old run to be pruned, got %v", err)
+	}
+	if _, err := store.Load("new"); err != nil {
+		t.Errorf("expected the new run to be kept, got %v", err)
+	}
+}
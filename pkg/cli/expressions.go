@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/spf13/cobra"
+)
+
+// NewExpressions returns the help topic documenting the helper library of flow expressions.
+func NewExpressions() *cobra.Command {
+	return &cobra.Command{
+		Use:   "expressions",
+		Short: "The helper functions available in ${...} expressions",
+		Long:  expressionsHelp(),
+	}
+}
+
+func expressionsHelp() string {
+	var buf strings.Builder
+	buf.WriteString(`Values in flows, triggers and agents can contain ${...} expressions, which are
+evaluated as JavaScript with the flow data, such as input, previous and the IDs of
+the steps that ran, as variables.
+
+Besides the standard JavaScript built-ins, the helper functions below are available
+under the ` + expr.HelpersName + ` variable in every expression. For example:
+
+  ${nb.jsonpath.get(fetch, '$.content[0].text')}
+  ${nb.date.format(nb.date.now(), 'YYYY-MM-DD', 'Europe/Berlin')}
+  ${nb.text.template('Hello {{user.name}}', input)}
+
+Helper functions:
+`)
+
+	var namespace string
+	for _, h := range expr.Helpers() {
+		if ns, _, _ := strings.Cut(h.Name, "."); ns != namespace {
+			namespace = ns
+			fmt.Fprintf(&buf, "\n  %s\n", namespace)
+		}
+		fmt.Fprintf(&buf, "    %s\n", h.Usage)
+		for _, line := range wrap(h.Description, 72) {
+			fmt.Fprintf(&buf, "        %s\n", line)
+		}
+	}
+	return buf.String()
+}
+
+func wrap(text string, width int) (lines []string) {
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
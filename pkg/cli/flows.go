@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/chat"
+	"github.com/nanobot-ai/nanobot/pkg/cmd"
+	"github.com/nanobot-ai/nanobot/pkg/flowgraph"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/spf13/cobra"
+)
+
+type Flows struct {
+	n *Nanobot
+}
+
+func NewFlows(n *Nanobot) *cobra.Command {
+	return cmd.Command(&Flows{n: n},
+		&FlowsList{n: n},
+		&FlowsResume{n: n},
+		&FlowsGraph{n: n})
+}
+
+func (f *Flows) Customize(cmd *cobra.Command) {
+	cmd.Use = "flows"
+	cmd.Short = "Inspect and resume flow runs"
+	cmd.Aliases = []string{"flow"}
+}
+
+func (f *Flows) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type FlowsList struct {
+	Output string `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+	n      *Nanobot
+}
+
+func (l *FlowsList) Customize(cmd *cobra.Command) {
+	cmd.Use = "list [flags]"
+	cmd.Short = "List the checkpointed flow runs"
+	cmd.Aliases = []string{"ls"}
+	cmd.Args = cobra.NoArgs
+}
+
+func (l *FlowsList) Run(*cobra.Command, []string) error {
+	store, err := l.n.checkpoints()
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if display(runs, l.Output) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tFLOW\tSTATUS\tSTEPS\tUPDATED\tERROR")
+	for _, run := range runs {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", run.ID, run.Flow, run.Status, len(run.Steps),
+			run.Updated.Local().Format(time.DateTime), trim(run.Error))
+	}
+	return tw.Flush()
+}
+
+type FlowsGraph struct {
+	Format string `usage:"Graph format (mermaid, dot)" short:"f" default:"mermaid"`
+	Audit  string `usage:"Overlay the timings and outcomes of a run recorded in this audit directory or file"`
+	RunID  string `name:"run" usage:"ID of the recorded run to overlay, defaults to the latest run of the flow in the audit trail"`
+	n      *Nanobot
+}
+
+func (g *FlowsGraph) Customize(cmd *cobra.Command) {
+	cmd.Use = "graph [flags] NANOBOT [FLOW]"
+	cmd.Short = "Render the steps of a flow as a Mermaid or Graphviz DOT graph"
+	cmd.Example = `
+  # Print a Mermaid graph of the only flow in nanobot.yaml in the current directory
+  nanobot flows graph .
+
+  # Render a flow to an SVG with Graphviz
+  nanobot flows graph . research -f dot | dot -Tsvg > research.svg
+
+  # Show how the latest run recorded with "nanobot run --audit-dir ./audit" went
+  nanobot flows graph . research --audit ./audit
+`
+	cmd.Args = cobra.RangeArgs(1, 2)
+}
+
+func (g *FlowsGraph) Run(cmd *cobra.Command, args []string) error {
+	cfg, err := g.n.ReadConfig(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	var flowName string
+	if len(args) > 1 {
+		flowName = args[1]
+	} else if len(cfg.Flows) == 1 {
+		for name := range cfg.Flows {
+			flowName = name
+		}
+	} else {
+		return fmt.Errorf("%d flows found, specify one of: %s", len(cfg.Flows), strings.Join(slices.Sorted(maps.Keys(cfg.Flows)), ", "))
+	}
+
+	flow, ok := cfg.Flows[flowName]
+	if !ok {
+		return fmt.Errorf("flow %s not found", flowName)
+	}
+
+	var run *flowgraph.Run
+	if g.Audit != "" {
+		run, err = flowgraph.ReadRun(g.Audit, flowName, g.RunID)
+		if err != nil {
+			return err
+		}
+	} else if g.RunID != "" {
+		return fmt.Errorf("--run requires --audit")
+	}
+
+	return flowgraph.New(flowName, flow, run).Render(os.Stdout, g.Format)
+}
+
+type FlowsResume struct {
+	Output string `usage:"Output format (json, pretty)" default:"pretty" short:"o"`
+	n      *Nanobot
+}
+
+func (r *FlowsResume) Customize(cmd *cobra.Command) {
+	cmd.Use = "resume [flags] NANOBOT RUN_ID"
+	cmd.Short = "Resume a flow run from its last checkpoint, skipping the steps that already completed"
+	cmd.Example = `
+  # Checkpoint the flow runs of a nanobot
+  nanobot run --checkpoint .
+
+  # Find the ID of a failed or interrupted run
+  nanobot flows list
+
+  # Resume the run with the flows in nanobot.yaml in the current directory
+  nanobot flows resume . 2b0f8e0c-...
+`
+	cmd.Args = cobra.ExactArgs(2)
+}
+
+func (r *FlowsResume) Run(cmd *cobra.Command, args []string) error {
+	checkpoints, err := r.n.checkpoints()
+	if err != nil {
+		return err
+	}
+
+	runtime, err := r.n.GetRuntime(cmd.Context(), args[0], runtime.Options{
+		MaxConcurrency: r.n.MaxConcurrency,
+		Checkpoints:    checkpoints,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := runtime.WithTempSession(cmd.Context())
+
+	result, err := runtime.Service().ResumeFlow(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	if display(result, r.Output) {
+		return nil
+	}
+
+	return chat.PrintResult(os.Stdout, result)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+type Lock struct {
+	n *Nanobot
+}
+
+func NewLock(n *Nanobot) *Lock {
+	return &Lock{
+		n: n,
+	}
+}
+
+func (l *Lock) Customize(cmd *cobra.Command) {
+	cmd.Use = "lock [flags] NANOBOT"
+	cmd.Short = "Pin the remote configs, git sources and images of a nanobot in nanobot.lock"
+	cmd.Long = `Resolve every remote config the nanobot reads, the configs it extends, the git sources of its MCP
+servers and the images of its sandboxed MCP servers to a commit, checksum or digest, and write
+them to nanobot.lock. The lock file is written next to a local config, or to the current directory
+for a remote config.
+
+The nanobot then runs with the locked versions. With --frozen, running it fails when anything is
+missing from the lock file or a remote config changed since it was locked.`
+	cmd.Example = `
+  # Lock the nanobot.yaml in the current directory
+  nanobot lock .
+
+  # Lock a nanobot from GitLab and run it, failing if the lock is out of date
+  nanobot lock gitlab.com/example/agents.git
+  nanobot run --frozen gitlab.com/example/agents.git
+`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (l *Lock) Run(cmd *cobra.Command, args []string) error {
+	file, err := config.WriteLock(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Wrote %s\n", file)
+	return nil
+}
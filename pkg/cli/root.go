@@ -6,17 +6,26 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/checkpoint"
 	"github.com/nanobot-ai/nanobot/pkg/cmd"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
+	"github.com/nanobot-ai/nanobot/pkg/expr"
 	"github.com/nanobot-ai/nanobot/pkg/llm"
 	"github.com/nanobot-ai/nanobot/pkg/llm/anthropic"
 	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
+	"github.com/nanobot-ai/nanobot/pkg/triggers"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/version"
 	"github.com/spf13/cobra"
@@ -29,15 +38,26 @@ func New() *cobra.Command {
 	root := cmd.Command(n,
 		NewCall(n),
 		NewTargets(n),
-		NewRun(n))
+		NewRun(n),
+		NewTrace(n),
+		NewFlows(n),
+		NewTriggers(n),
+		NewValidate(n),
+		NewSchema(),
+		NewLock(n),
+		NewSecrets(),
+		NewExpressions())
 	return root
 }
 
+const defaultEnvFile = "./nanobot.env"
+
 type Nanobot struct {
 	Debug            bool              `usage:"Enable debug logging"`
 	Trace            bool              `usage:"Enable trace logging"`
 	Env              []string          `usage:"Environment variables to set in the form of KEY=VALUE, or KEY to load from current environ" short:"e"`
-	EnvFile          string            `usage:"Path to the environment file (default: ./nanobot.env)"`
+	EnvFile          []string          `usage:"Paths of environment files, later files override earlier ones (default: ./nanobot.env)"`
+	Profile          []string          `usage:"Profiles of the config to use, nanobot.PROFILE.env is loaded after the environment files for each (a profile ending in ? is optional)" env:"NANOBOT_PROFILE"`
 	DefaultModel     string            `usage:"Default model to use for completions" default:"gpt-4.1" env:"NANOBOT_DEFAULT_MODEL" name:"default-model"`
 	OpenAIAPIKey     string            `usage:"OpenAI API key" env:"OPENAI_API_KEY" name:"openai-api-key"`
 	OpenAIBaseURL    string            `usage:"OpenAI API URL" env:"OPENAI_BASE_URL" name:"openai-base-url"`
@@ -46,7 +66,20 @@ type Nanobot struct {
 	AnthropicBaseURL string            `usage:"Anthropic API URL" env:"ANTHROPIC_BASE_URL" name:"anthropic-base-url"`
 	AnthropicHeaders map[string]string `usage:"Anthropic API headers" env:"ANTHROPIC_HEADERS" name:"anthropic-headers"`
 	MaxConcurrency   int               `usage:"The maximum number of concurrent tasks in a parallel loop" default:"10"`
+	ExprTimeout      string            `usage:"The maximum time a single expression may run, 0 for no limit" default:"10s" env:"NANOBOT_EXPR_TIMEOUT" name:"expr-timeout"`
+	ExprMaxMemory    int               `usage:"The maximum MiB of the values a single expression or script returns, 0 for no limit" default:"256" env:"NANOBOT_EXPR_MAX_MEMORY" name:"expr-max-memory"`
 	Chdir            string            `usage:"Change directory to this path before running the nanobot" default:"." short:"C"`
+	LogLevel         string            `usage:"Log level optionally followed by per component levels (ex: info,llm=debug,mcp:github=debug,flow=warn)" default:"info" env:"NANOBOT_LOG_LEVEL" name:"log-level"`
+	LogFormat        string            `usage:"Log format (text, json)" default:"text" env:"NANOBOT_LOG_FORMAT" name:"log-format"`
+	LogFile          string            `usage:"Append logs to this file instead of stderr" env:"NANOBOT_LOG_FILE" name:"log-file"`
+	OtelEndpoint     string            `usage:"OTLP/HTTP endpoint to send traces to (ex: http://localhost:4318)" env:"OTEL_EXPORTER_OTLP_ENDPOINT" name:"otel-endpoint"`
+	OtelHeaders      map[string]string `usage:"Headers to send to the OTLP endpoint" env:"OTEL_EXPORTER_OTLP_HEADERS" name:"otel-headers"`
+	OtelFile         string            `usage:"Append traces to this file as lines of OTLP JSON" name:"otel-file"`
+	Checkpoint       bool              `usage:"Checkpoint flow runs so they can be resumed with \"nanobot flows resume\"" env:"NANOBOT_CHECKPOINT"`
+	FlowStateDir     string            `usage:"Directory to checkpoint flow runs in (default: $XDG_CACHE_HOME/nanobot/flows)" env:"NANOBOT_FLOW_STATE_DIR" name:"flow-state-dir"`
+	FlowStateMaxAge  string            `usage:"Remove checkpointed flow runs that were not updated for this long, 0 to keep them" default:"168h" env:"NANOBOT_FLOW_STATE_MAX_AGE" name:"flow-state-max-age"`
+	TriggerStateDir  string            `usage:"Directory to store the schedule and last run of triggers in (default: $XDG_CACHE_HOME/nanobot/triggers)" env:"NANOBOT_TRIGGER_STATE_DIR" name:"trigger-state-dir"`
+	Frozen           bool              `usage:"Fail if a remote config, git source or image is not in nanobot.lock or changed since it was locked" env:"NANOBOT_FROZEN"`
 
 	env map[string]string
 }
@@ -79,6 +112,40 @@ func (n *Nanobot) PersistentPre(cmd *cobra.Command, _ []string) error {
 		log.DebugLog = true
 	}
 
+	if err := log.Setup(log.Options{
+		Level:  n.LogLevel,
+		Format: n.LogFormat,
+		File:   n.LogFile,
+	}); err != nil {
+		return err
+	}
+
+	exprTimeout, err := time.ParseDuration(n.ExprTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid expression timeout %q: %w", n.ExprTimeout, err)
+	}
+	expr.Setup(expr.Options{
+		Timeout:   exprTimeout,
+		MaxMemory: uint64(max(n.ExprMaxMemory, 0)) << 20,
+	})
+
+	shutdown, err := tracing.Setup(tracing.Options{
+		Endpoint:    n.OtelEndpoint,
+		Headers:     n.OtelHeaders,
+		File:        n.OtelFile,
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	})
+	if err != nil {
+		return err
+	}
+	cobra.OnFinalize(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Errorf(ctx, "failed to flush traces: %v", err)
+		}
+	})
+
 	for _, sub := range cmd.Commands() {
 		if sub.Name() == "help" {
 			sub.Hidden = true
@@ -131,25 +198,23 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 		env["CWD"] = cwd
 	}
 
-	defaultFile := n.EnvFile == ""
-	if defaultFile {
-		n.EnvFile = "./nanobot.env"
-	}
-
-	data, err := os.ReadFile(n.EnvFile)
-	if errors.Is(err, fs.ErrNotExist) && defaultFile {
-	} else if err != nil {
-		return nil, err
-	} else {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
+	for _, file := range n.envFiles() {
+		data, err := os.ReadFile(file.path)
+		if errors.Is(err, fs.ErrNotExist) && file.optional {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		values, err := envvar.ParseDotenv(data, func(key string) (string, bool) {
+			if v, ok := env[key]; ok {
+				return v, true
 			}
-			k, v, _ := strings.Cut(line, "=")
-			env[k] = v
+			return os.LookupEnv(key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.path, err)
 		}
+		maps.Copy(env, values)
 	}
 
 	if _, ok := env["NANOBOT_MCP"]; !ok {
@@ -168,20 +233,158 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 	return env, nil
 }
 
+type envFile struct {
+	path     string
+	optional bool
+}
+
+// envFiles returns the environment files in the order they are loaded, ./nanobot.env if none are
+// set, followed by the files of the profiles, such as nanobot.prod.env for nanobot.env.
+func (n *Nanobot) envFiles() (result []envFile) {
+	files := n.EnvFile
+	if len(files) == 0 {
+		files = []string{defaultEnvFile}
+	}
+	for _, file := range files {
+		result = append(result, envFile{
+			path:     file,
+			optional: len(n.EnvFile) == 0,
+		})
+	}
+	for _, profile := range n.Profile {
+		// A trailing ? only marks the profile as optional in the config, it is not part of the
+		// name of the env file, which is optional either way
+		profile = strings.TrimSuffix(profile, "?")
+		for _, file := range files {
+			result = append(result, envFile{
+				path:     profileEnvFile(file, profile),
+				optional: true,
+			})
+		}
+	}
+	return result
+}
+
+func profileEnvFile(file, profile string) string {
+	dir, base := filepath.Split(file)
+	if name, ok := strings.CutSuffix(base, ".env"); ok && name != "" {
+		return dir + name + "." + profile + ".env"
+	}
+	return file + "." + profile
+}
+
+// saveFile returns the environment file values are saved to, the last one as it overrides the
+// others.
+func (n *Nanobot) saveFile() string {
+	if len(n.EnvFile) == 0 {
+		return defaultEnvFile
+	}
+	return n.EnvFile[len(n.EnvFile)-1]
+}
+
+// saveEnv writes the values to the env file, replacing the variables that are already in it.
+func (n *Nanobot) saveEnv(values map[string]string) error {
+	file := n.saveFile()
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		lines = strings.Split(content, "\n")
+	}
+	remaining := maps.Clone(values)
+	for i, line := range lines {
+		// Lines that are not a whole assignment, such as the first line of a multi-line value, are
+		// kept and the new value is appended, which overrides them
+		parsed, err := envvar.ParseDotenv([]byte(line), nil)
+		if err != nil {
+			continue
+		}
+		for k := range parsed {
+			if v, ok := remaining[k]; ok {
+				lines[i] = k + "=" + envvar.QuoteDotenv(v)
+				delete(remaining, k)
+			}
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(remaining)) {
+		lines = append(lines, k+"="+envvar.QuoteDotenv(remaining[k]))
+	}
+
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
+
 func (n *Nanobot) ReadConfig(ctx context.Context, cfgPath string, opts ...runtime.Options) (*types.Config, error) {
-	cfg, _, err := config.Load(ctx, cfgPath, complete.Complete(opts...).Profiles...)
+	configOpts, err := n.configOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := config.Load(ctx, cfgPath, configOpts)
 	return cfg, err
 }
 
+func (n *Nanobot) configOptions(opts ...runtime.Options) (config.Options, error) {
+	env, err := n.loadEnv()
+	if err != nil {
+		return config.Options{}, fmt.Errorf("failed to load environment: %w", err)
+	}
+	return config.Options{
+		Profiles: append(slices.Clone(n.Profile), complete.Complete(opts...).Profiles...),
+		Frozen:   n.Frozen,
+		Env:      env,
+	}, nil
+}
+
 func (n *Nanobot) GetRuntime(ctx context.Context, cfgPath string, opts ...runtime.Options) (*runtime.Runtime, error) {
 	cfg, err := n.ReadConfig(ctx, cfgPath, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	if n.Checkpoint {
+		checkpoints, err := n.checkpoints()
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]runtime.Options{{Checkpoints: checkpoints}}, opts...)
+	}
+
 	return runtime.NewRuntime(n.llmConfig(), *cfg, opts...), nil
 }
 
+func (n *Nanobot) checkpoints() (*checkpoint.Store, error) {
+	dir := n.FlowStateDir
+	if dir == "" {
+		var err error
+		dir, err = checkpoint.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	maxAge, err := time.ParseDuration(n.FlowStateMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flow state max age %q: %w", n.FlowStateMaxAge, err)
+	}
+	return checkpoint.NewStore(dir, maxAge), nil
+}
+
+func (n *Nanobot) triggerStore() (*triggers.Store, error) {
+	dir := n.TriggerStateDir
+	if dir == "" {
+		var err error
+		dir, err = triggers.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return triggers.NewStore(dir), nil
+}
+
 func (n *Nanobot) Run(cmd *cobra.Command, _ []string) error {
 	return cmd.Help()
 }
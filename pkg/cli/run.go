@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"os"
@@ -11,12 +12,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/audit"
 	"github.com/nanobot-ai/nanobot/pkg/chat"
+	"github.com/nanobot-ai/nanobot/pkg/config"
 	"github.com/nanobot-ai/nanobot/pkg/confirm"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/openai"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
 	"github.com/nanobot-ai/nanobot/pkg/server"
+	"github.com/nanobot-ai/nanobot/pkg/triggers"
+	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
@@ -28,6 +35,13 @@ type Run struct {
 	ListenAddress string   `usage:"Address to listen on (ex: localhost:8099) (implies -m)" default:"stdio" short:"a"`
 	Roots         []string `usage:"Roots to expose the MCP server in the form of name:directory" short:"r"`
 	Input         string   `usage:"Input file for the prompt" default:"" short:"f"`
+	AuditDir      string   `usage:"Directory to write a JSONL audit trail of every MCP message to"`
+	AuditMaxSize  int      `usage:"Size in MB at which the audit file is rotated" default:"10"`
+	AuditMaxFiles int      `usage:"Number of rotated audit files to keep, 0 keeps all files" default:"0"`
+	Metrics       bool     `usage:"Expose Prometheus metrics at /metrics on the listen address"`
+	OpenAIAPI     bool     `usage:"Serve agents and flows as models of an OpenAI compatible API at /v1/models and /v1/chat/completions" name:"openai-api"`
+	NoTriggers    bool     `usage:"Do not run the scheduled and webhook triggers of the config when listening on an address"`
+	Watch         bool     `usage:"Reload the config when it, the configs it extends or the local sources of its MCP servers change" short:"w"`
 	n             *Nanobot
 }
 
@@ -55,6 +69,9 @@ func (r *Run) Customize(cmd *cobra.Command) {
 
   # Run the nanobot as a MCP Server
   nanobot run --mcp
+
+  # Reload the nanobot while editing nanobot.yaml, restarting only the MCP servers that changed
+  nanobot run --watch .
 `
 	cmd.Args = cobra.MinimumNArgs(1)
 }
@@ -128,7 +145,7 @@ func (r *Run) Run(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		return r.runMCP(cmd.Context(), runtime, nil)
+		return r.runMCP(cmd.Context(), runtime, nil, args[0], runtimeOpt)
 	}
 
 	runtimeOpt.Confirmations = confirm.NewService()
@@ -169,38 +186,95 @@ func (r *Run) Run(cmd *cobra.Command, args []string) error {
 		prompt = strings.TrimSpace(string(input))
 	}
 
+	env, err := r.n.loadEnv()
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
 	eg, ctx := errgroup.WithContext(cmd.Context())
 	ctx, cancel := context.WithCancel(ctx)
 	eg.Go(func() error {
-		return r.runMCP(ctx, runtime, l)
+		return r.runMCP(ctx, runtime, l, args[0], runtimeOpt)
 	})
 	eg.Go(func() error {
 		defer cancel()
 		return chat.Chat(ctx, r.ListenAddress, runtimeOpt.Confirmations, r.AutoConfirm, prompt, r.Output,
 			func(client *mcp.Client) error {
 				return r.reload(ctx, client, runtime, args[0], runtimeOpt)
+			},
+			r.n.saveFile(),
+			func(values map[string]string, save bool) error {
+				// New sessions copy the env, the chat is the only client so nothing reads it now
+				maps.Copy(env, values)
+				if save {
+					return r.n.saveEnv(values)
+				}
+				return nil
 			})
 	})
 	return eg.Wait()
 }
 
-func (r *Run) runMCP(ctx context.Context, runtime *runtime.Runtime, l net.Listener) error {
+// watch reloads the runtime and the sessions of the MCP server when the config changes. Changes
+// that make the config invalid are logged and the last valid config is kept.
+func (r *Run) watch(ctx context.Context, runtime *runtime.Runtime, mcpServer *server.Server, cfgPath string, runtimeOpt runtime.Options) {
+	configOpts, err := r.n.configOptions(runtimeOpt)
+	if err != nil {
+		log.Errorf(ctx, "Failed to watch %s: %v", cfgPath, err)
+		return
+	}
+
+	err = config.Watch(ctx, cfgPath, configOpts, func(cfg *types.Config, err error) {
+		if err != nil {
+			log.Errorf(ctx, "Not reloading %s, keeping the last valid config: %v", cfgPath, err)
+			return
+		}
+		if restarted := runtime.Reload(*cfg); len(restarted) > 0 {
+			log.Infof(ctx, "Reloaded %s, restarting MCP servers: %s", cfgPath, strings.Join(restarted, ", "))
+		} else {
+			log.Infof(ctx, "Reloaded %s", cfgPath)
+		}
+		mcpServer.Reload(ctx)
+	})
+	if err != nil {
+		log.Errorf(ctx, "Failed to watch %s: %v", cfgPath, err)
+	}
+}
+
+func (r *Run) runMCP(ctx context.Context, runtime *runtime.Runtime, l net.Listener, cfgPath string, runtimeOpt runtime.Options) error {
 	env, err := r.n.loadEnv()
 	if err != nil {
 		return fmt.Errorf("failed to load environment: %w", err)
 	}
 
 	address := r.ListenAddress
-	if strings.HasPrefix("address", "http://") {
+	if strings.HasPrefix(address, "http://") {
 		address = strings.TrimPrefix(address, "http://")
 	} else if strings.HasPrefix(address, "https://") {
 		return fmt.Errorf("https:// is not supported, use http:// instead")
 	}
 
 	mcpServer := server.NewServer(runtime)
+	if r.Watch {
+		go r.watch(ctx, runtime, mcpServer, cfgPath, runtimeOpt)
+	}
+
+	var recorder mcp.Recorder
+	if r.AuditDir != "" {
+		fileRecorder, err := audit.NewFileRecorder(r.AuditDir, audit.Options{
+			MaxSize:  int64(r.AuditMaxSize) * 1024 * 1024,
+			MaxFiles: r.AuditMaxFiles,
+		})
+		if err != nil {
+			return err
+		}
+		defer fileRecorder.Close()
+		recorder = fileRecorder
+	}
 
 	if address == "stdio" {
 		stdio := mcp.NewStdioServer(env, mcpServer)
+		stdio.Recorder = recorder
 		if err := stdio.Start(ctx, os.Stdin, os.Stdout); err != nil {
 			return fmt.Errorf("failed to start stdio server: %w", err)
 		}
@@ -209,11 +283,51 @@ func (r *Run) runMCP(ctx context.Context, runtime *runtime.Runtime, l net.Listen
 		return nil
 	}
 
+	if l == nil && len(runtime.GetConfig().Triggers) > 0 && !r.NoTriggers {
+		store, err := r.n.triggerStore()
+		if err != nil {
+			return err
+		}
+		scheduler := triggers.NewScheduler(runtime, env, triggers.Options{
+			Recorder: recorder,
+			Store:    store,
+		})
+
+		schedulerCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			scheduler.Run(schedulerCtx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+
 	httpServer := mcp.NewHTTPServer(env, mcpServer)
+	httpServer.Recorder = recorder
+
+	mux := http.NewServeMux()
+	mux.Handle("/", httpServer)
+	if r.Metrics {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+	if r.OpenAIAPI {
+		api := openai.NewServer(runtime, env)
+		api.Recorder = recorder
+		defer api.Close()
+		mux.Handle("/v1/", api)
+	}
+	if !r.NoTriggers {
+		mux.Handle("/webhooks/", triggers.NewWebhookServer(runtime, env, triggers.Options{
+			Recorder: recorder,
+		}))
+	}
 
 	s := &http.Server{
 		Addr:    address,
-		Handler: httpServer,
+		Handler: mux,
 	}
 
 	context.AfterFunc(ctx, func() {
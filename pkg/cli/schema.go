@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+type Schema struct {
+	Output string `usage:"Output format (json, yaml)" default:"json" short:"o"`
+}
+
+func NewSchema() *Schema {
+	return &Schema{}
+}
+
+func (s *Schema) Customize(cmd *cobra.Command) {
+	cmd.Use = "schema [flags]"
+	cmd.Short = "Print the JSON schema of the nanobot config for editors and other tools"
+	cmd.Example = `
+  # Write the schema for an editor to validate and complete nanobot.yaml with
+  nanobot schema > nanobot.schema.json
+`
+	cmd.Args = cobra.NoArgs
+}
+
+func (s *Schema) Run(_ *cobra.Command, _ []string) error {
+	data, err := config.Schema()
+	if err != nil {
+		return err
+	}
+
+	switch s.Output {
+	case "json":
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		fmt.Println(buf.String())
+	case "yaml":
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		return fmt.Errorf("unknown output format %q, must be json or yaml", s.Output)
+	}
+	return nil
+}
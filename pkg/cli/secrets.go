@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/cmd"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+type Secrets struct {
+}
+
+func NewSecrets() *cobra.Command {
+	return cmd.Command(&Secrets{},
+		&SecretsSet{},
+		&SecretsList{},
+		&SecretsRemove{})
+}
+
+func (s *Secrets) Customize(cmd *cobra.Command) {
+	cmd.Use = "secrets"
+	cmd.Short = "Manage the secrets in the local vault"
+	cmd.Long = `Manage the local vault, a file of secrets encrypted with the key in $NANOBOT_VAULT_KEY. The vault is
+in the user config directory unless $NANOBOT_VAULT_FILE is set.
+
+Env values and the config refer to secrets with ${secret:PROVIDER:KEY}, which is resolved when the
+value is used and redacted from logs and progress notifications:
+
+  ${secret:vault:github-token}      the secret github-token in the vault
+  ${secret:file:/run/secrets/token} the content of a file
+  ${secret:cmd:pass show github}    the output of a command`
+	cmd.Example = `
+  # Store a secret read from stdin and use it in nanobot.env
+  export NANOBOT_VAULT_KEY=...
+  pass show github | nanobot secrets set github-token
+  echo 'GITHUB_TOKEN=${secret:vault:github-token}' >> nanobot.env
+`
+	cmd.Aliases = []string{"secret"}
+}
+
+func (s *Secrets) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type VaultOptions struct {
+	Key  string `usage:"Key to unlock the vault" env:"NANOBOT_VAULT_KEY"`
+	File string `usage:"Vault file (default: $XDG_CONFIG_HOME/nanobot/vault)" env:"NANOBOT_VAULT_FILE"`
+}
+
+func (v VaultOptions) open() (*secrets.Vault, error) {
+	file := v.File
+	if file == "" {
+		var err error
+		file, err = secrets.DefaultVaultFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return secrets.OpenVault(file, v.Key)
+}
+
+type SecretsSet struct {
+	VaultOptions
+}
+
+func (s *SecretsSet) Customize(cmd *cobra.Command) {
+	cmd.Use = "set [flags] NAME [VALUE]"
+	cmd.Short = "Add or replace a secret, reading the value from stdin if it is not given"
+	cmd.Args = cobra.RangeArgs(1, 2)
+}
+
+func (s *SecretsSet) Run(cmd *cobra.Command, args []string) error {
+	vault, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	var value string
+	if len(args) > 1 {
+		value = args[1]
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read secret from stdin: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	vault.Set(args[0], value)
+	return vault.Save()
+}
+
+type SecretsList struct {
+	VaultOptions
+}
+
+func (s *SecretsList) Customize(cmd *cobra.Command) {
+	cmd.Use = "list [flags]"
+	cmd.Short = "List the names of the secrets in the vault"
+	cmd.Aliases = []string{"ls"}
+	cmd.Args = cobra.NoArgs
+}
+
+func (s *SecretsList) Run(cmd *cobra.Command, _ []string) error {
+	vault, err := s.open()
+	if err != nil {
+		return err
+	}
+	for _, name := range vault.Names() {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+type SecretsRemove struct {
+	VaultOptions
+}
+
+func (s *SecretsRemove) Customize(cmd *cobra.Command) {
+	cmd.Use = "rm [flags] NAME..."
+	cmd.Short = "Remove secrets from the vault"
+	cmd.Aliases = []string{"remove", "delete"}
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (s *SecretsRemove) Run(cmd *cobra.Command, args []string) error {
+	vault, err := s.open()
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if !vault.Delete(name) {
+			return fmt.Errorf("secret %s not found in the vault", name)
+		}
+	}
+	return vault.Save()
+}
@@ -39,7 +39,7 @@ func (t *Targets) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	tools, err := r.ListTools(r.WithTempSession(cmd.Context()), tools.ListToolsOptions{
+	tools, err := r.Service().ListTools(r.WithTempSession(cmd.Context()), tools.ListToolsOptions{
 		Servers: t.MCPServer,
 	})
 	if err != nil {
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nanobot-ai/nanobot/pkg/audit"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/spf13/cobra"
+)
+
+type Trace struct {
+	Session   string `usage:"Only show messages of this session and its child sessions" short:"s"`
+	Server    string `usage:"Only show messages sent to or received from this MCP server"`
+	Method    string `usage:"Only show messages with a method starting with this value (ex: tools/)" short:"m"`
+	Direction string `usage:"Only show messages in this direction (in, out)"`
+	Since     string `usage:"Only show messages after this time, as RFC3339 or a duration (ex: 1h)"`
+	Until     string `usage:"Only show messages before this time, as RFC3339 or a duration (ex: 10m)"`
+	Full      bool   `usage:"Print the full message instead of a summary"`
+	Output    string `usage:"Output format (json, pretty)" short:"o" default:"pretty"`
+	n         *Nanobot
+}
+
+func NewTrace(n *Nanobot) *Trace {
+	return &Trace{
+		n: n,
+	}
+}
+
+func (t *Trace) Customize(cmd *cobra.Command) {
+	cmd.Use = "trace [flags] AUDIT_DIR_OR_FILE"
+	cmd.Short = "Filter and print the audit trail written by \"nanobot run --audit-dir\""
+	cmd.Example = `
+  # Print all messages in the audit trail
+  nanobot trace ./audit
+
+  # Print all tool calls from the last hour
+  nanobot trace ./audit --method tools/call --since 1h
+
+  # Print the messages of a single session as JSON
+  nanobot trace ./audit --session 2b0f8e0c-... -o json
+`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be RFC3339 or a duration: %w", value, err)
+	}
+	return t, nil
+}
+
+func (t *Trace) Run(_ *cobra.Command, args []string) error {
+	since, err := parseTime(t.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(t.Until)
+	if err != nil {
+		return err
+	}
+
+	filter := audit.Filter{
+		SessionID: t.Session,
+		Server:    t.Server,
+		Method:    t.Method,
+		Direction: t.Direction,
+		Since:     since,
+		Until:     until,
+	}
+
+	return audit.Read(args[0], filter, func(record mcp.Record, msg mcp.Message) error {
+		if t.Output == "json" {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(os.Stdout, string(data))
+			return err
+		}
+		return t.print(record, msg)
+	})
+}
+
+func (t *Trace) print(record mcp.Record, msg mcp.Message) error {
+	var (
+		arrow   = "<-"
+		session = record.SessionID
+		server  = record.Server
+		kind    string
+		body    []byte
+	)
+	if record.Direction == mcp.DirectionOut {
+		arrow = "->"
+	}
+	if server == "" {
+		server = "client"
+	}
+
+	switch {
+	case msg.Method != "":
+		kind = msg.Method
+		body = msg.Params
+	case msg.Error != nil:
+		kind = "error"
+		body, _ = json.Marshal(msg.Error)
+	default:
+		kind = "result"
+		body = msg.Result
+	}
+	if msg.ID != nil {
+		kind += fmt.Sprintf(" (id=%v)", msg.ID)
+	}
+
+	var text string
+	if t.Full {
+		if indented, err := json.MarshalIndent(json.RawMessage(body), "  ", "  "); err == nil {
+			text = "\n  " + string(indented)
+		} else {
+			text = string(body)
+		}
+	} else {
+		text = strings.ReplaceAll(string(log.Base64Replace.ReplaceAll(body, log.Base64Replacement)), "\n", " ")
+		if len(text) > 200 {
+			end := 200
+			for end > 0 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+			text = text[:end] + "..."
+		}
+	}
+
+	_, err := fmt.Fprintf(os.Stdout, "%s %s(%s) %s [%s] %s\n",
+		record.Time.Local().Format("2006-01-02 15:04:05.000"), arrow, server, kind, session, text)
+	return err
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/cmd"
+	"github.com/nanobot-ai/nanobot/pkg/triggers"
+	"github.com/spf13/cobra"
+)
+
+type Triggers struct {
+	n *Nanobot
+}
+
+func NewTriggers(n *Nanobot) *cobra.Command {
+	return cmd.Command(&Triggers{n: n},
+		&TriggersList{n: n})
+}
+
+func (t *Triggers) Customize(cmd *cobra.Command) {
+	cmd.Use = "triggers"
+	cmd.Short = "Inspect the scheduled triggers of a nanobot"
+	cmd.Aliases = []string{"trigger"}
+}
+
+func (t *Triggers) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type TriggersList struct {
+	Output string `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+	n      *Nanobot
+}
+
+func (l *TriggersList) Customize(cmd *cobra.Command) {
+	cmd.Use = "list [flags] NANOBOT"
+	cmd.Short = "List the triggers of a nanobot with their next and last run"
+	cmd.Example = `
+  # Show when the triggers in nanobot.yaml run next and how their last run went
+  nanobot triggers list .
+`
+	cmd.Aliases = []string{"ls"}
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+type triggerInfo struct {
+	triggers.State
+	Target   string `json:"target"`
+	When     string `json:"when"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+func (l *TriggersList) Run(cmd *cobra.Command, args []string) error {
+	cfg, err := l.n.ReadConfig(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	store, err := l.n.triggerStore()
+	if err != nil {
+		return err
+	}
+
+	var infos []triggerInfo
+	for _, name := range slices.Sorted(maps.Keys(cfg.Triggers)) {
+		trigger := cfg.Triggers[name]
+		state, err := store.Load(name)
+		if err != nil {
+			return err
+		}
+
+		info := triggerInfo{
+			State:    state,
+			Target:   "flow " + trigger.Flow,
+			When:     trigger.Schedule,
+			Disabled: trigger.Disabled,
+		}
+		if trigger.Agent != "" {
+			info.Target = "agent " + trigger.Agent
+		}
+		if trigger.Every != "" {
+			info.When = "every " + trigger.Every
+		}
+		if trigger.Webhook != nil {
+			info.When = "POST /webhooks/" + trigger.WebhookPath(name)
+		}
+		infos = append(infos, info)
+	}
+
+	if display(infos, l.Output) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tTARGET\tSCHEDULE\tNEXT RUN\tLAST RUN\tSTATUS\tDURATION\tERROR")
+	for _, info := range infos {
+		next := formatTime(info.NextRun)
+		if info.Disabled {
+			next = "disabled"
+		}
+		var duration string
+		if info.LastDuration > 0 {
+			duration = info.LastDuration.Round(time.Millisecond).String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.Target, info.When, next,
+			formatTime(info.LastRun), info.LastStatus, duration, trim(info.LastError))
+	}
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.DateTime)
+}
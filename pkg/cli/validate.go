@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nanobot-ai/nanobot/pkg/cmd"
+	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+type Validate struct {
+	Strict  bool     `usage:"Fail on warnings as well as errors"`
+	Profile []string `usage:"Profiles to merge into the config before checking references and usage"`
+	Output  string   `usage:"Output format (text, json)" default:"text" short:"o"`
+	n       *Nanobot
+}
+
+func NewValidate(n *Nanobot) *Validate {
+	return &Validate{
+		n: n,
+	}
+}
+
+func (v *Validate) Customize(cmd *cobra.Command) {
+	cmd.Use = "validate [flags] NANOBOT"
+	cmd.Short = "Check a nanobot config for errors and likely mistakes without running it"
+	cmd.Long = `Check a nanobot config against the schema and the rules enforced when it is loaded, including
+the configs it extends and all of its profiles, and warn about agents, flows, MCP servers and env
+that are not used, entrypoints that can not be reached and tool choices an agent does not have.
+
+The exit code is 0 if the config is valid, 1 if it has errors, or warnings with --strict, and 2
+if it can not be read.`
+	cmd.Example = `
+  # Check nanobot.yaml in the current directory
+  nanobot validate .
+
+  # Fail a CI build on warnings too
+  nanobot validate --strict ./nanobot.yaml
+`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (v *Validate) Run(c *cobra.Command, args []string) error {
+	// Merged into the root profiles, so their env files are loaded as well
+	v.n.Profile = append(v.n.Profile, v.Profile...)
+
+	configOpts, err := v.n.configOptions()
+	if err != nil {
+		return &cmd.ExitError{Code: 2, Err: err}
+	}
+
+	problems, err := config.Validate(c.Context(), args[0], configOpts)
+	if err != nil {
+		return &cmd.ExitError{Code: 2, Err: err}
+	}
+
+	var errs, warnings int
+	for _, problem := range problems {
+		if problem.Severity == config.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	if v.Output == "json" {
+		if problems == nil {
+			problems = []config.Problem{}
+		}
+		data, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) == 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "%s is valid\n", args[0])
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s)\n", errs, warnings)
+		}
+	}
+
+	if errs > 0 || (v.Strict && warnings > 0) {
+		return &cmd.ExitError{Code: 1}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+var (
+	// scpURL matches the scp like syntax of SSH URLs, such as git@gitlab.com:group/repo.git
+	scpURL = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+	// gitSuffix matches the end of the repository in a path such as group/repo.git/configs
+	gitSuffix = regexp.MustCompile(`\.git(/|$)`)
+	// httpGitURL matches HTTP URLs of repositories rather than files
+	httpGitURL = regexp.MustCompile(`^https?://[^@]+?\.git(/|$)|^https?://[^@]+?//`)
+	commitHash = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+	gitLocks sync.Map
+)
+
+// gitCacheDir returns the directory repositories are cloned to.
+var gitCacheDir = func() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "nanobot", "git"), nil
+}
+
+func isGitURL(name string) bool {
+	for _, scheme := range []string{"ssh://", "git://", "file://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return scpURL.MatchString(name) || httpGitURL.MatchString(name)
+}
+
+// resolveGit parses the name of a config in a git repository. The repository is a URL, an SSH URL
+// or host/owner/repo, where the host defaults to github.com. The path of the config in the
+// repository follows the repository, separated by // if the repository does not end in .git or
+// is not owner/repo, and the branch, tag or commit follows an @.
+//
+//	nanobot-ai/welcome@v0.1.0
+//	gitlab.example.com/group/subgroup/repo.git/agents/nanobot.yaml
+//	git@gitlab.example.com:group/repo.git//agents/nanobot.yaml@main
+//	https://git.example.com/repo//agents/nanobot.yaml
+func resolveGit(name string) (*resource, error) {
+	location, ref := name, ""
+	if i := strings.LastIndex(name, "@"); i > strings.LastIndex(name, "/") && !strings.Contains(name[i:], ":") {
+		location, ref = name[:i], name[i+1:]
+	}
+
+	scheme, rest := "", location
+	if before, after, ok := strings.Cut(location, "://"); ok {
+		scheme, rest = before+"://", after
+	}
+
+	var repo, subPath string
+	if before, after, ok := strings.Cut(rest, "//"); ok {
+		repo, subPath = before, after
+	} else if loc := gitSuffix.FindStringIndex(rest); loc != nil {
+		repo, subPath = rest[:loc[0]+len(".git")], rest[loc[1]:]
+	} else if scheme != "" || scpURL.MatchString(rest) {
+		repo = rest
+	} else {
+		parts := strings.Split(rest, "/")
+		if !strings.Contains(parts[0], ".") {
+			parts = append([]string{"github.com"}, parts...)
+		}
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid git resource format, must be {owner}/{repo} format: %s", name)
+		}
+		repo, subPath = strings.Join(parts[:3], "/"), strings.Join(parts[3:], "/")
+	}
+
+	if scheme == "" && !scpURL.MatchString(repo) {
+		repo = "https://" + strings.TrimSuffix(repo, ".git") + ".git"
+	} else {
+		repo = scheme + repo
+	}
+
+	return &resource{
+		resourceType: "git",
+		repo:         repo,
+		parts:        splitPath(subPath),
+		ref:          ref,
+	}, nil
+}
+
+// splitPath returns the elements of a path in a repository, which can not leave the repository.
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// gitRead reads the file, or the nanobot.yaml of the directory, at the path in the repository. Public
+// GitHub repositories are read over HTTP, other repositories are cloned to the git cache with the
+// git credentials of the user and fetched again when the ref is not a commit that is cached.
+func gitRead(ctx context.Context, repo string, parts []string, ref string) ([]byte, error) {
+	file := strings.Join(parts, "/")
+
+	if ownerRepo, ok := strings.CutPrefix(repo, "https://github.com/"); ok {
+		rawRef, rawFile := ref, file
+		if rawRef == "" {
+			rawRef = "HEAD"
+		}
+		if path.Ext(rawFile) == "" {
+			rawFile = path.Join(rawFile, "nanobot.yaml")
+		}
+		url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", strings.TrimSuffix(ownerRepo, ".git"), rawRef, rawFile)
+		data, err := httpGet(ctx, url)
+		if err == nil {
+			return data, nil
+		}
+		log.Debugf(ctx, "failed to read %s, cloning %s instead: %v", url, repo, err)
+	}
+
+	dir, err := gitClone(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := gitRevParse(ctx, dir, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if out, err := git(ctx, dir, "cat-file", "-t", commit+":"+file); file == "" || (err == nil && strings.TrimSpace(string(out)) == "tree") {
+		file = path.Join(file, "nanobot.yaml")
+	}
+	data, err := git(ctx, dir, "cat-file", "blob", commit+":"+file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s in %s: %w", file, commit, repo, err)
+	}
+	return data, nil
+}
+
+// gitClone returns the bare clone of the repository in the git cache, which is named after the
+// hash of the URL. The clone is fetched unless the ref is a commit it already has, if fetching
+// fails and the ref can be found in the clone, the cached clone is used.
+func gitClone(ctx context.Context, repo, ref string) (string, error) {
+	cacheDir, err := gitCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(repo))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(hash[:16]))
+
+	lock, _ := gitLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create git cache directory: %w", err)
+		}
+		// Clone to a temporary directory so a failed clone does not leave a broken cache behind
+		tmp := dir + ".tmp-" + uuid.String()
+		defer os.RemoveAll(tmp)
+		if _, err := git(ctx, "", "clone", "--bare", "--quiet", "--", repo, tmp); err != nil {
+			return "", fmt.Errorf("failed to clone %s: %w", repo, err)
+		}
+		if _, err := git(ctx, tmp, "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			return "", fmt.Errorf("failed to move clone of %s to the git cache: %w", repo, err)
+		}
+		return dir, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if commitHash.MatchString(ref) {
+		if _, err := gitRevParse(ctx, dir, ref); err == nil {
+			return dir, nil
+		}
+	}
+
+	if _, err := git(ctx, dir, "fetch", "--quiet", "--prune", "--tags", "origin"); err != nil {
+		if _, revErr := gitRevParse(ctx, dir, ref); revErr != nil {
+			return "", fmt.Errorf("failed to fetch %s: %w", repo, err)
+		}
+		log.Infof(ctx, "Failed to fetch %s, using the cached clone: %v", repo, err)
+	}
+	return dir, nil
+}
+
+func gitRevParse(ctx context.Context, dir, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	out, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s: %w", ref, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// git runs git without prompting for credentials, so private repositories rely on the credential
+// helpers and SSH agent of the user.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// gitLsRemote returns the commit a branch, tag or HEAD of a remote repository points to, without
+// cloning it.
+func gitLsRemote(ctx context.Context, repo, ref string) (string, error) {
+	if commitHash.MatchString(ref) {
+		return ref, nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	out, err := git(ctx, "", "ls-remote", "--", repo, ref, ref+"^{}")
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s of %s: %w", ref, repo, err)
+	}
+
+	var commit string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		hash, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(name, "^{}") {
+			// The commit an annotated tag points to
+			return hash, nil
+		}
+		if commit == "" {
+			commit = hash
+		}
+	}
+	if commit == "" {
+		return "", fmt.Errorf("%s not found in %s", ref, repo)
+	}
+	return commit, nil
+}
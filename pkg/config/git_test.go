@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testRepo is a bare repository with a working copy to push commits to it from.
+type testRepo struct {
+	t      *testing.T
+	remote string
+	work   string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	r := &testRepo{
+		t:      t,
+		remote: filepath.Join(dir, "remote.git"),
+		work:   filepath.Join(dir, "work"),
+	}
+	r.git("", "init", "--quiet", "--bare", "--initial-branch=main", r.remote)
+	r.git("", "clone", "--quiet", r.remote, r.work)
+	r.git(r.work, "checkout", "--quiet", "-B", "main")
+
+	cacheDir := filepath.Join(dir, "cache")
+	oldCacheDir := gitCacheDir
+	gitCacheDir = func() (string, error) {
+		return cacheDir, nil
+	}
+	t.Cleanup(func() {
+		gitCacheDir = oldCacheDir
+	})
+	return r
+}
+
+func (r *testRepo) git(dir string, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes the files, pushes them and returns the commit.
+func (r *testRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		file := filepath.Join(r.work, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git(r.work, "add", "-A")
+	r.git(r.work, "commit", "--quiet", "-m", "update")
+	r.git(r.work, "push", "--quiet", "--tags", "origin", "main")
+	return r.git(r.work, "rev-parse", "HEAD")
+}
+
+func (r *testRepo) read(name string) (string, error) {
+	r.t.Helper()
+	res, err := resolve(name)
+	if err != nil {
+		r.t.Fatalf("failed to resolve %s: %v", name, err)
+	}
+	data, err := res.read(context.Background())
+	return string(data), err
+}
+
+func TestGitRead(t *testing.T) {
+	r := newTestRepo(t)
+	first := r.commit(map[string]string{
+		"nanobot.yaml":          "version: 1\n",
+		"agents/nanobot.yaml":   "agent: 1\n",
+		"agents/base/base.yaml": "base: 1\n",
+	})
+	r.git(r.work, "tag", "v1")
+	r.git(r.work, "push", "--quiet", "origin", "v1")
+	r.commit(map[string]string{
+		"nanobot.yaml": "version: 2\n",
+	})
+
+	url := "file://" + r.remote
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: url, want: "version: 2\n"},
+		{name: url + "//nanobot.yaml", want: "version: 2\n"},
+		{name: url + "@v1", want: "version: 1\n"},
+		{name: url + "//nanobot.yaml@" + first, want: "version: 1\n"},
+		{name: url + "//agents@main", want: "agent: 1\n"},
+		{name: url + "//agents/base/base.yaml", want: "base: 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.read(tt.name)
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := r.read(url + "//missing.yaml"); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+	if _, err := r.read(url + "@v9"); err == nil {
+		t.Error("expected an error reading a missing tag")
+	}
+}
+
+func TestGitReadFetchesAndFallsBackToCache(t *testing.T) {
+	r := newTestRepo(t)
+	first := r.commit(map[string]string{"nanobot.yaml": "version: 1\n"})
+
+	url := "file://" + r.remote
+	if got, err := r.read(url); err != nil || got != "version: 1\n" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	r.commit(map[string]string{"nanobot.yaml": "version: 2\n"})
+	if got, err := r.read(url + "@main"); err != nil || got != "version: 2\n" {
+		t.Fatalf("expected the branch to be fetched again, got %q, %v", got, err)
+	}
+
+	if err := os.RemoveAll(r.remote); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.read(url + "@" + first); err != nil || got != "version: 1\n" {
+		t.Fatalf("expected the cached commit without fetching, got %q, %v", got, err)
+	}
+	if got, err := r.read(url + "@main"); err != nil || got != "version: 2\n" {
+		t.Fatalf("expected the cached clone when fetching fails, got %q, %v", got, err)
+	}
+}
+
+func TestGitRel(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit(map[string]string{
+		"configs/nanobot.yaml": "extends: ../base.yaml\n",
+		"base.yaml":            "base: 1\n",
+	})
+
+	res, err := resolve("file://" + r.remote + "//configs/nanobot.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := res.Rel("../base.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parent.read(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read parent: %v", err)
+	}
+	if string(data) != "base: 1\n" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestResolveGit(t *testing.T) {
+	tests := []struct {
+		name  string
+		repo  string
+		parts []string
+		ref   string
+	}{
+		{name: "nanobot-ai/welcome", repo: "https://github.com/nanobot-ai/welcome.git"},
+		{name: "nanobot-ai/welcome/agents/x.yaml@v1", repo: "https://github.com/nanobot-ai/welcome.git", parts: []string{"agents", "x.yaml"}, ref: "v1"},
+		{name: "gitlab.example.com/group/sub/repo.git/agents@main", repo: "https://gitlab.example.com/group/sub/repo.git", parts: []string{"agents"}, ref: "main"},
+		{name: "gitlab.example.com/group/sub/repo//agents", repo: "https://gitlab.example.com/group/sub/repo.git", parts: []string{"agents"}},
+		{name: "git@gitlab.example.com:group/repo.git", repo: "git@gitlab.example.com:group/repo.git"},
+		{name: "git@gitlab.example.com:group/repo.git//a/b.yaml@v2", repo: "git@gitlab.example.com:group/repo.git", parts: []string{"a", "b.yaml"}, ref: "v2"},
+		{name: "ssh://git@git.example.com:2222/repo//x.yaml", repo: "ssh://git@git.example.com:2222/repo", parts: []string{"x.yaml"}},
+		{name: "https://git.example.com/scm/repo.git/x.yaml@abc", repo: "https://git.example.com/scm/repo.git", parts: []string{"x.yaml"}, ref: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolve(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.resourceType != "git" || res.repo != tt.repo || !slices.Equal(res.parts, tt.parts) || res.ref != tt.ref {
+				t.Errorf("got %s %s %v %s", res.resourceType, res.repo, res.parts, res.ref)
+			}
+		})
+	}
+}
@@ -8,10 +8,30 @@ import (
 	"os"
 	"strings"
 
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
-func Load(ctx context.Context, path string, profiles ...string) (cfg *types.Config, cwd string, err error) {
+type Options struct {
+	// Profiles are merged into the config in order, a profile ending in ? is optional
+	Profiles []string
+	// Frozen fails loading when a remote config, git source or image is not in the lock file, or a
+	// remote config changed since it was locked
+	Frozen bool
+	// Env is the env the config runs with, flows referencing env vars that are neither set in it
+	// nor declared in the config are warned about
+	Env map[string]string
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.Profiles = append(o.Profiles, other.Profiles...)
+	result.Frozen = o.Frozen || other.Frozen
+	result.Env = complete.MergeMap(o.Env, other.Env)
+	return
+}
+
+func Load(ctx context.Context, path string, opts ...Options) (cfg *types.Config, cwd string, err error) {
 	defer func() {
 		if err != nil {
 			if _, fErr := os.Stat(path); fErr == nil && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, ".") {
@@ -24,58 +44,85 @@ func Load(ctx context.Context, path string, profiles ...string) (cfg *types.Conf
 		return nil, "", fmt.Errorf("error resolving config path %s: %w", path, err)
 	}
 
-	return loadResource(ctx, configResource, profiles...)
+	return loadResource(ctx, configResource, complete.Complete(opts...))
 }
 
-func loadResource(ctx context.Context, configResource *resource, profiles ...string) (*types.Config, string, error) {
+func loadResource(ctx context.Context, configResource *resource, opt Options) (*types.Config, string, error) {
 	targetCwd, err := configResource.Cwd()
 	if err != nil {
 		return nil, "", fmt.Errorf("error determining working directory: %w", err)
 	}
 
-	last, err := configResource.Load(ctx)
+	last, err := resolveConfig(ctx, configResource, opt, nil)
 	if err != nil {
 		return nil, "", err
 	}
 
+	if err := last.Validate(configResource.resourceType == "path"); err != nil {
+		return &last, targetCwd, err
+	}
+
+	for _, diagnostic := range last.Analyze(types.AnalyzeOptions{Env: opt.Env}) {
+		log.Warnf(ctx, "%s", diagnostic)
+	}
+
+	return &last, targetCwd, nil
+}
+
+// resolveConfig loads the config with the config it extends and the profiles merged in, pinning
+// remote configs, git sources and images to the lock. The lock file of the config is used if the
+// locker is nil.
+func resolveConfig(ctx context.Context, configResource *resource, opt Options, l *locker) (types.Config, error) {
+	if l == nil {
+		var err error
+		l, err = readLock(configResource, opt.Frozen)
+		if err != nil {
+			return types.Config{}, err
+		}
+	}
+
+	last, err := configResource.Load(ctx, l)
+	if err != nil {
+		return last, err
+	}
+
 	if last.Extends != "" {
 		parentResource, err := configResource.Rel(last.Extends)
 		if err != nil {
-			return nil, "", fmt.Errorf("error resolving extends %s: %w", last.Extends, err)
+			return last, fmt.Errorf("error resolving extends %s: %w", last.Extends, err)
 		}
 
-		parent, err := parentResource.Load(ctx)
+		parent, err := parentResource.Load(ctx, l)
 		if err != nil {
-			return nil, "", fmt.Errorf("error loading parent config %s: %w", parentResource.url, err)
+			return last, fmt.Errorf("error loading parent config %s: %w", parentResource.url, err)
 		}
 
 		last, err = merge(parent, last)
 		if err != nil {
-			return nil, "", fmt.Errorf("error merging %s: %w", last.Extends, err)
+			return last, fmt.Errorf("error merging %s: %w", last.Extends, err)
 		}
 	}
 
-	for _, profile := range profiles {
+	for _, profile := range opt.Profiles {
 		profileName, _, optional := strings.Cut(profile, "?")
 		profileConfig, found := last.Profiles[profileName]
 		if !found && !optional {
-			return nil, "", fmt.Errorf("profile %s not found", profileName)
+			return last, fmt.Errorf("profile %s not found", profileName)
 		} else if !found {
 			continue
 		}
 		var err error
 		last, err = merge(last, profileConfig)
 		if err != nil {
-			return nil, "", fmt.Errorf("error merging profile %s: %w", profileName, err)
+			return last, fmt.Errorf("error merging profile %s: %w", profileName, err)
 		}
 	}
 
 	last, err = rewriteSourceReferences(last, configResource)
 	if err != nil {
-		return nil, "", fmt.Errorf("error rewriting source references: %w", err)
+		return last, fmt.Errorf("error rewriting source references: %w", err)
 	}
-
-	return &last, targetCwd, last.Validate(configResource.resourceType == "path")
+	return l.pin(ctx, last)
 }
 
 func rewriteSourceReferences(cfg types.Config, resource *resource) (types.Config, error) {
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/sandbox"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// LockFile is the name of the lock file, which is next to a local config, or in the current
+// directory for a remote config.
+const LockFile = "nanobot.lock"
+
+// Lock pins the remote configs, git sources of MCP servers and images of sandboxed MCP servers a
+// config uses to the commits and digests they resolved to when the config was locked.
+type Lock struct {
+	Version int `json:"version"`
+	// Configs are the remote configs by URL, including the ref they are read at
+	Configs map[string]LockedConfig `json:"configs,omitempty"`
+	// Sources are the git sources of MCP servers by repository and ref, such as https://github.com/example/mcp.git@main
+	Sources map[string]LockedSource `json:"sources,omitempty"`
+	// Images are the images of sandboxed MCP servers by name
+	Images map[string]LockedImage `json:"images,omitempty"`
+}
+
+type LockedConfig struct {
+	Commit string `json:"commit,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+type LockedSource struct {
+	Commit string `json:"commit"`
+}
+
+type LockedImage struct {
+	Digest string `json:"digest"`
+}
+
+// locker applies a lock when loading a config, or records a new lock when locking is set.
+type locker struct {
+	lock    *Lock
+	file    string
+	frozen  bool
+	locking bool
+	warned  sync.Map
+}
+
+func lockPath(configResource *resource) (string, error) {
+	if configResource.resourceType != "path" {
+		return LockFile, nil
+	}
+	cwd, err := configResource.Cwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cwd, LockFile), nil
+}
+
+func readLock(configResource *resource, frozen bool) (*locker, error) {
+	file, err := lockPath(configResource)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &locker{
+		file:   file,
+		frozen: frozen,
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	l.lock = &Lock{}
+	if err := yaml.Unmarshal(data, l.lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return l, nil
+}
+
+// drift fails in frozen mode, or warns once that the lock is not up to date if there is one.
+func (l *locker) drift(key, format string, args ...any) error {
+	if l.lock == nil && !l.frozen {
+		return nil
+	}
+	msg := fmt.Sprintf(format, args...)
+	if l.frozen {
+		return fmt.Errorf("%s, run \"nanobot lock\" to update %s", msg, l.file)
+	}
+	if _, warned := l.warned.LoadOrStore(key, true); !warned {
+		log.Warnf(context.Background(), "%s, run \"nanobot lock\" to update %s", msg, l.file)
+	}
+	return nil
+}
+
+// read reads a config, at the locked commit if it is in a git repository.
+func (l *locker) read(ctx context.Context, r *resource) ([]byte, error) {
+	if r.resourceType == "path" {
+		return r.read(ctx)
+	}
+
+	key := r.String()
+	if l.locking {
+		locked := LockedConfig{}
+		if r.resourceType == "git" {
+			commit, err := gitLsRemote(ctx, r.repo, r.ref)
+			if err != nil {
+				return nil, err
+			}
+			locked.Commit = commit
+			r = &resource{resourceType: "git", repo: r.repo, parts: r.parts, ref: commit}
+		}
+		data, err := r.read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		locked.SHA256 = checksum(data)
+		l.lock.Configs[key] = locked
+		return data, nil
+	}
+
+	var (
+		locked LockedConfig
+		ok     bool
+	)
+	if l.lock != nil {
+		locked, ok = l.lock.Configs[key]
+	}
+	if !ok {
+		if err := l.drift(key, "config %s is not locked", key); err != nil {
+			return nil, err
+		}
+		return r.read(ctx)
+	}
+
+	if r.resourceType == "git" && locked.Commit != "" {
+		r = &resource{resourceType: "git", repo: r.repo, parts: r.parts, ref: locked.Commit}
+	}
+	data, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sum := checksum(data); sum != locked.SHA256 {
+		if err := l.drift(key, "config %s changed since it was locked, its checksum is %s instead of %s", key, sum, locked.SHA256); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pin sets the locked commits of the git sources and the digests of the images of the MCP servers.
+func (l *locker) pin(ctx context.Context, cfg types.Config) (types.Config, error) {
+	for _, name := range slices.Sorted(maps.Keys(cfg.MCPServers)) {
+		server := cfg.MCPServers[name]
+
+		if key, ok := sourceKey(server.Source); ok {
+			commit, err := l.sourceCommit(ctx, key, server.Source)
+			if err != nil {
+				return cfg, fmt.Errorf("failed to pin source of MCP server %s: %w", name, err)
+			}
+			if commit != "" {
+				server.Source.Commit = commit
+			}
+		}
+
+		if image, ok := sandboxImage(server); ok {
+			digest, err := l.imageDigest(ctx, image)
+			if err != nil {
+				return cfg, fmt.Errorf("failed to pin image of MCP server %s: %w", name, err)
+			}
+			if digest != "" {
+				server.Image = image + "@" + digest
+			}
+		}
+
+		cfg.MCPServers[name] = server
+	}
+	return cfg, nil
+}
+
+func (l *locker) sourceCommit(ctx context.Context, key string, source mcp.ServerSource) (string, error) {
+	if l.locking {
+		if locked, ok := l.lock.Sources[key]; ok {
+			return locked.Commit, nil
+		}
+		commit, err := gitLsRemote(ctx, source.Repo, sourceRef(source))
+		if err != nil {
+			return "", err
+		}
+		l.lock.Sources[key] = LockedSource{Commit: commit}
+		return commit, nil
+	}
+
+	if l.lock != nil {
+		if locked, ok := l.lock.Sources[key]; ok {
+			return locked.Commit, nil
+		}
+	}
+	return "", l.drift(key, "source %s is not locked", key)
+}
+
+func (l *locker) imageDigest(ctx context.Context, image string) (string, error) {
+	if l.locking {
+		if locked, ok := l.lock.Images[image]; ok {
+			return locked.Digest, nil
+		}
+		digest, err := sandbox.ImageDigest(ctx, image)
+		if err != nil {
+			return "", err
+		}
+		l.lock.Images[image] = LockedImage{Digest: digest}
+		return digest, nil
+	}
+
+	if l.lock != nil {
+		if locked, ok := l.lock.Images[image]; ok {
+			return locked.Digest, nil
+		}
+	}
+	return "", l.drift(image, "image %s is not locked", image)
+}
+
+// sourceKey returns the key of a remote git source in the lock, local sources and sources that
+// are already pinned to a commit are not locked.
+func sourceKey(source mcp.ServerSource) (string, bool) {
+	if source.Repo == "" || source.Commit != "" || strings.HasPrefix(source.Repo, "/") {
+		return "", false
+	}
+	if ref := sourceRef(source); ref != "" {
+		return source.Repo + "@" + ref, true
+	}
+	return source.Repo, true
+}
+
+func sourceRef(source mcp.ServerSource) string {
+	for _, ref := range []string{source.Tag, source.Branch, source.Reference} {
+		if ref != "" {
+			return ref
+		}
+	}
+	return ""
+}
+
+// sandboxImage returns the image an MCP server runs in, if it runs in a sandbox with an image
+// that is not already pinned to a digest.
+func sandboxImage(server mcp.Server) (string, bool) {
+	if server.Command == "" || server.Command == "nanobot" || server.Unsandboxed || server.Dockerfile != "" {
+		return "", false
+	}
+	image := server.Image
+	if image == "" {
+		image = sandbox.DefaultImage()
+	}
+	if strings.Contains(image, "@") {
+		return "", false
+	}
+	return image, true
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteLock resolves the remote configs, git sources and images of the config and all of its
+// profiles to commits and digests, and writes them to the lock file. It returns the path of the
+// lock file.
+func WriteLock(ctx context.Context, path string) (string, error) {
+	configResource, err := resolve(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving config path %s: %w", path, err)
+	}
+
+	file, err := lockPath(configResource)
+	if err != nil {
+		return "", err
+	}
+	l := &locker{
+		file:    file,
+		locking: true,
+		lock: &Lock{
+			Version: 1,
+			Configs: map[string]LockedConfig{},
+			Sources: map[string]LockedSource{},
+			Images:  map[string]LockedImage{},
+		},
+	}
+
+	cfg, err := resolveConfig(ctx, configResource, Options{}, l)
+	if err != nil {
+		return "", err
+	}
+	for _, profile := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		if _, err := resolveConfig(ctx, configResource, Options{Profiles: []string{profile}}, l); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := yaml.Marshal(l.lock)
+	if err != nil {
+		return "", err
+	}
+	data = append([]byte("# Generated by \"nanobot lock\", do not edit.\n"), data...)
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// sourceRepo is the repository of the git source of the MCP server of the test config, which git
+// is configured to read from the test repository.
+const sourceRepo = "https://git.example.com/mcp.git"
+
+// commitConfig pushes a config with the instructions and an MCP server with a git source in the
+// same repository, and returns its URL and the commit. The lock of a remote config is in the
+// current directory.
+func commitConfig(t *testing.T, r *testRepo, instructions string) (string, string) {
+	t.Helper()
+	remote := "file://" + r.remote
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "url."+remote+".insteadOf")
+	t.Setenv("GIT_CONFIG_VALUE_0", sourceRepo)
+	commit := r.commit(map[string]string{"nanobot.yaml": `publish:
+  instructions: ` + instructions + `
+mcpServers:
+  server:
+    command: node
+    unsandboxed: true
+    source:
+      repo: ` + sourceRepo + `
+      branch: main
+`})
+	return remote + "//nanobot.yaml@main", commit
+}
+
+func readTestLock(t *testing.T, file string) Lock {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock Lock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+func writeTestLock(t *testing.T, file string, lock Lock) {
+	t.Helper()
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	r := newTestRepo(t)
+	path, first := commitConfig(t, r, "first")
+
+	file, err := WriteLock(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != LockFile {
+		t.Errorf("expected the lock file in the current directory, got %s", file)
+	}
+
+	lock := readTestLock(t, file)
+	configKey := path
+	if locked := lock.Configs[configKey]; locked.Commit != first || locked.SHA256 == "" {
+		t.Errorf("expected config %s to be locked at %s, got %+v", configKey, first, lock.Configs)
+	}
+	sourceKey := sourceRepo + "@main"
+	if locked := lock.Sources[sourceKey]; locked.Commit != first {
+		t.Errorf("expected source %s to be locked at %s, got %+v", sourceKey, first, lock.Sources)
+	}
+
+	// Loading uses the locked commits even though the branch moved
+	commitConfig(t, r, "second")
+	for _, frozen := range []bool{false, true} {
+		cfg, _, err := Load(context.Background(), path, Options{Frozen: frozen})
+		if err != nil {
+			t.Fatalf("frozen %v: %v", frozen, err)
+		}
+		if cfg.Publish.Instructions != "first" {
+			t.Errorf("frozen %v: expected the locked config, got %q", frozen, cfg.Publish.Instructions)
+		}
+		if commit := cfg.MCPServers["server"].Source.Commit; commit != first {
+			t.Errorf("frozen %v: expected the source to be pinned to %s, got %q", frozen, first, commit)
+		}
+	}
+
+	// Locking again picks up the new commit
+	if _, err := WriteLock(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := Load(context.Background(), path, Options{Frozen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Publish.Instructions != "second" {
+		t.Errorf("expected the config of the new lock, got %q", cfg.Publish.Instructions)
+	}
+}
+
+func TestLockFrozenDrift(t *testing.T) {
+	t.Chdir(t.TempDir())
+	r := newTestRepo(t)
+	path, _ := commitConfig(t, r, "first")
+	file, configKey := LockFile, path
+	tests := []struct {
+		name   string
+		change func(lock *Lock)
+		// err is the error of loading frozen, loading without frozen warns and succeeds
+		err string
+	}{
+		{
+			name:   "up to date",
+			change: func(*Lock) {},
+		},
+		{
+			name: "config not locked",
+			change: func(lock *Lock) {
+				delete(lock.Configs, configKey)
+			},
+			err: "config " + configKey + " is not locked",
+		},
+		{
+			name: "config changed",
+			change: func(lock *Lock) {
+				locked := lock.Configs[configKey]
+				locked.SHA256 = checksum([]byte("other"))
+				lock.Configs[configKey] = locked
+			},
+			err: "config " + configKey + " changed since it was locked",
+		},
+		{
+			name: "source not locked",
+			change: func(lock *Lock) {
+				lock.Sources = nil
+			},
+			err: "source " + sourceRepo + "@main is not locked",
+		},
+		{
+			name: "no lock file",
+			err:  "config " + configKey + " is not locked",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := WriteLock(context.Background(), path); err != nil {
+				t.Fatal(err)
+			}
+			if tt.change == nil {
+				if err := os.Remove(file); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				lock := readTestLock(t, file)
+				tt.change(&lock)
+				writeTestLock(t, file, lock)
+			}
+
+			_, _, err := Load(context.Background(), path, Options{Frozen: true})
+			if tt.err == "" && err != nil {
+				t.Fatalf("expected frozen loading to succeed, got %v", err)
+			} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), `run "nanobot lock" to update `+file)) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+
+			if _, _, err := Load(context.Background(), path); err != nil {
+				t.Errorf("expected loading without frozen to succeed, got %v", err)
+			}
+		})
+	}
+}
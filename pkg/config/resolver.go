@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
@@ -17,8 +18,10 @@ import (
 type resource struct {
 	resourceType string
 	url          string
-	parts        []string
-	ref          string
+	// repo is the URL to clone a git resource from, parts the path in the repository
+	repo  string
+	parts []string
+	ref   string
 }
 
 func httpGet(ctx context.Context, url string) ([]byte, error) {
@@ -45,24 +48,24 @@ func httpGet(ctx context.Context, url string) ([]byte, error) {
 	return data, nil
 }
 
-func (r *resource) Load(ctx context.Context) (result types.Config, _ error) {
-	data, err := r.read(ctx)
+func (r *resource) Load(ctx context.Context, l *locker) (result types.Config, _ error) {
+	data, err := l.read(ctx, r)
 	if err != nil {
-		return result, fmt.Errorf("error reading resource %s: %w", r.url, err)
+		return result, fmt.Errorf("error reading resource %s: %w", r, err)
 	}
 
 	obj := map[string]any{}
 	if err := yaml.Unmarshal(data, &obj); err != nil {
-		return result, fmt.Errorf("error unmarshalling resource %s: %w", r.url, err)
+		return result, fmt.Errorf("error unmarshalling resource %s: %w", r, err)
 	}
 
 	s := getSchema()
 	if err := s.Validate(obj); err != nil {
-		return result, fmt.Errorf("error validating resource %s: %w", r.url, err)
+		return result, fmt.Errorf("error validating resource %s: %w", r, err)
 	}
 
 	if err := types.Marshal(obj, &result); err != nil {
-		return result, fmt.Errorf("error marshalling resource %s: %w", r.url, err)
+		return result, fmt.Errorf("error marshalling resource %s: %w", r, err)
 	}
 	return
 }
@@ -94,11 +97,11 @@ func (r *resource) SourceRel(source mcp.ServerSource) (mcp.ServerSource, error)
 		source.Repo = cwd
 		return source, nil
 	case "git":
-		source.Repo = fmt.Sprintf("https://%s/%s/%s.git", r.parts[0], r.parts[1], r.parts[2])
+		source.Repo = r.repo
 		if source.Reference == "" && source.Tag == "" && source.Branch == "" && source.Commit == "" {
 			source.Reference = r.ref
 		}
-		source.SubPath = strings.Join(append(r.parts[3:], source.SubPath), "/")
+		source.SubPath = strings.Join(append(slices.Clone(r.parts), source.SubPath), "/")
 		return source, nil
 	}
 
@@ -106,8 +109,12 @@ func (r *resource) SourceRel(source mcp.ServerSource) (mcp.ServerSource, error)
 }
 
 func (r *resource) String() string {
-	if len(r.parts) > 0 {
-		return strings.Join(r.parts, "/")
+	if r.resourceType == "git" {
+		name := r.repo + "//" + strings.Join(r.parts, "/")
+		if r.ref != "" {
+			name += "@" + r.ref
+		}
+		return name
 	}
 	return r.url
 }
@@ -126,7 +133,7 @@ func (r *resource) read(ctx context.Context) ([]byte, error) {
 	}
 
 	if r.resourceType == "git" {
-		return gitRead(ctx, r.parts, r.ref)
+		return gitRead(ctx, r.repo, r.parts, r.ref)
 	}
 
 	return nil, fmt.Errorf("unknown resource type: %s", r.resourceType)
@@ -138,7 +145,7 @@ func joinPath(url, path string) string {
 	}
 
 	dir, file := parts[:len(parts)-1], parts[len(parts)-1]
-	if strings.Contains(file, ".") {
+	if strings.Contains(file, ".") && file != "." && file != ".." {
 		url = strings.Join(dir, "/")
 	}
 
@@ -195,31 +202,19 @@ func (r *resource) Rel(path string) (*resource, error) {
 	case "git":
 		return &resource{
 			resourceType: "git",
-			parts:        append(r.parts, path),
+			repo:         r.repo,
+			parts:        splitPath(joinPath(strings.Join(r.parts, "/"), path)),
 			ref:          r.ref,
 		}, nil
 	}
 	return nil, fmt.Errorf("unknown resource type: %s", r.resourceType)
 }
 
-func gitRead(ctx context.Context, parts []string, ref string) ([]byte, error) {
-	if parts[0] != "github.com" {
-		// Handle other git providers or formats
-		return nil, fmt.Errorf("git read not implemented for %s", strings.Join(parts, "/"))
-	}
-
-	owner, repo := parts[1], parts[2]
-	path := strings.Join(parts[3:], "/")
-
-	if ref == "" {
-		ref = "HEAD"
+func resolve(name string) (*resource, error) {
+	if isGitURL(name) {
+		return resolveGit(name)
 	}
 
-	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", owner, repo, ref, path)
-	return httpGet(ctx, url)
-}
-
-func resolve(name string) (*resource, error) {
 	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
 		// Handle HTTP resources
 		return &resource{
@@ -236,20 +231,5 @@ func resolve(name string) (*resource, error) {
 		}, nil
 	}
 
-	location, ref, _ := strings.Cut(name, "@")
-	parts := strings.Split(location, "/")
-
-	if !strings.Contains(parts[0], ".") {
-		parts = append([]string{"github.com"}, parts...)
-	}
-
-	if len(parts) < 3 {
-		return nil, fmt.Errorf("invalid git resource format, must be {owner}/{repo} format: %s", name)
-	}
-
-	return &resource{
-		resourceType: "git",
-		parts:        parts,
-		ref:          ref,
-	}, nil
+	return resolveGit(name)
 }
@@ -47,3 +47,12 @@ func initSchema() (*jsonschema.Schema, error) {
 
 	return s, nil
 }
+
+// Schema returns the JSON schema of the config for editors and other tools.
+func Schema() ([]byte, error) {
+	data, err := yaml.YAMLToJSON(schemaByte)
+	if err != nil {
+		return nil, fmt.Errorf("error converting schema to JSON: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,353 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"sigs.k8s.io/yaml"
+	yamlv3 "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Problem is an error or warning found by Validate with the position in the YAML of the value it
+// is about, if it is known.
+type Problem struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	// Path is the path of the value in the config, such as flows.main.steps.0
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var buf strings.Builder
+	if p.File != "" {
+		buf.WriteString(p.File)
+		if p.Line > 0 {
+			fmt.Fprintf(&buf, ":%d:%d", p.Line, p.Column)
+		}
+		buf.WriteString(": ")
+	}
+	return buf.String() + p.Severity + ": " + p.Message
+}
+
+// Validate checks the config against the schema and the rules Load enforces, resolving extends and
+// the profiles, and lints it for references to unknown steps and fields, unused agents, flows, MCP
+// servers and env, and entrypoints that can not be reached. All declared profiles are validated,
+// the profiles of the options are merged in for the remaining checks. An error is returned if the
+// config can not be read.
+func Validate(ctx context.Context, path string, opts ...Options) ([]Problem, error) {
+	opt := complete.Complete(opts...)
+
+	configResource, err := resolve(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving config path %s: %w", path, err)
+	}
+
+	main, err := readSource(ctx, configResource)
+	if err != nil {
+		return nil, err
+	}
+	v := validator{sources: []*source{main}}
+	v.schema(main)
+
+	// An absolute extends is reported by validating the config
+	if extends := main.extends; extends != "" && !strings.HasPrefix(strings.TrimSpace(extends), "/") {
+		parentResource, err := configResource.Rel(extends)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving extends %s: %w", extends, err)
+		}
+		parent, err := readSource(ctx, parentResource)
+		if err != nil {
+			return nil, fmt.Errorf("error loading parent config %s: %w", parentResource.url, err)
+		}
+		v.sources = append(v.sources, parent)
+		v.schema(parent)
+	}
+	if len(v.problems) > 0 {
+		// The config can not be resolved if it does not match the schema
+		return v.problems, nil
+	}
+
+	for _, profile := range opt.Profiles {
+		name, _, _ := strings.Cut(profile, "?")
+		v.profiles = append([]string{name}, v.profiles...)
+	}
+
+	cfg, err := resolveConfig(ctx, configResource, Options{Profiles: opt.Profiles}, nil)
+	if err != nil {
+		v.add(SeverityError, nil, err.Error())
+		return v.problems, nil
+	}
+
+	allowLocal := configResource.resourceType == "path"
+	v.errors(cfg.Validate(allowLocal), nil, "")
+
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		if slices.Contains(v.profiles, name) {
+			continue
+		}
+		merged, err := merge(cfg, cfg.Profiles[name])
+		if err != nil {
+			v.add(SeverityError, []string{"profiles", name}, fmt.Sprintf("error merging profile %s: %v", name, err))
+			continue
+		}
+		v.errors(merged.Validate(allowLocal), []string{"profiles", name}, fmt.Sprintf("profile %s: ", name))
+	}
+
+	for _, d := range cfg.Analyze(types.AnalyzeOptions{Env: opt.Env}) {
+		v.add(SeverityWarning, diagnosticPath(d), d.String())
+	}
+
+	for _, w := range cfg.Lint() {
+		v.add(SeverityWarning, w.Path, w.Message)
+	}
+
+	return v.problems, nil
+}
+
+type source struct {
+	file    string
+	root    *yamlv3.Node
+	obj     map[string]any
+	extends string
+}
+
+func readSource(ctx context.Context, r *resource) (*source, error) {
+	data, err := r.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading resource %s: %w", r.url, err)
+	}
+
+	s := &source{
+		file: r.String(),
+		obj:  map[string]any{},
+	}
+	if r.resourceType == "path" {
+		if file, err := r.fileToRead(); err == nil {
+			s.file = file
+		}
+	}
+
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", s.file, err)
+	}
+	s.root = &root
+	if err := yaml.Unmarshal(data, &s.obj); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", s.file, err)
+	}
+	s.extends, _ = s.obj["extends"].(string)
+	return s, nil
+}
+
+// find returns the position of the path, or of the closest parent in the file, and the number of
+// elements of the path that were found.
+func (s *source) find(path []string) (pos *yamlv3.Node, found int) {
+	node := s.root
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	pos = node
+	for _, key := range path {
+		keyNode, value := child(node, key)
+		if value == nil && node.Kind == yamlv3.MappingNode {
+			if _, err := strconv.Atoi(key); err == nil {
+				// A list of steps that may also be written as an object with steps
+				if _, steps := child(node, "steps"); steps != nil {
+					keyNode, value = child(steps, key)
+				}
+			}
+		}
+		if value == nil {
+			break
+		}
+		pos, node = keyNode, value
+		found++
+	}
+	return pos, found
+}
+
+// child returns the key and value of a mapping, so positions point to the name of a value, or
+// the item of a sequence.
+func child(node *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i], node.Content[i+1]
+			}
+		}
+	case yamlv3.SequenceNode:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i], node.Content[i]
+		}
+	}
+	return nil, nil
+}
+
+type validator struct {
+	sources  []*source
+	profiles []string
+	problems []Problem
+	seen     map[string]bool
+}
+
+// add adds a problem at the position of the path in the selected profiles, the config or the
+// config it extends, whichever has most of the path.
+func (v *validator) add(severity string, path []string, message string) {
+	key := severity + ": " + message
+	if v.seen[key] {
+		return
+	}
+	if v.seen == nil {
+		v.seen = map[string]bool{}
+	}
+	v.seen[key] = true
+
+	problem := Problem{
+		Severity: severity,
+		File:     v.sources[0].file,
+		Path:     strings.Join(path, "."),
+		Message:  message,
+	}
+
+	var candidates [][]string
+	for _, profile := range v.profiles {
+		candidates = append(candidates, append([]string{"profiles", profile}, path...))
+	}
+	candidates = append(candidates, path)
+
+	best := 0
+	for _, src := range v.sources {
+		for i, candidate := range candidates {
+			pos, found := src.find(candidate)
+			if i < len(v.profiles) {
+				// Finding the profile itself does not mean the value is set in it
+				found -= 2
+			}
+			if found > best {
+				best = found
+				problem.File = src.file
+				problem.Line = pos.Line
+				problem.Column = pos.Column
+			}
+		}
+	}
+	v.problems = append(v.problems, problem)
+}
+
+// schema adds the problems of the source not matching the schema.
+func (v *validator) schema(src *source) {
+	err := getSchema().Validate(src.obj)
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		if err != nil {
+			v.problems = append(v.problems, Problem{Severity: SeverityError, File: src.file, Message: err.Error()})
+		}
+		return
+	}
+
+	sources := v.sources
+	v.sources = []*source{src}
+	defer func() {
+		v.sources = sources
+	}()
+	v.schemaErrors(ve)
+}
+
+func (v *validator) schemaErrors(ve *jsonschema.ValidationError) {
+	if len(ve.Causes) == 0 {
+		path := ve.InstanceLocation
+		if additional, ok := ve.ErrorKind.(*kind.AdditionalProperties); ok && len(additional.Properties) == 1 {
+			path = append(slices.Clip(path), additional.Properties[0])
+		}
+		v.add(SeverityError, path, schemaMessage(ve))
+		return
+	}
+
+	var missing []string
+	for _, cause := range ve.Causes {
+		required, ok := cause.ErrorKind.(*kind.Required)
+		if !ok || len(cause.Causes) > 0 || !slices.Equal(cause.InstanceLocation, ve.InstanceLocation) {
+			missing = nil
+			break
+		}
+		missing = append(missing, required.Missing...)
+	}
+	if len(missing) > 0 {
+		v.add(SeverityError, ve.InstanceLocation, fmt.Sprintf("missing one of the properties %s", strings.Join(missing, ", ")))
+		return
+	}
+
+	for _, cause := range ve.Causes {
+		v.schemaErrors(cause)
+	}
+}
+
+// schemaMessage returns the message of an error without the location.
+func schemaMessage(ve *jsonschema.ValidationError) string {
+	msg := ve.Error()
+	if rest, ok := strings.CutPrefix(msg, "at '"); ok {
+		if _, after, ok := strings.Cut(rest, "': "); ok {
+			return after
+		}
+	}
+	return msg
+}
+
+// errors adds the errors of validating the config, using the paths of the errors for positions.
+func (v *validator) errors(err error, path []string, prefix string) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case *types.PathError:
+		v.errors(e.Err, slices.Concat(path, e.Path), prefix)
+		return
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			v.errors(err, path, prefix)
+		}
+		return
+	}
+
+	var pathErr *types.PathError
+	if inner := errors.Unwrap(err); inner != nil && errors.As(inner, &pathErr) {
+		// The message of a nested error includes the context of the errors wrapping it
+		v.errors(inner, path, prefix)
+		return
+	}
+	if prefix != "" && v.seen[SeverityError+": "+err.Error()] {
+		// Not specific to the profile
+		return
+	}
+	v.add(SeverityError, path, prefix+err.Error())
+}
+
+var labelPart = regexp.MustCompile(`[^.\[\]]+`)
+
+// diagnosticPath returns the path of a diagnostic of a flow, whose step is a label such as
+// steps[0].steps[1] (id) and field a path such as input.name.
+func diagnosticPath(d types.Diagnostic) []string {
+	path := []string{"flows", d.Flow}
+	label, _, _ := strings.Cut(d.Step, " (")
+	path = append(path, labelPart.FindAllString(label, -1)...)
+	return append(path, labelPart.FindAllString(d.Field, -1)...)
+}
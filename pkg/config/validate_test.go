@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		profiles []string
+		expected []Problem
+	}{
+		{
+			name: "valid",
+			files: map[string]string{"nanobot.yaml": `
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+`},
+		},
+		{
+			name: "schema",
+			files: map[string]string{"nanobot.yaml": `
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+    bogus: 1
+`},
+			expected: []Problem{
+				{Severity: SeverityError, File: "nanobot.yaml", Line: 7, Column: 5, Path: "agents.main.bogus", Message: "additional properties 'bogus' not allowed"},
+			},
+		},
+		{
+			name: "schema of extended config",
+			files: map[string]string{
+				"nanobot.yaml": `
+extends: ./parent.yaml
+publish:
+  entrypoint: main
+`,
+				"parent.yaml": `
+agents:
+  main:
+    model: 1
+`,
+			},
+			expected: []Problem{
+				{Severity: SeverityError, File: "parent.yaml", Line: 4, Column: 5, Path: "agents.main.model", Message: "got number, want string"},
+			},
+		},
+		{
+			name: "lint and analyze",
+			files: map[string]string{"nanobot.yaml": `
+publish:
+  entrypoint: mian
+env:
+  TOKEN: {}
+  UNUSED: {}
+agents:
+  main:
+    model: gpt-4.1
+    instructions: hi ${TOKEN}
+  helper:
+    model: gpt-4.1
+flows:
+  run:
+    steps:
+      - id: a
+        script: return call("helper", {})
+      - id: b
+        agent:
+          name: main
+          toolChoice: search
+        input: ${a.outptu}
+`},
+			expected: []Problem{
+				{Severity: SeverityWarning, File: "nanobot.yaml", Line: 22, Column: 9, Path: "flows.run.steps.1.input", Message: `flow run steps[1] (b) input: a has no field "outptu", a step result has content, isError and output, did you mean "output"?`},
+				{Severity: SeverityWarning, File: "nanobot.yaml", Line: 14, Column: 3, Path: "flows.run", Message: `flow "run" is not used by publish, an agent, a flow or a trigger`},
+				{Severity: SeverityWarning, File: "nanobot.yaml", Line: 6, Column: 3, Path: "env.UNUSED", Message: `env "UNUSED" is not used by any value in the config`},
+				{Severity: SeverityWarning, File: "nanobot.yaml", Line: 3, Column: 3, Path: "publish.entrypoint", Message: `entrypoint "mian" can not be reached, it is not an agent, flow or MCP server, did you mean "main"?`},
+				{Severity: SeverityWarning, File: "nanobot.yaml", Line: 21, Column: 11, Path: "flows.run.steps.1.agent.toolChoice", Message: `tool choice "search" is not a tool of agent "main", it must be none, auto or one of its tools`},
+			},
+		},
+		{
+			name: "profiles",
+			files: map[string]string{"nanobot.yaml": `
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+profiles:
+  broken:
+    agents:
+      main:
+        toolChoice: x
+`},
+			expected: []Problem{
+				{Severity: SeverityError, File: "nanobot.yaml", Line: 10, Column: 7, Path: "profiles.broken.agents.main", Message: `profile broken: agent "main" has tool choice "x" that is not defined in tools`},
+			},
+		},
+		{
+			name:     "selected profile",
+			profiles: []string{"broken"},
+			files: map[string]string{"nanobot.yaml": `
+publish:
+  entrypoint: main
+agents:
+  main:
+    model: gpt-4.1
+profiles:
+  broken:
+    agents:
+      main:
+        toolChoice: x
+`},
+			expected: []Problem{
+				{Severity: SeverityError, File: "nanobot.yaml", Line: 10, Column: 7, Path: "agents.main", Message: `agent "main" has tool choice "x" that is not defined in tools`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			problems, err := Validate(context.Background(), filepath.Join(dir, "nanobot.yaml"), Options{Profiles: tt.profiles})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range problems {
+				problems[i].File, _ = filepath.Rel(dir, problems[i].File)
+			}
+			if !reflect.DeepEqual(problems, tt.expected) {
+				got, _ := json.MarshalIndent(problems, "", "  ")
+				t.Errorf("unexpected problems:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestValidateUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Validate(context.Background(), filepath.Join(dir, "nanobot.yaml")); err == nil {
+		t.Error("expected an error for a missing config")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "nanobot.yaml"), []byte("agents: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Validate(context.Background(), filepath.Join(dir, "nanobot.yaml")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestSchemaExport(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := map[string]any{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("expected the schema to be JSON: %v", err)
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for _, key := range []string{"agents", "flows", "mcpServers", "publish", "triggers"} {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("expected the schema to have the property %s", key)
+		}
+	}
+}
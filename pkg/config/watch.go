@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// WatchInterval is how often Watch checks the files of the config for changes.
+var WatchInterval = time.Second
+
+// Watch loads the config when the config file, the configs it extends or the local source
+// directories of its MCP servers change, and calls onChange with the new config, or the error
+// loading it, until the context is done. Only configs read from the local file system can be
+// watched.
+func Watch(ctx context.Context, path string, opt Options, onChange func(cfg *types.Config, err error)) error {
+	configResource, err := resolve(path)
+	if err != nil {
+		return fmt.Errorf("error resolving config path %s: %w", path, err)
+	}
+	if configResource.resourceType != "path" {
+		return fmt.Errorf("can not watch %s, only local configs can be watched", path)
+	}
+
+	cfg, err := resolveConfig(ctx, configResource, opt, nil)
+	if err != nil {
+		return err
+	}
+	files, err := watchedFiles(configResource, cfg)
+	if err != nil {
+		return err
+	}
+	last := fingerprint(files)
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := fingerprint(files)
+		if current == last {
+			continue
+		}
+		// Wait for editors to finish writing before loading
+		time.Sleep(WatchInterval / 4)
+		last = fingerprint(files)
+
+		newCfg, _, err := loadResource(ctx, configResource, opt)
+		if err != nil {
+			onChange(nil, err)
+			continue
+		}
+		if newFiles, err := watchedFiles(configResource, *newCfg); err != nil {
+			log.Errorf(ctx, "failed to find the files of %s to watch: %v", path, err)
+		} else if !slices.Equal(newFiles, files) {
+			files = newFiles
+			last = fingerprint(files)
+		}
+		onChange(newCfg, nil)
+	}
+}
+
+// watchedFiles returns the config file, the local config it extends and the local source
+// directories of the MCP servers of the config.
+func watchedFiles(configResource *resource, cfg types.Config) ([]string, error) {
+	file, err := configResource.fileToRead()
+	if err != nil {
+		return nil, err
+	}
+	files := []string{file}
+	if lock, err := lockPath(configResource); err == nil {
+		files = append(files, lock)
+	}
+
+	if cfg.Extends != "" {
+		parentResource, err := configResource.Rel(cfg.Extends)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving extends %s: %w", cfg.Extends, err)
+		}
+		if parentResource.resourceType == "path" {
+			if parent, err := parentResource.fileToRead(); err == nil {
+				files = append(files, parent)
+			}
+		}
+	}
+
+	for _, server := range cfg.MCPServers {
+		if strings.HasPrefix(server.Source.Repo, "/") {
+			files = append(files, filepath.Join(server.Source.Repo, server.Source.SubPath))
+		}
+	}
+
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// fingerprint returns a summary of the size and modification time of the files and all files
+// in the directories, skipping hidden files and node_modules.
+func fingerprint(files []string) string {
+	var buf strings.Builder
+	for _, file := range files {
+		_ = filepath.WalkDir(file, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintf(&buf, "%s:missing\n", path)
+				return nil
+			}
+			if path != file && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(&buf, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return buf.String()
+}
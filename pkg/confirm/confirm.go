@@ -2,17 +2,21 @@ package confirm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
 const Timeout = 15 * time.Minute
 
+var errRejected = errors.New("rejected")
+
 type Service struct {
 	cond     sync.Cond
 	requests map[string]request
@@ -29,6 +33,7 @@ type request struct {
 	ID            string          `json:"id"`
 	RequestedTime time.Time       `json:"requestedTime"`
 	Accepted      *bool           `json:"-"`
+	Message       string          `json:"message,omitempty"`
 	MCPServer     string          `json:"mcpServer,omitempty"`
 	ToolName      string          `json:"toolName,omitempty"`
 	Tool          mcp.Tool        `json:"tool,omitempty"`
@@ -76,18 +81,37 @@ func (s *Service) Confirm(ctx context.Context, session *mcp.Session, target type
 		return nil
 	}
 
-	uid := uuid.String()
-	req := request{
-		ID:            uid,
+	return s.send(ctx, session, request{
+		ID:            uuid.String(),
 		RequestedTime: time.Now(),
 		MCPServer:     target.MCPServer,
 		ToolName:      target.TargetName,
 		Tool:          target.Target.(mcp.Tool),
 		Invocation:    funcCall,
+	})
+}
+
+// Approve asks the user to approve the message, returning false if the user rejected it. An error is
+// returned if the request timed out or ctx is done before the user answered.
+func (s *Service) Approve(ctx context.Context, session *mcp.Session, message string) (bool, error) {
+	if s == nil {
+		return false, fmt.Errorf("confirmations are not enabled")
 	}
 
+	err := s.send(ctx, session, request{
+		ID:            uuid.String(),
+		RequestedTime: time.Now(),
+		Message:       message,
+	})
+	if errors.Is(err, errRejected) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Service) send(ctx context.Context, session *mcp.Session, req request) error {
 	s.cond.L.Lock()
-	s.requests[uid] = req
+	s.requests[req.ID] = req
 	s.cond.L.Unlock()
 
 	for session.Parent != nil {
@@ -105,29 +129,32 @@ func (s *Service) Confirm(ctx context.Context, session *mcp.Session, target type
 		return fmt.Errorf("failed to send confirmation message: %w", err)
 	}
 
-	return s.waitAccepted(ctx, uid)
+	start := time.Now()
+	result, err := s.waitAccepted(ctx, req.ID)
+	metrics.ConfirmationWait.Observe(time.Since(start).Seconds(), result)
+	return err
 }
 
-func (s *Service) waitAccepted(ctx context.Context, id string) error {
+func (s *Service) waitAccepted(ctx context.Context, id string) (string, error) {
 	s.cond.L.Lock()
 	defer s.cond.L.Unlock()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return "canceled", ctx.Err()
 		default:
 		}
 
 		if req, ok := s.requests[id]; ok {
 			if req.Accepted != nil {
 				if *req.Accepted {
-					return nil
+					return "accepted", nil
 				}
-				return fmt.Errorf("request %s was rejected", id)
+				return "rejected", fmt.Errorf("request %s was %w", id, errRejected)
 			}
 		} else {
-			return fmt.Errorf("confirmation %s timed out", id)
+			return "timeout", fmt.Errorf("confirmation %s timed out", id)
 		}
 		s.cond.Wait()
 	}
@@ -0,0 +1,206 @@
+package cron
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record if the day fields started with "*", such as * or */2. Like
+	// standard cron, when both day fields are restricted a day matches if either field matches.
+	domStar, dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also accepted as Sunday
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Parse parses a standard five field cron expression (minute, hour, day of month, month, day of
+// week) or one of the descriptors @yearly, @monthly, @weekly, @daily and @hourly.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("unknown cron descriptor %q", spec)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var (
+		s    Schedule
+		errs []string
+	)
+	for i, target := range []struct {
+		f    field
+		bits *uint64
+	}{
+		{minuteField, &s.minute},
+		{hourField, &s.hour},
+		{domField, &s.dom},
+		{monthField, &s.month},
+		{dowField, &s.dow},
+	} {
+		value, err := parseField(fields[i], target.f)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		*target.bits = value
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", spec, strings.Join(errs, ", "))
+	}
+
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[2], "?")
+	s.dowStar = strings.HasPrefix(fields[4], "*") || strings.HasPrefix(fields[4], "?")
+	return &s, nil
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var result uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, stepPart)
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			lowPart, highPart, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowPart); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(highPart); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rangePart)
+			}
+		default:
+			var err error
+			if low, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			if !hasStep {
+				high = low
+			}
+		}
+
+		for i := low; i <= high; i += step {
+			result |= 1 << i
+		}
+	}
+	return result, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be between %d and %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t that matches the schedule, in the location of t. It returns
+// the zero time if there is no match in the next five years, for example for February 30th.
+// Times skipped by a daylight saving time change do not match, and times that occur twice only
+// match the first time.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = startOf(t, t.Year(), t.Month()+1, 1, 0)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = startOf(t, t.Year(), t.Month(), t.Day()+1, 0)
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = startOf(t, t.Year(), t.Month(), t.Day(), t.Hour()+1)
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			// Jump straight to the next matching minute of this hour, if any
+			if next := s.minute >> t.Minute(); next != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)) * time.Minute)
+			} else {
+				t = startOf(t, t.Year(), t.Month(), t.Day(), t.Hour()+1)
+			}
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// startOf returns the start of the given hour in the location of t. If that hour was skipped by a
+// daylight saving time change, time.Date normalizes it to a time that is not after t, and the start
+// of the hour after t is returned instead so Next always moves forward.
+func startOf(t time.Time, year int, month time.Month, day, hour int) time.Time {
+	next := time.Date(year, month, day, hour, 0, 0, 0, t.Location())
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,138 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	}
+	for _, spec := range invalid {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Thursday
+	from := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{spec: "* * * * *", from: from, expected: date(1, 1, 10, 31)},
+		{spec: "* * * * *", from: from.Add(59 * time.Second), expected: date(1, 1, 10, 31)},
+		{spec: "30 10 * * *", from: from, expected: date(1, 2, 10, 30)},
+		{spec: "*/20 * * * *", from: from, expected: date(1, 1, 10, 40)},
+		{spec: "10-15/5 * * * *", from: from, expected: date(1, 1, 11, 10)},
+		{spec: "0 9-17 * * *", from: from, expected: date(1, 1, 11, 0)},
+		{spec: "0 8,20 * * *", from: from, expected: date(1, 1, 20, 0)},
+		{spec: "0 0 1 * *", from: from, expected: date(2, 1, 0, 0)},
+		{spec: "0 0 * mar *", from: from, expected: date(3, 1, 0, 0)},
+		{spec: "0 0 * * MON-FRI", from: date(1, 2, 12, 0), expected: date(1, 5, 0, 0)},
+		{spec: "0 0 * * sun", from: from, expected: date(1, 4, 0, 0)},
+		{spec: "0 0 * * 7", from: from, expected: date(1, 4, 0, 0)},
+		{spec: "0 0 * * 0", from: from, expected: date(1, 4, 0, 0)},
+		// When both day fields are restricted either one matches: the 10th or a Sunday
+		{spec: "0 0 10 * 0", from: from, expected: date(1, 4, 0, 0)},
+		{spec: "0 0 10 * 0", from: date(1, 5, 0, 0), expected: date(1, 10, 0, 0)},
+		// When one of them is * only the other one has to match
+		{spec: "0 0 10 * *", from: from, expected: date(1, 10, 0, 0)},
+		{spec: "0 0 ? * 0", from: from, expected: date(1, 4, 0, 0)},
+		// A step of * is not a restriction either, so both have to match: an odd day and a Monday,
+		// or the 1st on a Sunday, Tuesday, Thursday or Saturday
+		{spec: "0 0 */2 * 1", from: date(1, 6, 0, 0), expected: date(1, 19, 0, 0)},
+		{spec: "0 0 1 * */2", from: from, expected: date(2, 1, 0, 0)},
+		{spec: "0 0 29 2 *", from: from, expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", from: from, expected: date(2, 1, 0, 0)},
+		{spec: "@weekly", from: from, expected: date(1, 4, 0, 0)},
+		{spec: "@hourly", from: from, expected: date(1, 1, 11, 0)},
+		{spec: "0 0 30 2 *", from: from},
+		{spec: "0 0 31 4,6,9,11 *", from: from},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.expected) {
+				t.Errorf("expected %s after %s, got %s", tt.expected, tt.from, got)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name     string
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		// Clocks go from 2:00 to 3:00 on March 8th, 2:30 does not exist that day
+		{name: "skipped time", spec: "30 2 * * *", from: date(3, 7, 12, 0), expected: date(3, 9, 2, 30)},
+		{name: "after the gap", spec: "*/15 * * * *", from: date(3, 8, 1, 50), expected: date(3, 8, 3, 0)},
+		{name: "daily across the gap", spec: "0 9 * * *", from: date(3, 7, 9, 0), expected: date(3, 8, 9, 0)},
+		// Clocks go from 2:00 back to 1:00 on November 1st, 1:30 only runs once
+		{name: "repeated time", spec: "30 1 * * *", from: date(11, 1, 1, 45), expected: date(11, 2, 1, 30)},
+		{name: "daily across the overlap", spec: "0 9 * * *", from: date(10, 31, 9, 0), expected: date(11, 1, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(tt.from)
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %s after %s, got %s", tt.expected, tt.from, got)
+			}
+			if got.Location() != loc {
+				t.Errorf("expected the location of the start time, got %s", got.Location())
+			}
+		})
+	}
+
+	// In Santiago clocks go from midnight to 1:00 on September 6th, so the day starts at 1:00
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse("0 12 6 9 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2026, 9, 6, 12, 0, 0, 0, santiago)
+	if got := s.Next(time.Date(2026, 9, 5, 13, 0, 0, 0, santiago)); !got.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,281 @@
+package envvar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	dotenvKey  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	// dotenvPlain matches values that can be written without quotes
+	dotenvPlain = regexp.MustCompile(`^[A-Za-z0-9_./:@,+=%-]*$`)
+)
+
+// ParseDotenv parses a .env file. Lines are KEY=VALUE, optionally prefixed with export, and # starts
+// a comment at the start of a line or after whitespace in an unquoted value. Single quoted values
+// are literal, double quoted values support \n, \r, \t, \", \\ and \$ escapes, and both can span
+// multiple lines. $VAR, ${VAR}, ${VAR:-default} and ${VAR-default} in unquoted and double quoted
+// values are expanded from the variables defined earlier in the file and then lookup. Other ${...}
+// placeholders, such as secret references, are left as is.
+func ParseDotenv(data []byte, lookup func(string) (string, bool)) (map[string]string, error) {
+	p := &dotenvParser{
+		src:    strings.ReplaceAll(string(data), "\r\n", "\n"),
+		values: map[string]string{},
+		lookup: lookup,
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.values, nil
+}
+
+// QuoteDotenv returns the value as it is written in a .env file, so that it parses back to the
+// same value.
+func QuoteDotenv(value string) string {
+	if dotenvPlain.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`).Replace(value) + `"`
+}
+
+type dotenvParser struct {
+	src    string
+	pos    int
+	values map[string]string
+	lookup func(string) (string, bool)
+}
+
+func (p *dotenvParser) errorf(pos int, format string, args ...any) error {
+	line := strings.Count(p.src[:pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) skipBlank() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		p.pos += i + 1
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *dotenvParser) parse() error {
+	for {
+		// Skip blank lines and comments
+		for !p.eof() {
+			p.skipBlank()
+			if p.eof() || (p.src[p.pos] != '\n' && p.src[p.pos] != '#') {
+				break
+			}
+			p.skipLine()
+		}
+		if p.eof() {
+			return nil
+		}
+
+		start := p.pos
+		if rest, ok := strings.CutPrefix(p.src[p.pos:], "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			p.pos += len("export")
+			p.skipBlank()
+		}
+
+		keyStart := p.pos
+		for !p.eof() && !strings.ContainsRune("= \t\n", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		key := p.src[keyStart:p.pos]
+		if !dotenvKey.MatchString(key) {
+			return p.errorf(start, "invalid variable name %q", key)
+		}
+
+		p.skipBlank()
+		if p.eof() || p.src[p.pos] != '=' {
+			return p.errorf(start, "expected = after %s", key)
+		}
+		p.pos++
+		p.skipBlank()
+
+		value, err := p.value()
+		if err != nil {
+			return err
+		}
+		p.values[key] = value
+	}
+}
+
+func (p *dotenvParser) value() (string, error) {
+	if p.eof() {
+		return "", nil
+	}
+
+	start := p.pos
+	switch quote := p.src[p.pos]; quote {
+	case '\'', '"':
+		end := p.pos + 1
+		for ; end < len(p.src) && p.src[end] != quote; end++ {
+			if quote == '"' && p.src[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(p.src) {
+			return "", p.errorf(start, "missing closing %c", quote)
+		}
+		raw := p.src[p.pos+1 : end]
+		p.pos = end + 1
+
+		// Only a comment can follow the closing quote
+		p.skipBlank()
+		if !p.eof() && p.src[p.pos] != '\n' && p.src[p.pos] != '#' {
+			return "", p.errorf(p.pos, "unexpected %q after closing %c", p.src[p.pos], quote)
+		}
+		p.skipLine()
+
+		if quote == '\'' {
+			return raw, nil
+		}
+		return p.expand(raw, true), nil
+	default:
+		end := strings.IndexByte(p.src[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.src) - p.pos
+		}
+		raw := p.src[p.pos : p.pos+end]
+		p.skipLine()
+
+		for i := 0; i < len(raw); i++ {
+			if raw[i] == '#' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t') {
+				raw = raw[:i]
+				break
+			}
+		}
+		return p.expand(strings.TrimSpace(raw), false), nil
+	}
+}
+
+// expand replaces the escapes of double quoted values and the variables in the value.
+func (p *dotenvParser) expand(s string, doubleQuoted bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			switch {
+			case next == '$':
+				buf.WriteByte('$')
+				i++
+				continue
+			case doubleQuoted && next == 'n':
+				buf.WriteByte('\n')
+				i++
+				continue
+			case doubleQuoted && next == 'r':
+				buf.WriteByte('\r')
+				i++
+				continue
+			case doubleQuoted && next == 't':
+				buf.WriteByte('\t')
+				i++
+				continue
+			case doubleQuoted && (next == '"' || next == '\\'):
+				buf.WriteByte(next)
+				i++
+				continue
+			}
+		}
+		if c != '$' {
+			buf.WriteByte(c)
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '{' {
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				buf.WriteString(s[i:])
+				break
+			}
+			if value, ok := p.placeholder(s[i+2 : end]); ok {
+				buf.WriteString(value)
+			} else {
+				buf.WriteString(s[i : end+1])
+			}
+			i = end
+			continue
+		}
+
+		if name := dotenvName.FindString(s[i+1:]); name != "" {
+			value, _ := p.get(name)
+			buf.WriteString(value)
+			i += len(name)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// placeholder returns the value of VAR, VAR:-default or VAR-default, or false if the placeholder
+// is not a variable.
+func (p *dotenvParser) placeholder(inner string) (string, bool) {
+	name := dotenvName.FindString(inner)
+	if name == "" {
+		return "", false
+	}
+	rest := inner[len(name):]
+	value, ok := p.get(name)
+	switch {
+	case rest == "":
+		return value, true
+	case strings.HasPrefix(rest, ":-"):
+		if value == "" {
+			return p.expand(rest[2:], false), true
+		}
+		return value, true
+	case strings.HasPrefix(rest, "-"):
+		if !ok {
+			return p.expand(rest[1:], false), true
+		}
+		return value, true
+	}
+	return "", false
+}
+
+func (p *dotenvParser) get(name string) (string, bool) {
+	if value, ok := p.values[name]; ok {
+		return value, true
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+	return "", false
+}
+
+// matchingBrace returns the index of the } that closes the { at start, or -1.
+func matchingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,125 @@
+package envvar
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "HOME" {
+			return "/home/user", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "plain",
+			input: "A=1\n\n# comment\n  B = two words  \nC=\n",
+			want:  map[string]string{"A": "1", "B": "two words", "C": ""},
+		},
+		{
+			name:  "export",
+			input: "export A=1\nexport\tB=2\nexport=3",
+			want:  map[string]string{"A": "1", "B": "2", "export": "3"},
+		},
+		{
+			name:  "inline comments",
+			input: "A=value # comment\nB=value#not-a-comment\nC='quoted # value' # comment\nD=\"x\" #c\nE=#empty",
+			want:  map[string]string{"A": "value", "B": "value#not-a-comment", "C": "quoted # value", "D": "x", "E": ""},
+		},
+		{
+			name:  "single quotes are literal",
+			input: `A='$HOME \n ${HOME}'`,
+			want:  map[string]string{"A": `$HOME \n ${HOME}`},
+		},
+		{
+			name:  "double quote escapes",
+			input: `A="line1\nline2\ttab \"quoted\" back\\slash \$HOME \x"`,
+			want:  map[string]string{"A": "line1\nline2\ttab \"quoted\" back\\slash $HOME \\x"},
+		},
+		{
+			name:  "multi-line values",
+			input: "A=\"first\nsecond\"\nB='one\ntwo'\nC=3",
+			want:  map[string]string{"A": "first\nsecond", "B": "one\ntwo", "C": "3"},
+		},
+		{
+			name:  "expansion",
+			input: "A=a\nB=${A}b\nC=\"$A-$B-${HOME}\"\nD=$MISSING.\nE=${MISSING:-default}\nF=${A:-default}\nG=${MISSING-x}\nH=\nI=${H:-empty}${H-unset}",
+			want: map[string]string{
+				"A": "a", "B": "ab", "C": "a-ab-/home/user", "D": ".", "E": "default", "F": "a", "G": "x", "H": "",
+				"I": "empty",
+			},
+		},
+		{
+			name:  "nested default",
+			input: "A=${MISSING:-${HOME}/x}",
+			want:  map[string]string{"A": "/home/user/x"},
+		},
+		{
+			name:  "other placeholders are kept",
+			input: "A=${secret:file:/run/secrets/token}\nB=\"${secret:cmd:pass show x}\"\nC=${input.name}\nD=\\$HOME",
+			want: map[string]string{
+				"A": "${secret:file:/run/secrets/token}",
+				"B": "${secret:cmd:pass show x}",
+				"C": "${input.name}",
+				"D": "$HOME",
+			},
+		},
+		{
+			name:  "windows line endings",
+			input: "A=1\r\nB=\"2\"\r\n",
+			want:  map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:  "later values override",
+			input: "A=1\nA=2",
+			want:  map[string]string{"A": "2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv([]byte(tt.input), lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := map[string]string{
+		"A=1\nB\n":           "line 2: expected = after B",
+		"A=1\n1A=2":          `line 2: invalid variable name "1A"`,
+		"A=\"unterminated\n": `line 1: missing closing "`,
+		"A='x' y":            `line 1: unexpected 'y' after closing '`,
+	}
+	for input, want := range tests {
+		_, err := ParseDotenv([]byte(input), nil)
+		if err == nil || err.Error() != want {
+			t.Errorf("parsing %q: got error %v, want %s", input, err, want)
+		}
+	}
+}
+
+func TestQuoteDotenv(t *testing.T) {
+	for _, value := range []string{"", "plain", "https://example.com/x?a=b", "two words", "a # b", "$HOME", "it's", "multi\nline \"$x\" \\", "${secret:vault:x}"} {
+		got, err := ParseDotenv([]byte("A="+QuoteDotenv(value)), func(string) (string, bool) {
+			return "expanded", true
+		})
+		if err != nil {
+			t.Fatalf("failed to parse quoted %q: %v", value, err)
+		}
+		if got["A"] != value {
+			t.Errorf("got %q, want %q", got["A"], value)
+		}
+	}
+}
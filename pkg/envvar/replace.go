@@ -9,6 +9,7 @@ import (
 
 	"github.com/nanobot-ai/nanobot/pkg/expr"
 	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 func ReplaceString(envs map[string]string, str string) string {
@@ -31,9 +32,15 @@ func ReplaceMap(envs map[string]string, m map[string]string) map[string]string {
 func ReplaceEnv(envs map[string]string, command string, args []string, env map[string]string) (string, []string, []string) {
 	newEnvMap := make(map[string]string, len(envs)+len(env))
 	for k, v := range envs {
-		if !strings.Contains(k, ":") {
-			newEnvMap[k] = v
+		if strings.Contains(k, ":") {
+			continue
 		}
+		v, err := secrets.Expand(context.TODO(), v)
+		if err != nil {
+			log.Errorf(context.TODO(), "not passing env var %s: %v", k, err)
+			continue
+		}
+		newEnvMap[k] = v
 	}
 
 	maps.Copy(newEnvMap, ReplaceMap(envs, env))
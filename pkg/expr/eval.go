@@ -2,11 +2,13 @@ package expr
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/dop251/goja"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 func EvalString(ctx context.Context, env map[string]string, expr string) (string, error) {
@@ -79,45 +81,36 @@ func translate(ctx context.Context, env map[string]string, data map[string]any,
 			}
 			result[key] = res
 		}
+		return result, nil
 	case string:
 		return evalString(ctx, env, data, expr)
 	}
 	return expr, nil
 }
 
-func newRuntime(data map[string]any) (*goja.Runtime, error) {
-	runtime := goja.New()
-	for key, value := range data {
-		if err := runtime.Set(key, value); err != nil {
-			return nil, fmt.Errorf("failed to set variable %s: %w", key, err)
-		}
-	}
-	return runtime, nil
-}
+var errUndefined = errors.New("undefined")
 
-func evalString(_ context.Context, env map[string]string, data map[string]any, expr string) (any, error) {
+func evalString(ctx context.Context, env map[string]string, data map[string]any, expr string) (any, error) {
 	if strings.HasPrefix(expr, "${") && strings.HasSuffix(expr, "}") {
+		if secrets.IsName(expr[2 : len(expr)-1]) {
+			return secrets.Resolve(ctx, expr[2:len(expr)-1])
+		}
 		envVal, ok := Lookup(env, expr[2:len(expr)-1])
 		if ok {
-			return envVal, nil
-		}
-		runtime, err := newRuntime(data)
-		if err != nil {
-			return nil, err
-		}
-		val, err := runtime.RunString(expr[2 : len(expr)-1])
-		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate expression %s: %w", expr, err)
+			return secrets.Expand(ctx, envVal)
 		}
-		if val.String() == "undefined" {
+		val, err := run(ctx, data, expr[2:len(expr)-1], func(val goja.Value) (any, error) {
+			if val.String() == "undefined" {
+				return nil, errUndefined
+			}
+			return val.Export(), nil
+		})
+		if errors.Is(err, errUndefined) {
 			return nil, fmt.Errorf("expression resulted in a javascript undefined, check for a missing reference in expression %q", expr)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to evaluate expression %s: %w", expr, err)
 		}
-		return val.Export(), nil
-	}
-
-	runtime, err := newRuntime(data)
-	if err != nil {
-		return nil, err
+		return val, nil
 	}
 
 	var lastErr error
@@ -125,16 +118,25 @@ func evalString(_ context.Context, env map[string]string, data map[string]any, e
 		if lastErr != nil {
 			return name
 		}
+		if secrets.IsName(name) {
+			val, err := secrets.Resolve(ctx, name)
+			lastErr = err
+			return val
+		}
 		envVal, ok := Lookup(env, name)
 		if ok {
-			return envVal
+			val, err := secrets.Expand(ctx, envVal)
+			lastErr = err
+			return val
 		}
-		val, err := runtime.RunString(name)
+		val, err := run(ctx, data, name, func(val goja.Value) (string, error) {
+			return val.ToString().String(), nil
+		})
 		if err != nil {
 			lastErr = err
 			return ""
 		}
-		return val.ToString().String()
+		return val
 	}), lastErr
 }
 
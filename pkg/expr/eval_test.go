@@ -0,0 +1,122 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+// forEachData is the data of a flow in a forEach loop over many items after a few steps ran.
+func forEachData(items int) map[string]any {
+	list := make([]any, items)
+	for i := range list {
+		list[i] = map[string]any{
+			"name":  fmt.Sprintf("item-%d", i),
+			"count": i,
+			"tags":  []any{"a", "b", "c"},
+		}
+	}
+	return map[string]any{
+		"id":    "run",
+		"flow":  "bench",
+		"input": map[string]any{"items": list},
+		"fetch": map[string]any{"output": list, "isError": false},
+		"previous": map[string]any{
+			"output": list,
+		},
+	}
+}
+
+var forEachInput = map[string]any{
+	"name":    "${item.name}",
+	"summary": "Item ${item.name} has ${item.count} of ${fetch.output.length}",
+	"double":  "${item.count * 2}",
+	"tags":    "${item.tags.filter(t => t !== 'b').join(',')}",
+}
+
+// evalFresh evaluates the way expressions were evaluated before runtimes were pooled, with a new
+// runtime and no compiled program cache for every evaluation.
+func evalFresh(data map[string]any, src string) (any, error) {
+	vm := goja.New()
+	for key, value := range data {
+		if err := vm.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	val, err := vm.RunString(src)
+	if err != nil {
+		return nil, err
+	}
+	return val.Export(), nil
+}
+
+func BenchmarkEval(b *testing.B) {
+	data := forEachData(100)
+	data["item"] = data["fetch"].(map[string]any)["output"].([]any)[42]
+	src := "item.tags.filter(t => t !== 'b').join(',')"
+
+	b.Run("fresh", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := evalFresh(data, src); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		ctx := context.Background()
+		for b.Loop() {
+			if _, err := EvalAny(ctx, nil, data, "${"+src+"}"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkForEach evaluates the input of a step for every item of a large forEach loop.
+func BenchmarkForEach(b *testing.B) {
+	const items = 1000
+	data := forEachData(items)
+	list := data["fetch"].(map[string]any)["output"].([]any)
+
+	b.Run("fresh", func(b *testing.B) {
+		for b.Loop() {
+			for _, item := range list {
+				data["item"] = item
+				for _, value := range forEachInput {
+					for _, src := range Expressions(value.(string)) {
+						if _, err := evalFresh(data, src); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		ctx := context.Background()
+		for b.Loop() {
+			for _, item := range list {
+				data["item"] = item
+				if _, err := EvalAny(ctx, nil, data, forEachInput); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("pooled parallel", func(b *testing.B) {
+		ctx := context.Background()
+		b.RunParallel(func(pb *testing.PB) {
+			local := forEachData(items)
+			i := 0
+			for pb.Next() {
+				local["item"] = list[i%items]
+				if _, err := EvalAny(ctx, nil, local, forEachInput); err != nil {
+					b.Fatal(err)
+				}
+				i++
+			}
+		})
+	})
+}
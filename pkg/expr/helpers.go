@@ -0,0 +1,605 @@
+package expr
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// HelpersName is the global variable the helper library is available as in expressions.
+const HelpersName = "nb"
+
+// Helper is a function of the helper library.
+type Helper struct {
+	// Name is the path of the helper below nb, such as jsonpath.get
+	Name        string
+	Usage       string
+	Description string
+	fn          any
+}
+
+var helpers = []Helper{
+	{
+		Name:        "jsonpath.query",
+		Usage:       "nb.jsonpath.query(value, path)",
+		Description: "Returns the list of values matching a JSONPath such as $.items[*].name, $..id, $.items[-1] or $.items[?(@.status == 'open')].",
+		fn:          jsonPathQuery,
+	},
+	{
+		Name:        "jsonpath.get",
+		Usage:       "nb.jsonpath.get(value, path, fallback)",
+		Description: "Returns the first value matching a JSONPath, or fallback (null if not given) if nothing matches.",
+		fn:          jsonPathGet,
+	},
+	{
+		Name:        "text.template",
+		Usage:       "nb.text.template(template, values)",
+		Description: "Replaces the {{name}} placeholders in template with values. Names can be paths such as {{user.name}} or {{items[0]}}, missing values are empty.",
+		fn:          textTemplate,
+	},
+	{
+		Name:        "text.extract",
+		Usage:       "nb.text.extract(text, regexp)",
+		Description: "Returns the first match of a regular expression (RE2 syntax), or the first group if it has one, or null if it does not match.",
+		fn:          textExtract,
+	},
+	{
+		Name:        "text.extractAll",
+		Usage:       "nb.text.extractAll(text, regexp)",
+		Description: "Returns all matches of a regular expression, or of its first group if it has one.",
+		fn:          textExtractAll,
+	},
+	{
+		Name:        "text.truncate",
+		Usage:       "nb.text.truncate(text, length)",
+		Description: `Shortens text to at most length characters, ending in "..." if it was cut.`,
+		fn:          textTruncate,
+	},
+	{
+		Name:        "content.text",
+		Usage:       "nb.content.text(result)",
+		Description: "Returns the text of all text content of a step result, such as previous or a step ID, or of a list of content, joined by newlines.",
+		fn:          contentText,
+	},
+	{
+		Name:        "content.last",
+		Usage:       "nb.content.last(result)",
+		Description: "Returns the text of the last content with text of a step result or a list of content.",
+		fn:          contentLast,
+	},
+	{
+		Name:        "date.now",
+		Usage:       "nb.date.now()",
+		Description: "Returns the current time in UTC as RFC3339.",
+		fn:          dateNow,
+	},
+	{
+		Name:        "date.parse",
+		Usage:       "nb.date.parse(value)",
+		Description: "Parses an RFC3339 time, a date such as 2025-01-31, a Date or unix seconds and returns it as RFC3339.",
+		fn:          dateParse,
+	},
+	{
+		Name:        "date.format",
+		Usage:       "nb.date.format(value, layout, timezone)",
+		Description: "Formats a time with the tokens YYYY, YY, MMMM, MMM, MM, M, DD, D, dddd, ddd, HH, H, hh, h, mm, m, ss, s, SSS, A, a, Z and ZZ. Text in [brackets] is not replaced. The timezone is an optional IANA name such as Europe/Berlin.",
+		fn:          dateFormat,
+	},
+	{
+		Name:        "date.add",
+		Usage:       "nb.date.add(value, duration)",
+		Description: "Adds a duration such as 1h30m, -15m or 7d to a time and returns it as RFC3339.",
+		fn:          dateAdd,
+	},
+	{
+		Name:        "date.diff",
+		Usage:       "nb.date.diff(a, b)",
+		Description: "Returns the number of seconds from b to a.",
+		fn:          dateDiff,
+	},
+	{
+		Name:        "base64.encode",
+		Usage:       "nb.base64.encode(text)",
+		Description: "Encodes text as standard base64.",
+		fn:          base64Encode(base64.StdEncoding),
+	},
+	{
+		Name:        "base64.decode",
+		Usage:       "nb.base64.decode(text)",
+		Description: "Decodes standard base64, with or without padding.",
+		fn:          base64Decode(base64.StdEncoding),
+	},
+	{
+		Name:        "base64.urlEncode",
+		Usage:       "nb.base64.urlEncode(text)",
+		Description: "Encodes text as URL safe base64 without padding.",
+		fn:          base64Encode(base64.RawURLEncoding),
+	},
+	{
+		Name:        "base64.urlDecode",
+		Usage:       "nb.base64.urlDecode(text)",
+		Description: "Decodes URL safe base64, with or without padding.",
+		fn:          base64Decode(base64.RawURLEncoding),
+	},
+	{
+		Name:        "hash.sha256",
+		Usage:       "nb.hash.sha256(text)",
+		Description: "Returns the hex encoded SHA-256 hash of text.",
+		fn:          hashHex(sha256.New),
+	},
+	{
+		Name:        "hash.sha1",
+		Usage:       "nb.hash.sha1(text)",
+		Description: "Returns the hex encoded SHA-1 hash of text.",
+		fn:          hashHex(sha1.New),
+	},
+	{
+		Name:        "hash.md5",
+		Usage:       "nb.hash.md5(text)",
+		Description: "Returns the hex encoded MD5 hash of text.",
+		fn:          hashHex(md5.New),
+	},
+	{
+		Name:        "hash.hmac",
+		Usage:       "nb.hash.hmac(key, text, algorithm)",
+		Description: "Returns the hex encoded HMAC of text, the algorithm is sha256 (default), sha1 or sha512.",
+		fn:          hashHMAC,
+	},
+}
+
+// Helpers returns the functions of the helper library.
+func Helpers() []Helper {
+	return helpers
+}
+
+// registerHelpers adds the helper library to the runtime. It is frozen so an expression can not
+// change it for the expressions that later run in the same runtime.
+func registerHelpers(vm *goja.Runtime) error {
+	root := vm.NewObject()
+	objects := map[string]*goja.Object{}
+	for _, h := range helpers {
+		namespace, name, _ := strings.Cut(h.Name, ".")
+		obj, ok := objects[namespace]
+		if !ok {
+			obj = vm.NewObject()
+			objects[namespace] = obj
+			if err := root.Set(namespace, obj); err != nil {
+				return err
+			}
+		}
+		if err := obj.Set(name, h.fn); err != nil {
+			return err
+		}
+	}
+
+	freeze, ok := goja.AssertFunction(vm.Get("Object").ToObject(vm).Get("freeze"))
+	if !ok {
+		return errors.New("Object.freeze is not a function")
+	}
+	for _, obj := range objects {
+		if _, err := freeze(nil, obj); err != nil {
+			return err
+		}
+	}
+	if _, err := freeze(nil, root); err != nil {
+		return err
+	}
+	return vm.GlobalObject().DefineDataProperty(HelpersName, root, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+}
+
+// plain converts values that reach helpers as Go types, such as the content of step results, to
+// the maps, lists and primitives they are as JSON.
+func plain(v any) any {
+	switch v.(type) {
+	case nil, string, bool, float64, int64, map[string]any, []any:
+		if !hasGoTypes(v) {
+			return v
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return v
+	}
+	return result
+}
+
+func hasGoTypes(v any) bool {
+	switch v := v.(type) {
+	case nil, string, bool, float64, int64:
+		return false
+	case map[string]any:
+		for _, item := range v {
+			if hasGoTypes(item) {
+				return true
+			}
+		}
+		return false
+	case []any:
+		for _, item := range v {
+			if hasGoTypes(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+var (
+	jsonPathsLock sync.Mutex
+	jsonPaths     = map[string]jsonPath{}
+)
+
+func compileJSONPath(path string) (jsonPath, error) {
+	jsonPathsLock.Lock()
+	defer jsonPathsLock.Unlock()
+	if p, ok := jsonPaths[path]; ok {
+		return p, nil
+	}
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(jsonPaths) >= maxPrograms {
+		clear(jsonPaths)
+	}
+	jsonPaths[path] = p
+	return p, nil
+}
+
+func jsonPathQuery(value any, path string) ([]any, error) {
+	p, err := compileJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	result := p.query(plain(value))
+	if result == nil {
+		result = []any{}
+	}
+	return result, nil
+}
+
+func jsonPathGet(value any, path string, fallback any) (any, error) {
+	result, err := jsonPathQuery(value, path)
+	if err != nil || len(result) == 0 {
+		return fallback, err
+	}
+	return result[0], nil
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*}}`)
+
+func textTemplate(template string, values any) (string, error) {
+	var errs []error
+	result := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		path := placeholder.FindStringSubmatch(match)[1]
+		value, err := jsonPathGet(values, path, nil)
+		if err != nil {
+			errs = append(errs, err)
+			return ""
+		}
+		return toText(value)
+	})
+	return result, errors.Join(errs...)
+}
+
+func toText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64, bool:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+var (
+	regexpsLock sync.Mutex
+	regexps     = map[string]*regexp.Regexp{}
+)
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpsLock.Lock()
+	defer regexpsLock.Unlock()
+	if re, ok := regexps[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexps) >= maxPrograms {
+		clear(regexps)
+	}
+	regexps[expr] = re
+	return re, nil
+}
+
+func textExtract(text, expr string) (any, error) {
+	re, err := compileRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+	match := re.FindStringSubmatch(text)
+	switch {
+	case match == nil:
+		return nil, nil
+	case len(match) > 1:
+		return match[1], nil
+	}
+	return match[0], nil
+}
+
+func textExtractAll(text, expr string) ([]any, error) {
+	re, err := compileRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+	result := []any{}
+	for _, match := range re.FindAllStringSubmatch(text, -1) {
+		if len(match) > 1 {
+			result = append(result, match[1])
+		} else {
+			result = append(result, match[0])
+		}
+	}
+	return result, nil
+}
+
+func textTruncate(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	if length <= 3 {
+		return string([]rune(text)[:max(length, 0)])
+	}
+	return string([]rune(text)[:length-3]) + "..."
+}
+
+// contentTexts returns the text of the content of a step result or a list of content.
+func contentTexts(value any) []string {
+	value = plain(value)
+	if m, ok := value.(map[string]any); ok {
+		value = m["content"]
+	}
+	list, _ := value.([]any)
+
+	var result []string
+	for _, item := range list {
+		if content, ok := item.(map[string]any); ok {
+			if text, ok := content["text"].(string); ok && text != "" {
+				result = append(result, text)
+			}
+		}
+	}
+	return result
+}
+
+func contentText(value any) string {
+	return strings.Join(contentTexts(value), "\n")
+}
+
+func contentLast(value any) any {
+	texts := contentTexts(value)
+	if len(texts) == 0 {
+		return nil
+	}
+	return texts[len(texts)-1]
+}
+
+func toTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case float64:
+		return time.UnixMilli(int64(v * 1000)).UTC(), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or YYYY-MM-DD", v)
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v, expected a string, Date or unix seconds", value)
+}
+
+func dateNow() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func dateParse(value any) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// dateTokens maps the tokens of date.format to Go layouts, longest first.
+var dateTokens = []struct {
+	token, layout string
+}{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"H", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"m", "4"},
+	{"ss", "05"}, {"s", "5"},
+	{"SSS", "000"},
+	{"A", "PM"}, {"a", "pm"},
+	{"ZZ", "-0700"}, {"Z", "-07:00"},
+}
+
+func dateFormat(value any, layout, timezone string) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return "", fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		t = t.In(loc)
+	}
+	if layout == "" {
+		return t.Format(time.RFC3339), nil
+	}
+
+	var result strings.Builder
+outer:
+	for i := 0; i < len(layout); {
+		if layout[i] == '[' {
+			if end := strings.IndexByte(layout[i:], ']'); end > 0 {
+				result.WriteString(layout[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		for _, tok := range dateTokens {
+			if strings.HasPrefix(layout[i:], tok.token) {
+				if tok.token == "H" {
+					result.WriteString(strconv.Itoa(t.Hour()))
+				} else {
+					result.WriteString(t.Format(tok.layout))
+				}
+				i += len(tok.token)
+				continue outer
+			}
+		}
+		result.WriteByte(layout[i])
+		i++
+	}
+	return result.String(), nil
+}
+
+// parseDuration is time.ParseDuration with support for days (d) and weeks (w).
+func parseDuration(s string) (time.Duration, error) {
+	var (
+		value    = strings.TrimSpace(s)
+		negative = strings.HasPrefix(value, "-")
+		result   time.Duration
+	)
+	value = strings.TrimLeft(value, "+-")
+	for _, unit := range []struct {
+		suffix string
+		d      time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if n, rest, ok := strings.Cut(value, unit.suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			result += time.Duration(count) * unit.d
+			value = rest
+		}
+	}
+	if value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		result += d
+	}
+	if negative {
+		result = -result
+	}
+	return result, nil
+}
+
+func dateAdd(value any, duration string) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	d, err := parseDuration(duration)
+	if err != nil {
+		return "", err
+	}
+	return t.Add(d).Format(time.RFC3339Nano), nil
+}
+
+func dateDiff(a, b any) (float64, error) {
+	x, err := toTime(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toTime(b)
+	if err != nil {
+		return 0, err
+	}
+	return x.Sub(y).Seconds(), nil
+}
+
+func base64Encode(encoding *base64.Encoding) func(string) string {
+	return func(text string) string {
+		return encoding.EncodeToString([]byte(text))
+	}
+}
+
+func base64Decode(encoding *base64.Encoding) func(string) (string, error) {
+	return func(text string) (string, error) {
+		data, err := encoding.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(text, "="))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64: %w", err)
+		}
+		return string(data), nil
+	}
+}
+
+func hashHex(fn func() hash.Hash) func(string) string {
+	return func(text string) string {
+		h := fn()
+		h.Write([]byte(text))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+}
+
+func hashHMAC(key, text, algorithm string) (string, error) {
+	var fn func() hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		fn = sha256.New
+	case "sha1":
+		fn = sha1.New
+	case "sha512":
+		fn = sha512.New
+	default:
+		return "", fmt.Errorf("unsupported HMAC algorithm %q, must be sha256, sha1 or sha512", algorithm)
+	}
+	mac := hmac.New(fn, []byte(key))
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
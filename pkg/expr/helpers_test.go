@@ -0,0 +1,157 @@
+package expr
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// toolResult has the JSON shape of a tool call result, which steps store as a Go value.
+type toolResult struct {
+	Content []toolContent `json:"content"`
+}
+
+type toolContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MIMEType string `json:"mimeType,omitempty"`
+}
+
+var helperData = map[string]any{
+	"issues": map[string]any{
+		"items": []any{
+			map[string]any{"id": 1.0, "title": "Crash on start", "status": "open", "labels": []any{"bug"}},
+			map[string]any{"id": 2.0, "title": "Add dark mode", "status": "closed", "labels": []any{}},
+			map[string]any{"id": 3.0, "title": "Slow search", "status": "open", "labels": []any{"perf", "bug"}},
+		},
+	},
+	"fetch": &toolResult{
+		Content: []toolContent{
+			{Type: "text", Text: "first"},
+			{Type: "image", Data: "aGk=", MIMEType: "image/png"},
+			{Type: "text", Text: "last"},
+		},
+	},
+	"user": map[string]any{"name": "Ada"},
+}
+
+func TestHelpers(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		err  string
+	}{
+		{expr: "nb.jsonpath.query(issues, '$.items[*].id')", want: `[1,2,3]`},
+		{expr: "nb.jsonpath.query(issues, '$..labels[0]')", want: `["bug","perf"]`},
+		{expr: "nb.jsonpath.query(issues, \"$.items[?(@.status == 'open')].title\")", want: `["Crash on start","Slow search"]`},
+		{expr: "nb.jsonpath.query(issues, '$.items[?(@.id > 1)].id')", want: `[2,3]`},
+		{expr: "nb.jsonpath.query(issues, '$.items[-1].id')", want: `[3]`},
+		{expr: "nb.jsonpath.query(issues, '$.items[0,2].id')", want: `[1,3]`},
+		{expr: "nb.jsonpath.query(issues, '$.items[1:].id')", want: `[2,3]`},
+		{expr: "nb.jsonpath.query(issues, \"$['items'][0]['title']\")", want: `["Crash on start"]`},
+		{expr: "nb.jsonpath.query(issues, '$.missing')", want: `[]`},
+		{expr: "nb.jsonpath.query(issues, '$.items[')", err: "invalid JSONPath"},
+		{expr: "nb.jsonpath.get(issues, 'items[1].title')", want: `"Add dark mode"`},
+		{expr: "nb.jsonpath.get(issues, '$.missing', 'none')", want: `"none"`},
+		{expr: "nb.jsonpath.get(issues, '$.missing')", want: `null`},
+		{expr: "nb.jsonpath.get(fetch, '$.content[0].text')", want: `"first"`},
+
+		{expr: "nb.text.template('Hello {{ name }}, you have {{count}} issues', {name: user.name, count: 2})", want: `"Hello Ada, you have 2 issues"`},
+		{expr: "nb.text.template('{{user.name}} {{missing}}!', {user})", want: `"Ada !"`},
+		{expr: "nb.text.template('{{items[0]}}', {items: [{a: 1}]})", want: `"{\"a\":1}"`},
+		{expr: "nb.text.extract('order #1234 shipped', '#(\\\\d+)')", want: `"1234"`},
+		{expr: "nb.text.extract('no order', '#\\\\d+')", want: `null`},
+		{expr: "nb.text.extractAll('a1 b22 c333', '\\\\d+')", want: `["1","22","333"]`},
+		{expr: "nb.text.extract('x', '(')", err: "missing closing )"},
+		{expr: "nb.text.truncate('hello world', 8)", want: `"hello..."`},
+		{expr: "nb.text.truncate('hello', 8)", want: `"hello"`},
+
+		{expr: "nb.content.text(fetch)", want: `"first\nlast"`},
+		{expr: "nb.content.last(fetch)", want: `"last"`},
+		{expr: "nb.content.text([{type: 'text', text: 'a'}, {type: 'text', text: 'b'}])", want: `"a\nb"`},
+		{expr: "nb.content.last({content: []})", want: `null`},
+
+		{expr: "nb.date.parse('2025-01-31')", want: `"2025-01-31T00:00:00Z"`},
+		{expr: "nb.date.parse(1700000000)", want: `"2023-11-14T22:13:20Z"`},
+		{expr: "nb.date.parse(new Date(Date.UTC(2025, 0, 31, 12)))", want: `"2025-01-31T12:00:00Z"`},
+		{expr: "nb.date.parse('tomorrow')", err: "invalid time"},
+		{expr: "nb.date.format('2025-01-05T09:07:03Z', 'YYYY-MM-DD HH:mm:ss')", want: `"2025-01-05 09:07:03"`},
+		{expr: "nb.date.format('2025-01-05T09:07:03Z', 'dddd, MMMM D [at] h:mm A')", want: `"Sunday, January 5 at 9:07 AM"`},
+		{expr: "nb.date.format('2025-01-05T23:00:00Z', 'YYYY-MM-DD HH:mm Z', 'Europe/Berlin')", want: `"2025-01-06 00:00 +01:00"`},
+		{expr: "nb.date.format('2025-01-05T23:00:00Z', 'H', 'Nowhere/City')", err: "invalid timezone"},
+		{expr: "nb.date.add('2025-01-31T00:00:00Z', '1d12h')", want: `"2025-02-01T12:00:00Z"`},
+		{expr: "nb.date.add('2025-01-31T00:00:00Z', '-2w')", want: `"2025-01-17T00:00:00Z"`},
+		{expr: "nb.date.add('2025-01-31', 'soon')", err: "invalid duration"},
+		{expr: "nb.date.diff('2025-01-02', '2025-01-01')", want: `86400`},
+
+		{expr: "nb.base64.encode('hello?')", want: `"aGVsbG8/"`},
+		{expr: "nb.base64.decode('aGVsbG8/')", want: `"hello?"`},
+		{expr: "nb.base64.decode('aGk')", want: `"hi"`},
+		{expr: "nb.base64.urlEncode('hello?')", want: `"aGVsbG8_"`},
+		{expr: "nb.base64.urlDecode('aGVsbG8_')", want: `"hello?"`},
+		{expr: "nb.base64.decode('!!')", err: "invalid base64"},
+
+		{expr: "nb.hash.sha256('abc')", want: `"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"`},
+		{expr: "nb.hash.sha1('abc')", want: `"a9993e364706816aba3e25717850c26c9cd0d89d"`},
+		{expr: "nb.hash.md5('abc')", want: `"900150983cd24fb0d6963f7d28e17f72"`},
+		{expr: "nb.hash.hmac('key', 'The quick brown fox jumps over the lazy dog')", want: `"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"`},
+		{expr: "nb.hash.hmac('key', 'text', 'crc32')", err: "unsupported HMAC algorithm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvalAny(context.Background(), nil, helperData, "${"+tt.expr+"}")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v (result %v)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("failed to marshal result: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelpersFrozen(t *testing.T) {
+	ctx := context.Background()
+	for _, src := range []string{
+		"${(() => { nb.text.truncate = () => 'changed'; return nb.text.truncate('hello world', 5) })()}",
+		"${(() => { nb.text = {}; return nb.text.truncate('hello world', 5) })()}",
+		"${(() => { delete nb.text; return nb.text.truncate('hello world', 5) })()}",
+	} {
+		got, err := EvalAny(ctx, nil, nil, src)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", src, err)
+		}
+		if got != "he..." {
+			t.Fatalf("%s: helper was changed, got %v", src, got)
+		}
+	}
+
+	got, err := EvalAny(ctx, nil, nil, "${nb.text.truncate('hello world', 5)}")
+	if err != nil || got != "he..." {
+		t.Fatalf("helper was changed for later expressions, got %v, %v", got, err)
+	}
+}
+
+func TestHelpersDocumented(t *testing.T) {
+	for _, h := range Helpers() {
+		if h.Usage == "" || h.Description == "" {
+			t.Errorf("helper %s is not documented", h.Name)
+		}
+		if !strings.HasPrefix(h.Usage, HelpersName+"."+h.Name+"(") {
+			t.Errorf("usage %q of helper %s does not match its name", h.Usage, h.Name)
+		}
+	}
+}
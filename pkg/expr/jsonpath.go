@@ -0,0 +1,438 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath. The supported subset is the root $, child .name and ['name'],
+// wildcards .* and [*], recursive descent ..name, indexes [0] and [-1], unions [0,2], slices
+// [1:3] and filters [?(@.field)] and [?(@.field == 'value')].
+type jsonPath []segment
+
+type segment struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     *[3]*int
+	filter    *filter
+}
+
+type filter struct {
+	path  jsonPath
+	op    string
+	value any
+}
+
+func parseJSONPath(path string) (jsonPath, error) {
+	p := pathParser{src: strings.TrimSpace(path)}
+	result, err := p.parse(true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", path, err)
+	}
+	return result, nil
+}
+
+type pathParser struct {
+	src string
+	pos int
+}
+
+func (p *pathParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parse parses segments until the end of the source, or for a filter until a comparison or the
+// closing parenthesis.
+func (p *pathParser) parse(root bool) (jsonPath, error) {
+	switch {
+	case root && p.peek("$"):
+		p.pos++
+	case !root && p.peek("@"):
+		p.pos++
+	case root && p.pos < len(p.src) && p.src[p.pos] != '.' && p.src[p.pos] != '[':
+		// A path without $ such as items[0].name
+		p.src = "." + p.src
+	}
+
+	var result jsonPath
+	for p.pos < len(p.src) {
+		if !root && strings.ContainsRune(" )=!<>", rune(p.src[p.pos])) {
+			break
+		}
+
+		var seg segment
+		switch {
+		case p.peek(".."):
+			p.pos += 2
+			seg.recursive = true
+			if p.peek("[") {
+				if err := p.bracket(&seg); err != nil {
+					return nil, err
+				}
+				result = append(result, seg)
+				continue
+			}
+		case p.peek("."):
+			p.pos++
+		case p.peek("["):
+			if err := p.bracket(&seg); err != nil {
+				return nil, err
+			}
+			result = append(result, seg)
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos)
+		}
+
+		name := p.name()
+		switch name {
+		case "":
+			return nil, fmt.Errorf("missing name at position %d", p.pos)
+		case "*":
+			seg.wildcard = true
+		default:
+			seg.names = []string{name}
+		}
+		result = append(result, seg)
+	}
+	return result, nil
+}
+
+func (p *pathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(".[ )=!<>", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *pathParser) bracket(seg *segment) error {
+	end := p.closing()
+	if end < 0 {
+		return fmt.Errorf("missing ] for [ at position %d", p.pos)
+	}
+	content := strings.TrimSpace(p.src[p.pos+1 : end])
+	p.pos = end + 1
+
+	switch {
+	case content == "*":
+		seg.wildcard = true
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		f, err := parseFilter(content[2 : len(content)-1])
+		if err != nil {
+			return err
+		}
+		seg.filter = f
+	case strings.Contains(content, ":"):
+		parts := strings.Split(content, ":")
+		if len(parts) > 3 {
+			return fmt.Errorf("invalid slice [%s]", content)
+		}
+		seg.slice = &[3]*int{}
+		for i, part := range parts {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("invalid slice [%s]", content)
+			}
+			seg.slice[i] = &n
+		}
+	default:
+		for _, part := range splitUnion(content) {
+			if name, ok := unquote(part); ok {
+				seg.names = append(seg.names, name)
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("invalid index %q, names must be quoted", part)
+			}
+			seg.indexes = append(seg.indexes, n)
+		}
+	}
+	return nil
+}
+
+// closing returns the position of the ] matching the [ at the current position.
+func (p *pathParser) closing() int {
+	var (
+		depth int
+		quote byte
+	)
+	for i := p.pos; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func splitUnion(s string) (result []string) {
+	var (
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			result = append(result, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(result, strings.TrimSpace(s[start:]))
+}
+
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+func parseFilter(src string) (*filter, error) {
+	p := pathParser{src: strings.TrimSpace(src)}
+	if !p.peek("@") {
+		return nil, fmt.Errorf("filter %q must start with @", src)
+	}
+	path, err := p.parse(false)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &filter{path: path}
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	if rest == "" {
+		return f, nil
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(rest, op) {
+			f.op = op
+			rest = strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+	if f.op == "" {
+		return nil, fmt.Errorf("invalid filter %q", src)
+	}
+	if s, ok := unquote(rest); ok {
+		f.value = s
+	} else if err := json.Unmarshal([]byte(rest), &f.value); err != nil {
+		return nil, fmt.Errorf("invalid value %s in filter %q", rest, src)
+	}
+	return f, nil
+}
+
+func (path jsonPath) query(value any) []any {
+	nodes := []any{value}
+	for _, seg := range path {
+		var next []any
+		for _, node := range nodes {
+			if seg.recursive {
+				for _, n := range descendants(node) {
+					next = append(next, seg.apply(n)...)
+				}
+			} else {
+				next = append(next, seg.apply(node)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func descendants(value any) []any {
+	result := []any{value}
+	for _, child := range children(value) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+func children(value any) []any {
+	switch v := value.(type) {
+	case map[string]any:
+		var result []any
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			result = append(result, v[key])
+		}
+		return result
+	case []any:
+		return v
+	}
+	return nil
+}
+
+func (seg segment) apply(value any) []any {
+	switch {
+	case seg.wildcard:
+		return children(value)
+	case seg.filter != nil:
+		var result []any
+		for _, child := range children(value) {
+			if seg.filter.match(child) {
+				result = append(result, child)
+			}
+		}
+		return result
+	}
+
+	var result []any
+	if m, ok := value.(map[string]any); ok {
+		for _, name := range seg.names {
+			if v, ok := m[name]; ok {
+				result = append(result, v)
+			}
+		}
+	}
+
+	list, ok := value.([]any)
+	if !ok {
+		return result
+	}
+	for _, i := range seg.indexes {
+		if i < 0 {
+			i += len(list)
+		}
+		if i >= 0 && i < len(list) {
+			result = append(result, list[i])
+		}
+	}
+	if seg.slice != nil {
+		start, end, step := 0, len(list), 1
+		if seg.slice[2] != nil && *seg.slice[2] > 0 {
+			step = *seg.slice[2]
+		}
+		if seg.slice[0] != nil {
+			start = clampIndex(*seg.slice[0], len(list))
+		}
+		if seg.slice[1] != nil {
+			end = clampIndex(*seg.slice[1], len(list))
+		}
+		for i := start; i < end; i += step {
+			result = append(result, list[i])
+		}
+	}
+	return result
+}
+
+func clampIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	return max(0, min(i, length))
+}
+
+func (f *filter) match(value any) bool {
+	matches := f.path.query(value)
+	if f.op == "" {
+		return len(matches) > 0
+	}
+	for _, m := range matches {
+		if compare(m, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(a any, op string, b any) bool {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch op {
+			case "==":
+				return x == y
+			case "!=":
+				return x != y
+			case "<":
+				return x < y
+			case "<=":
+				return x <= y
+			case ">":
+				return x > y
+			case ">=":
+				return x >= y
+			}
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			switch op {
+			case "<":
+				return x < y
+			case "<=":
+				return x <= y
+			case ">":
+				return x > y
+			case ">=":
+				return x >= y
+			}
+		}
+	}
+	if !scalar(a) || !scalar(b) {
+		return op == "!="
+	}
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+func scalar(v any) bool {
+	switch v.(type) {
+	case nil, string, bool, float64, int64, int:
+		return true
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
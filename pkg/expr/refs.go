@@ -0,0 +1,202 @@
+package expr
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// Reference is a free variable used in an expression and the properties read from it, for
+// example previous.output.name is {Name: "previous", Path: ["output", "name"]}.
+type Reference struct {
+	Name string
+	Path []string
+}
+
+func (r Reference) String() string {
+	return strings.Join(append([]string{r.Name}, r.Path...), ".")
+}
+
+// Expressions returns the javascript of the ${...} placeholders in s, split the same way they
+// are when s is evaluated.
+func Expressions(s string) (result []string) {
+	if strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}") {
+		return []string{s[2 : len(s)-1]}
+	}
+	os.Expand(s, func(name string) string {
+		result = append(result, name)
+		return ""
+	})
+	return result
+}
+
+// References parses the javascript expression and returns the variables it reads that are not
+// declared in the expression itself.
+func References(expr string) ([]Reference, error) {
+	program, err := parser.ParseFile(nil, "", expr, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	w := walkReferences(program)
+	var result []Reference
+	for _, ref := range w.refs {
+		if !w.bound[ref.Name] {
+			result = append(result, ref)
+		}
+	}
+	return result, nil
+}
+
+// ScriptReferences parses a script and returns the variables it reads and the global variables it
+// assigns, which are written back to the flow data.
+func ScriptReferences(src string) (refs []Reference, assigned []string, err error) {
+	program, err := parser.ParseFile(nil, "", scriptSource(src), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := walkReferences(program)
+	for _, ref := range w.refs {
+		if !w.bound[ref.Name] && ref.Name != ScriptCallName {
+			refs = append(refs, ref)
+		}
+	}
+	walk(reflect.ValueOf(program), func(node any) bool {
+		if n, ok := node.(*ast.AssignExpression); ok {
+			if id, ok := n.Left.(*ast.Identifier); ok && !w.bound[id.Name.String()] && !slices.Contains(assigned, id.Name.String()) {
+				assigned = append(assigned, id.Name.String())
+			}
+		}
+		return true
+	})
+	return refs, assigned, nil
+}
+
+func walkReferences(program *ast.Program) refWalker {
+	w := refWalker{
+		bound: map[string]bool{},
+	}
+	w.walk(reflect.ValueOf(program))
+	return w
+}
+
+var astPackage = reflect.TypeOf(ast.Identifier{}).PkgPath()
+
+type refWalker struct {
+	refs  []Reference
+	bound map[string]bool
+}
+
+func (w *refWalker) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem())
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		switch n := v.Interface().(type) {
+		case *ast.Identifier:
+			w.refs = append(w.refs, Reference{Name: n.Name.String()})
+			return
+		case *ast.DotExpression:
+			if ref, ok := dotReference(n); ok {
+				w.refs = append(w.refs, ref)
+				return
+			}
+		case *ast.PropertyShort:
+			// {name} reads name in an object literal and declares it in a destructuring pattern
+			w.refs = append(w.refs, Reference{Name: n.Name.Name.String()})
+			w.walk(reflect.ValueOf(n.Initializer))
+			return
+		case *ast.Binding:
+			w.bind(n.Target)
+			w.walk(reflect.ValueOf(n.Initializer))
+			return
+		case *ast.FunctionLiteral:
+			if n.Name != nil {
+				w.bound[n.Name.Name.String()] = true
+			}
+		}
+		w.walk(v.Elem())
+	case reflect.Struct:
+		// Only descend into syntax nodes, not source file information
+		if v.Type().PkgPath() != astPackage {
+			return
+		}
+		for i := range v.NumField() {
+			w.walk(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			w.walk(v.Index(i))
+		}
+	}
+}
+
+func (w *refWalker) bind(target ast.BindingTarget) {
+	declared := refWalker{
+		bound: w.bound,
+	}
+	declared.walk(reflect.ValueOf(target))
+	for _, ref := range declared.refs {
+		w.bound[ref.Name] = true
+	}
+}
+
+// walk calls fn for every syntax node until it returns false.
+func walk(v reflect.Value, fn func(node any) bool) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			return walk(v.Elem(), fn)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return true
+		}
+		if v.Type().Elem().PkgPath() == astPackage && !fn(v.Interface()) {
+			return false
+		}
+		return walk(v.Elem(), fn)
+	case reflect.Struct:
+		if v.Type().PkgPath() != astPackage {
+			return true
+		}
+		for i := range v.NumField() {
+			if !walk(v.Field(i), fn) {
+				return false
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			if !walk(v.Index(i), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dotReference(n *ast.DotExpression) (Reference, bool) {
+	path := []string{n.Identifier.Name.String()}
+	left := n.Left
+	for {
+		switch l := left.(type) {
+		case *ast.DotExpression:
+			path = append([]string{l.Identifier.Name.String()}, path...)
+			left = l.Left
+		case *ast.Identifier:
+			return Reference{Name: l.Name.String(), Path: path}, true
+		default:
+			return Reference{}, false
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/dop251/goja"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+)
+
+// ScriptCallName is the global function scripts call tools, agents and flows with.
+const ScriptCallName = "call"
+
+// CallFunc calls a tool, agent or flow for a script and returns its result.
+type CallFunc func(ctx context.Context, target string, args any) (any, error)
+
+// scriptSource wraps a script in a function so it can return a value. The function starts on the
+// first line so the line numbers of errors match the script.
+func scriptSource(src string) string {
+	return "(function() {" + src + "\n})()"
+}
+
+// RunScript runs a block of javascript with the data as global variables and returns the value
+// of its return statement. Global variables the script assigns or deletes are written back to the
+// data, unless the script fails. The env vars the script uses are read-only global variables, as
+// they are in expressions, unless the data has a variable of the same name. The time spent in
+// call does not count towards the time limit.
+func RunScript(ctx context.Context, env map[string]string, data map[string]any, src string, call CallFunc) (any, error) {
+	p := compile(scriptSource(src))
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	// Scripts change the globals, so the runtime is not returned to the pool
+	vm := vms.Get().(*goja.Runtime)
+	for key, value := range data {
+		if err := vm.Set(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set variable %s: %w", key, err)
+		}
+	}
+	for name := range p.names {
+		if _, ok := data[name]; ok || Globals()[name] {
+			continue
+		}
+		envVal, ok := Lookup(env, name)
+		if !ok {
+			continue
+		}
+		value, err := secrets.Expand(ctx, envVal)
+		if err != nil {
+			return nil, err
+		}
+		// Not enumerable, so it is not written back to the data
+		if err := vm.GlobalObject().DefineDataProperty(name, vm.ToValue(value), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+			return nil, fmt.Errorf("failed to set env var %s: %w", name, err)
+		}
+	}
+
+	opts := limits()
+	g := newGuard(ctx, vm, opts)
+	defer g.stop()
+
+	callFn := vm.ToValue(func(target string, args any) (any, error) {
+		if call == nil {
+			return nil, fmt.Errorf("%s is not available", ScriptCallName)
+		}
+		resume := g.pause()
+		defer resume()
+		result, err := call(ctx, target, args)
+		if err != nil {
+			return nil, err
+		}
+		return plain(result), nil
+	})
+	if err := vm.GlobalObject().DefineDataProperty(ScriptCallName, callFn, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+		return nil, err
+	}
+
+	val, err := vm.RunProgram(p.program)
+	if err != nil {
+		return nil, runError(err)
+	}
+
+	var result any
+	if !goja.IsUndefined(val) {
+		result = val.Export()
+	}
+
+	global := vm.GlobalObject()
+	globals := map[string]any{}
+	for _, key := range global.Keys() {
+		value := global.Get(key)
+		if _, ok := goja.AssertFunction(value); ok {
+			continue
+		}
+		if !goja.IsUndefined(value) {
+			globals[key] = value.Export()
+		}
+	}
+	if err := checkSize(opts, result, globals); err != nil {
+		return nil, err
+	}
+
+	for key := range data {
+		if value := global.Get(key); value == nil || goja.IsUndefined(value) {
+			delete(data, key)
+		}
+	}
+	maps.Copy(data, globals)
+	return result, nil
+}
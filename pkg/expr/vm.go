@@ -0,0 +1,444 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+)
+
+const (
+	DefaultTimeout   = 10 * time.Second
+	DefaultMaxMemory = 256 << 20
+
+	maxCallStackSize = 10000
+	maxPrograms      = 4096
+)
+
+// Options are the limits of evaluating a single expression. A zero value disables the limit.
+type Options struct {
+	// Timeout is the maximum time an expression may run. Built-in functions such as JSON.stringify
+	// are not interrupted, the expression is stopped once they return.
+	Timeout time.Duration
+	// MaxMemory is the maximum number of bytes of the values an expression returns and a script
+	// writes back to its globals, estimated from the length of strings and the number of items.
+	// Memory an expression only uses while it runs is bounded by the timeout.
+	MaxMemory uint64
+}
+
+var options atomic.Pointer[Options]
+
+// Setup sets the limits of all expressions evaluated by the process. Until it is called the
+// default timeout and memory limit apply.
+func Setup(opts Options) {
+	options.Store(&opts)
+}
+
+func limits() Options {
+	if opts := options.Load(); opts != nil {
+		return *opts
+	}
+	return Options{
+		Timeout:   DefaultTimeout,
+		MaxMemory: DefaultMaxMemory,
+	}
+}
+
+type program struct {
+	program *goja.Program
+	err     error
+	// global is set if the program may declare or assign global variables, which would stay in
+	// the runtime after it ran
+	global bool
+	// names are the identifiers used by the program, only these need to be set from the data. A
+	// name may also be a local variable, setting it anyway is harmless.
+	names map[string]bool
+	// members are the variables whose members the program changes, the runtime is only reused if
+	// they are all data, as a changed built-in such as JSON.parse would stay in it
+	members map[string]bool
+}
+
+var (
+	programsLock sync.Mutex
+	programs     = map[string]program{}
+)
+
+// compile returns the cached program of the source, compiling it on first use.
+func compile(src string) program {
+	programsLock.Lock()
+	p, ok := programs[src]
+	programsLock.Unlock()
+	if ok {
+		return p
+	}
+
+	parsed, err := parser.ParseFile(nil, "", src, 0)
+	if err == nil {
+		p.program, err = goja.CompileAST(parsed, false)
+		p.global, p.members = mayChangeGlobals(parsed)
+		p.names = map[string]bool{}
+		refs := walkReferences(parsed)
+		for _, ref := range refs.refs {
+			p.names[ref.Name] = true
+		}
+		for name := range p.members {
+			if refs.bound[name] {
+				// A local variable may be a built-in, such as the parameter of (a => a.x = 1)(JSON)
+				p.global = true
+			}
+		}
+	}
+	p.err = err
+
+	programsLock.Lock()
+	defer programsLock.Unlock()
+	if len(programs) >= maxPrograms {
+		clear(programs)
+	}
+	programs[src] = p
+	return p
+}
+
+// Globals returns the names of the built-in global variables of expressions, including the
+// helper library.
+var Globals = sync.OnceValue(func() map[string]bool {
+	result := map[string]bool{}
+	for _, name := range newVM().GlobalObject().GetOwnPropertyNames() {
+		result[name] = true
+	}
+	return result
+})
+
+func newVM() *goja.Runtime {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxCallStackSize)
+	if err := registerHelpers(vm); err != nil {
+		panic(fmt.Sprintf("failed to register expression helpers: %v", err))
+	}
+	return vm
+}
+
+var vms = sync.Pool{
+	New: func() any {
+		return newVM()
+	},
+}
+
+// run evaluates the javascript with the data as global variables and passes the result to fn.
+// The result belongs to a pooled runtime, so it must not be used after fn returns.
+func run[T any](ctx context.Context, data map[string]any, src string, fn func(goja.Value) (T, error)) (result T, err error) {
+	p := compile(src)
+	if p.err != nil {
+		return result, p.err
+	}
+
+	vm := vms.Get().(*goja.Runtime)
+	reuse := !p.global
+	for name := range p.members {
+		if _, ok := data[name]; !ok {
+			reuse = false
+		}
+	}
+	var set []string
+	defer func() {
+		if reuse && reset(vm, set) {
+			vms.Put(vm)
+		}
+	}()
+
+	for key, value := range data {
+		if reuse && !p.names[key] {
+			continue
+		}
+		if Globals()[key] {
+			// Replaces a built-in such as JSON that can not be restored
+			reuse = false
+		}
+		if err := vm.Set(key, value); err != nil {
+			return result, fmt.Errorf("failed to set variable %s: %w", key, err)
+		}
+		set = append(set, key)
+	}
+
+	opts := limits()
+	g := newGuard(ctx, vm, opts)
+	val, err := vm.RunProgram(p.program)
+	if g.stop() {
+		// The interrupt may still be pending if the program completed first
+		reuse = false
+	}
+	if err != nil {
+		return result, runError(err)
+	}
+	result, err = fn(val)
+	if err != nil {
+		return result, err
+	}
+	if err := checkSize(opts, result); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// checkSize returns an error if the estimated size of the values exceeds the memory limit.
+func checkSize(opts Options, values ...any) error {
+	if opts.MaxMemory == 0 {
+		return nil
+	}
+	var total uint64
+	for _, value := range values {
+		if total = size(value, total, opts.MaxMemory); total > opts.MaxMemory {
+			return fmt.Errorf("expression exceeded the memory limit of %d MiB", opts.MaxMemory>>20)
+		}
+	}
+	return nil
+}
+
+// size adds the estimated size of v to total, it stops once total exceeds limit.
+func size(v any, total, limit uint64) uint64 {
+	const overhead = 16
+	total += overhead
+	switch v := v.(type) {
+	case string:
+		total += uint64(len(v))
+	case []any:
+		for _, item := range v {
+			if total = size(item, total, limit); total > limit {
+				break
+			}
+		}
+	case map[string]any:
+		for key, item := range v {
+			if total = size(item, total+uint64(len(key)), limit); total > limit {
+				break
+			}
+		}
+	}
+	return total
+}
+
+// runError returns the cause of an interrupted program and a readable error for a stack overflow.
+func runError(err error) error {
+	if interrupted := (*goja.InterruptedError)(nil); errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return cause
+		}
+	} else if overflow := (*goja.StackOverflowError)(nil); errors.As(err, &overflow) {
+		return fmt.Errorf("expression exceeded the maximum call stack size of %d", maxCallStackSize)
+	}
+	return err
+}
+
+// reset removes the data of the last evaluation from the runtime.
+func reset(vm *goja.Runtime, names []string) bool {
+	global := vm.GlobalObject()
+	for _, key := range names {
+		if err := global.Delete(key); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mayChangeGlobals reports if the program declares variables at the top level, assigns to or
+// deletes a variable that may be global, changes a member through a prototype, or uses this,
+// globalThis, eval, Function, Reflect or a function of Object that changes objects, which can
+// change anything. It also returns the variables whose members the program changes, which must
+// be data. Expressions rarely do either, and the runtime they ran in is not reused.
+func mayChangeGlobals(program *ast.Program) (bool, map[string]bool) {
+	for _, stmt := range program.Body {
+		switch stmt.(type) {
+		case *ast.VariableStatement, *ast.LexicalDeclaration, *ast.FunctionDeclaration, *ast.ClassDeclaration:
+			return true, nil
+		}
+	}
+
+	members := map[string]bool{}
+	found := false
+	walk(reflect.ValueOf(program), func(node any) bool {
+		switch n := node.(type) {
+		case *ast.AssignExpression:
+			found = !changesMember(n.Left, members)
+		case *ast.UnaryExpression:
+			switch n.Operator {
+			case token.INCREMENT, token.DECREMENT, token.DELETE:
+				found = !changesMember(n.Operand, members)
+			}
+		case *ast.ForIntoExpression:
+			found = !changesMember(n.Expression, members)
+		case *ast.DotExpression:
+			if object, ok := n.Left.(*ast.Identifier); ok && object.Name == "Object" {
+				found = objectMutators[n.Identifier.Name.String()]
+			}
+		case *ast.ThisExpression:
+			found = true
+		case *ast.Identifier:
+			switch n.Name {
+			case "globalThis", "eval", "Function", "Reflect":
+				found = true
+			}
+		}
+		return !found
+	})
+	return found, members
+}
+
+// objectMutators are the functions of Object that change the object they are passed.
+var objectMutators = map[string]bool{
+	"assign":            true,
+	"defineProperty":    true,
+	"defineProperties":  true,
+	"setPrototypeOf":    true,
+	"freeze":            true,
+	"seal":              true,
+	"preventExtensions": true,
+}
+
+// prototypeNames are the properties that reach the prototype of an object, which may be shared
+// with the built-in objects.
+var prototypeNames = map[string]bool{
+	"__proto__":   true,
+	"prototype":   true,
+	"constructor": true,
+}
+
+// changesMember reports if target is a member of a variable, such as a.b or a[i].b, that is not
+// reached through a prototype, and adds the variable to members. Setting any property of the
+// target itself is fine, a.__proto__ = x only changes a.
+func changesMember(target ast.Expression, members map[string]bool) bool {
+	for first := true; ; first = false {
+		switch t := target.(type) {
+		case *ast.DotExpression:
+			if !first && prototypeNames[t.Identifier.Name.String()] {
+				return false
+			}
+			target = t.Left
+		case *ast.BracketExpression:
+			if !first {
+				key, ok := t.Member.(*ast.StringLiteral)
+				if !ok || prototypeNames[key.Value.String()] {
+					if _, ok := t.Member.(*ast.NumberLiteral); !ok {
+						return false
+					}
+				}
+			}
+			target = t.Left
+		case *ast.Identifier:
+			if first {
+				return false
+			}
+			members[t.Name.String()] = true
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// guard interrupts a runtime when ctx is done or the time limit is exceeded.
+type guard struct {
+	vm        *goja.Runtime
+	timeout   time.Duration
+	stopAfter func() bool
+
+	lock     sync.Mutex
+	done     bool
+	fired    bool
+	timer    *time.Timer
+	deadline time.Time
+	// paused is when the time limit was paused, it is not checked until resumed
+	paused time.Time
+}
+
+func newGuard(ctx context.Context, vm *goja.Runtime, opts Options) *guard {
+	g := &guard{
+		vm:        vm,
+		timeout:   opts.Timeout,
+		stopAfter: func() bool { return true },
+	}
+
+	if ctx.Done() != nil {
+		g.stopAfter = context.AfterFunc(ctx, func() {
+			g.interrupt(context.Cause(ctx))
+		})
+	}
+
+	if opts.Timeout > 0 {
+		g.lock.Lock()
+		g.deadline = time.Now().Add(opts.Timeout)
+		g.timer = time.AfterFunc(opts.Timeout, g.check)
+		g.lock.Unlock()
+	}
+	return g
+}
+
+func (g *guard) interrupt(err error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.done || g.fired {
+		return
+	}
+	g.fired = true
+	g.vm.Interrupt(err)
+}
+
+func (g *guard) check() {
+	g.lock.Lock()
+	if g.done || !g.paused.IsZero() {
+		// resume restarts the timer
+		g.lock.Unlock()
+		return
+	}
+	if wait := time.Until(g.deadline); wait > 0 {
+		g.timer.Reset(wait)
+		g.lock.Unlock()
+		return
+	}
+	g.lock.Unlock()
+
+	g.interrupt(fmt.Errorf("expression exceeded the time limit of %s", g.timeout))
+}
+
+// pause stops checking the time limit until the returned function is called, the time in between
+// does not count towards the limit. Cancelling ctx still interrupts the runtime.
+func (g *guard) pause() (resume func()) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.timer == nil || !g.paused.IsZero() {
+		return func() {}
+	}
+	g.paused = time.Now()
+	g.timer.Stop()
+
+	return func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		g.deadline = g.deadline.Add(time.Since(g.paused))
+		g.paused = time.Time{}
+		if !g.done {
+			g.timer.Reset(time.Until(g.deadline))
+		}
+	}
+}
+
+// stop stops the guard and reports if the runtime was interrupted.
+func (g *guard) stop() bool {
+	g.lock.Lock()
+	g.done = true
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	fired := g.fired
+	g.lock.Unlock()
+	g.stopAfter()
+	return fired
+}
@@ -0,0 +1,172 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setTimeout(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	Setup(Options{Timeout: timeout})
+	t.Cleanup(func() {
+		options.Store(nil)
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	setTimeout(t, 100*time.Millisecond)
+
+	start := time.Now()
+	_, err := EvalAny(context.Background(), nil, nil, `${(() => { while (true) {} })()}`)
+	if err == nil || !strings.Contains(err.Error(), "exceeded the time limit of 100ms") {
+		t.Errorf("expected the time limit to be exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected the expression to be interrupted, took %s", time.Since(start))
+	}
+
+	// the runtime must not be interrupted by a timer of an earlier evaluation
+	for range 10 {
+		if val, err := EvalAny(context.Background(), nil, nil, `${1 + 1}`); err != nil || val != int64(2) {
+			t.Fatalf("expected 2, got %v, %v", val, err)
+		}
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	setTimeout(t, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := EvalAny(ctx, nil, nil, `${(() => { while (true) {} })()}`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	_, err = RunScript(ctx, nil, map[string]any{}, `while (true) {}`, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded for a script, got %v", err)
+	}
+}
+
+func TestScriptCallPausesTimeout(t *testing.T) {
+	setTimeout(t, 100*time.Millisecond)
+
+	call := func(ctx context.Context, target string, args any) (any, error) {
+		time.Sleep(150 * time.Millisecond)
+		return target, nil
+	}
+	val, err := RunScript(context.Background(), nil, map[string]any{}, `return call("a") + call("b")`, call)
+	if err != nil || val != "ab" {
+		t.Errorf("expected the time in call to not count, got %v, %v", val, err)
+	}
+
+	_, err = RunScript(context.Background(), nil, map[string]any{}, `call("a"); while (true) {}`, call)
+	if err == nil || !strings.Contains(err.Error(), "exceeded the time limit") {
+		t.Errorf("expected the time limit to be exceeded after call, got %v", err)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	Setup(Options{MaxMemory: 1 << 20})
+	t.Cleanup(func() {
+		options.Store(nil)
+	})
+
+	tests := []struct {
+		name string
+		expr string
+		err  bool
+	}{
+		{name: "small", expr: "${'x'.repeat(1000)}"},
+		{name: "string", expr: "${'x'.repeat(2 << 20)}", err: true},
+		{name: "interpolated", expr: "a ${'x'.repeat(2 << 20)}", err: true},
+		{name: "array", expr: "${Array.from({length: 100000}, (_, i) => i)}", err: true},
+		{name: "object", expr: "${Object.fromEntries(Array.from({length: 1000}, (_, i) => ['k' + i, 'x'.repeat(2000)]))}", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := EvalAny(context.Background(), nil, nil, tt.expr)
+			if tt.err && (err == nil || !strings.Contains(err.Error(), "exceeded the memory limit of 1 MiB")) {
+				t.Errorf("expected the memory limit to be exceeded, got %v", err)
+			} else if !tt.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	data := map[string]any{"big": "small"}
+	_, err := RunScript(context.Background(), nil, data, `big = "x".repeat(2 << 20)`, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeded the memory limit") {
+		t.Errorf("expected the memory limit to be exceeded for the globals of a script, got %v", err)
+	}
+	if data["big"] != "small" {
+		t.Errorf("expected the globals to not be written back, got %d bytes", len(data["big"].(string)))
+	}
+}
+
+func TestProgramCache(t *testing.T) {
+	programsLock.Lock()
+	clear(programs)
+	programsLock.Unlock()
+
+	for i := range maxPrograms {
+		compile(fmt.Sprintf("%d", i))
+	}
+	compile("1 + 1")
+	if p := compile("1 + 1"); p.err != nil || p.program == nil {
+		t.Errorf("expected the program to be compiled, got %v", p.err)
+	}
+
+	programsLock.Lock()
+	defer programsLock.Unlock()
+	if len(programs) != 1 {
+		t.Errorf("expected the cache to be cleared once it is full, got %d programs", len(programs))
+	}
+}
+
+func TestNoLeakingGlobals(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  map[string]any
+		expr  string
+		check string
+	}{
+		{name: "assignment", expr: "${x = 1}", check: "${typeof x === 'undefined'}"},
+		{name: "increment", expr: "${y = 1, y++}", check: "${typeof y === 'undefined'}"},
+		{name: "globalThis", expr: "${globalThis.z = 1}", check: "${typeof z === 'undefined'}"},
+		{name: "data", data: map[string]any{"item": 1}, expr: "${item}", check: "${typeof item === 'undefined'}"},
+		{name: "built-in", data: map[string]any{"JSON": 1}, expr: "${JSON}", check: "${typeof JSON.stringify === 'function'}"},
+		{name: "built-in function", expr: "${JSON.parse = null, 1}", check: "${typeof JSON.parse('{}') === 'object'}"},
+		{name: "prototype", expr: "${(Array.prototype.evil = 42, 1)}", check: "${typeof [].evil === 'undefined'}"},
+		{name: "prototype of data", data: map[string]any{"item": map[string]any{}}, expr: "${(item.__proto__.evil = 42, 1)}", check: "${typeof ({}).evil === 'undefined'}"},
+		{name: "delete", expr: "${delete Math.max, 1}", check: "${Math.max(1, 2) === 2}"},
+		{name: "defineProperty", expr: "${(() => { try { Object.defineProperty(String.prototype, 'evil', {value: 42}) } catch (e) {} return 1 })()}", check: "${typeof ''.evil === 'undefined'}"},
+		{name: "Reflect", expr: "${Reflect.set(Object.prototype, 'evil', 42), 1}", check: "${typeof ({}).evil === 'undefined'}"},
+		{name: "alias", expr: "${(a => (a.evil = 42, 1))(Array.prototype)}", check: "${typeof [].evil === 'undefined'}"},
+		{name: "assign", expr: "${Object.assign(Array.prototype, {evil: 42}), 1}", check: "${typeof [].evil === 'undefined'}"},
+		{name: "member of data", data: map[string]any{"item": map[string]any{}}, expr: "${(item.evil = 42, item.evil)}", check: "${typeof item === 'undefined' && typeof ({}).evil === 'undefined'}"},
+		{name: "setPrototypeOf", expr: "${(() => { try { Object.setPrototypeOf(Array.prototype, null) } catch (e) {} return 1 })()}", check: "${typeof [].hasOwnProperty === 'function'}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the pool usually returns the same runtime on the same goroutine, repeat to make sure
+			for range 10 {
+				if _, err := EvalAny(context.Background(), nil, tt.data, tt.expr); err != nil {
+					t.Fatal(err)
+				}
+				val, err := EvalAny(context.Background(), nil, nil, tt.check)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if val != true {
+					t.Fatalf("expected nothing to leak into the next evaluation, got %v", val)
+				}
+			}
+		})
+	}
+}
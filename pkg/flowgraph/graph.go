@@ -0,0 +1,302 @@
+package flowgraph
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	ShapeStep     = "step"
+	ShapeDecision = "decision"
+	ShapeFork     = "fork"
+	ShapeTerminal = "terminal"
+
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	maxLabelLength = 60
+)
+
+// Graph is the control flow of a flow. Steps with nested steps are rendered as clusters.
+type Graph struct {
+	Name  string
+	Root  *Cluster
+	Edges []Edge
+
+	run      *Run
+	nodes    int
+	clusters int
+}
+
+type Cluster struct {
+	ID       string
+	Label    []string
+	Status   string
+	Nodes    []*Node
+	Clusters []*Cluster
+}
+
+type Node struct {
+	ID     string
+	Label  []string
+	Shape  string
+	Status string
+}
+
+type Edge struct {
+	From   string
+	To     string
+	Label  string
+	Dashed bool
+}
+
+// exit is an open end of the graph that is connected to whatever comes next.
+type exit struct {
+	node  string
+	label string
+}
+
+// New builds the graph of a flow. If run is set the nodes are annotated with the outcome and
+// duration of each step in the run.
+func New(name string, flow types.Flow, run *Run) *Graph {
+	g := &Graph{
+		Name: name,
+		Root: &Cluster{},
+		run:  run,
+	}
+
+	start := g.node(g.Root, ShapeTerminal, "start")
+	out := g.sequence(g.Root, flow.Steps, "steps", []exit{{node: start.ID}})
+	end := g.node(g.Root, ShapeTerminal, "end")
+	g.connect(out, end.ID)
+	return g
+}
+
+func (g *Graph) node(c *Cluster, shape string, label ...string) *Node {
+	g.nodes++
+	n := &Node{
+		ID:    fmt.Sprintf("n%d", g.nodes),
+		Shape: shape,
+		Label: label,
+	}
+	c.Nodes = append(c.Nodes, n)
+	return n
+}
+
+func (g *Graph) cluster(c *Cluster, label ...string) *Cluster {
+	g.clusters++
+	sub := &Cluster{
+		ID:    fmt.Sprintf("c%d", g.clusters),
+		Label: label,
+	}
+	c.Clusters = append(c.Clusters, sub)
+	return sub
+}
+
+func (g *Graph) connect(in []exit, to string) {
+	for _, e := range in {
+		g.Edges = append(g.Edges, Edge{
+			From:  e.node,
+			To:    to,
+			Label: e.label,
+		})
+	}
+}
+
+func (g *Graph) sequence(c *Cluster, steps []types.Step, path string, in []exit) []exit {
+	for i, step := range steps {
+		in = g.step(c, step, fmt.Sprintf("%s[%d]", path, i), in)
+	}
+	return in
+}
+
+func (g *Graph) step(c *Cluster, step types.Step, path string, in []exit) (out []exit) {
+	if step.If != "" {
+		decision := g.node(c, ShapeDecision, "if "+trim(step.If))
+		g.connect(in, decision.ID)
+		in = []exit{{node: decision.ID, label: "yes"}}
+		defer func() {
+			out = append(out, exit{node: decision.ID, label: "no"})
+		}()
+	}
+
+	label := stepLabel(step, path)
+	if loop := loopLabel(step); loop != "" {
+		label = append(label, loop)
+	}
+	if step.Reduce != nil && step.Reduce.Expression != "" {
+		label = append(label, "reduce: "+trim(step.Reduce.Expression))
+	}
+	status, duration := g.status(step)
+	if status != "" {
+		label = append(label, statusLabel(status, duration))
+	}
+	loop := step.ForEach != nil || step.While != ""
+
+	var source string
+	switch {
+	case step.Parallel.IsGroup():
+		join := step.Parallel.Join
+		if join == "" {
+			join = types.JoinAll
+		}
+		sub := g.cluster(c, append(label, "parallel, join "+join)...)
+		sub.Status = status
+		fork := g.node(sub, ShapeFork)
+		g.connect(in, fork.ID)
+		joinNode := g.node(sub, ShapeFork)
+		for i, branch := range step.Parallel.Steps {
+			g.connect(g.step(sub, branch, fmt.Sprintf("%s.parallel[%d]", path, i), []exit{{node: fork.ID}}), joinNode.ID)
+		}
+		source = joinNode.ID
+		out = []exit{{node: joinNode.ID}}
+	case len(step.Steps) > 0:
+		sub := g.cluster(c, label...)
+		sub.Status = status
+		// The first node created in the cluster is the entry of the nested steps
+		entry := fmt.Sprintf("n%d", g.nodes+1)
+		out = g.sequence(sub, step.Steps, path+".steps", in)
+		if loop {
+			for _, e := range out {
+				g.Edges = append(g.Edges, Edge{From: e.node, To: entry, Label: "next", Dashed: true})
+			}
+		}
+		if len(out) > 0 {
+			source = out[0].node
+		}
+	default:
+		n := g.node(c, ShapeStep, label...)
+		n.Status = status
+		g.connect(in, n.ID)
+		if loop {
+			g.Edges = append(g.Edges, Edge{From: n.ID, To: n.ID, Label: "next", Dashed: true})
+		}
+		source = n.ID
+		out = []exit{{node: n.ID}}
+	}
+
+	if step.Reduce != nil && len(step.Reduce.Steps) > 0 {
+		sub := g.cluster(c, "reduce")
+		out = g.sequence(sub, step.Reduce.Steps, path+".reduce", out)
+	}
+
+	if step.OnError != nil && len(step.OnError.Steps) > 0 && source != "" {
+		sub := g.cluster(c, "on error")
+		entry := fmt.Sprintf("n%d", g.nodes+1)
+		errOut := g.sequence(sub, step.OnError.Steps, path+".onError", nil)
+		g.Edges = append(g.Edges, Edge{From: source, To: entry, Label: "on error", Dashed: true})
+		out = append(out, errOut...)
+	}
+
+	return out
+}
+
+// status returns the outcome of the step in the overlaid run and how long it took, including
+// the time spent in nested steps.
+func (g *Graph) status(step types.Step) (string, time.Duration) {
+	if g.run == nil || step.ID == "" {
+		return "", 0
+	}
+	stepRun, ok := g.run.Steps[step.ID]
+	if !ok {
+		return "", 0
+	}
+
+	start := stepRun.Start
+	var visit func([]types.Step)
+	visit = func(steps []types.Step) {
+		for _, nested := range steps {
+			if r, ok := g.run.Steps[nested.ID]; ok && !r.Start.IsZero() && (start.IsZero() || r.Start.Before(start)) {
+				start = r.Start
+			}
+			visit(nested.Steps)
+			visit(nested.Parallel.Steps)
+		}
+	}
+	visit(step.Steps)
+	visit(step.Parallel.Steps)
+
+	status := StatusSucceeded
+	if stepRun.Failed {
+		status = StatusFailed
+	}
+	if start.IsZero() {
+		return status, 0
+	}
+	return status, stepRun.End.Sub(start)
+}
+
+func stepLabel(step types.Step, path string) []string {
+	label := []string{path}
+	if step.ID != "" {
+		label[0] = step.ID
+	}
+
+	switch {
+	case step.Agent.Name != "":
+		label = append(label, "agent: "+step.Agent.Name)
+	case step.Tool != "":
+		label = append(label, "tool: "+step.Tool)
+	case step.Flow != "":
+		label = append(label, "flow: "+step.Flow)
+	case step.Ask != nil:
+		label = append(label, "ask: "+trim(step.Ask.Message))
+	case step.Script != "":
+		label = append(label, "script")
+	}
+
+	if len(step.Set) > 0 {
+		label = append(label, "set: "+strings.Join(slices.Sorted(maps.Keys(step.Set)), ", "))
+	}
+	if step.Retry != nil && step.Retry.Attempts > 0 {
+		label = append(label, fmt.Sprintf("retry: %d", step.Retry.Attempts))
+	}
+	if step.Timeout != "" {
+		label = append(label, "timeout: "+step.Timeout)
+	}
+	return label
+}
+
+func loopLabel(step types.Step) string {
+	var loop string
+	switch {
+	case step.ForEach != nil:
+		itemVar := step.ForEachVar
+		if itemVar == "" {
+			itemVar = "item"
+		}
+		loop = fmt.Sprintf("forEach %s in %s", itemVar, trim(fmt.Sprint(step.ForEach)))
+	case step.While != "":
+		loop = "while " + trim(step.While)
+	default:
+		return ""
+	}
+	if step.Parallel.Enabled {
+		loop = "parallel " + loop
+	}
+	return loop
+}
+
+func statusLabel(status string, duration time.Duration) string {
+	mark := "✓"
+	if status == StatusFailed {
+		mark = "✗"
+	}
+	if duration <= 0 {
+		return mark + " " + status
+	}
+	return fmt.Sprintf("%s %s in %s", mark, status, duration.Round(time.Millisecond))
+}
+
+func trim(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > maxLabelLength {
+		return string(runes[:maxLabelLength]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,203 @@
+package flowgraph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/audit"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+var testFlow = types.Flow{
+	Steps: []types.Step{
+		{ID: "search", Tool: "search/query", Retry: &types.Retry{Attempts: 3}},
+		{ID: "check", If: `${search.output.count > 0}`, Agent: types.AgentCall{Name: "summarizer"}},
+		{ID: "fanout", Parallel: types.Parallel{Join: types.JoinAny, Steps: []types.Step{
+			{ID: "a", Flow: "child"},
+			{ID: "b", Script: "return 1", OnError: &types.OnError{Steps: []types.Step{{ID: "recover", Set: map[string]any{"x": 1}}}}},
+		}}},
+		{ID: "each", ForEach: "${input.items}", Steps: []types.Step{{ID: "inner", Tool: "server/tool"}}},
+	},
+}
+
+func render(t *testing.T, g *Graph, format string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := g.Render(&out, format); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestMermaid(t *testing.T) {
+	expected := `---
+title: "test"
+---
+flowchart TD
+  classDef succeeded fill:#d4edda,stroke:#28a745
+  classDef failed fill:#f8d7da,stroke:#dc3545
+  n1(["start"])
+  n2["search<br/>tool: search/query<br/>retry: 3"]
+  n3{"if ${search.output.count > 0}"}
+  n4["check<br/>agent: summarizer"]
+  n11(["end"])
+  subgraph c1 ["fanout<br/>parallel, join any"]
+    n5((" "))
+    n6((" "))
+    n7["a<br/>flow: child"]
+    n8["b<br/>script"]
+    subgraph c2 ["on error"]
+      n9["recover<br/>set: x"]
+    end
+  end
+  subgraph c3 ["each<br/>forEach item in ${input.items}"]
+    n10["inner<br/>tool: server/tool"]
+  end
+  n1 --> n2
+  n2 --> n3
+  n3 -->|"yes"| n4
+  n4 --> n5
+  n3 -->|"no"| n5
+  n5 --> n7
+  n7 --> n6
+  n5 --> n8
+  n8 -.->|"on error"| n9
+  n8 --> n6
+  n9 --> n6
+  n6 --> n10
+  n10 -.->|"next"| n10
+  n10 --> n11
+`
+	if got := render(t, New("test", testFlow, nil), FormatMermaid); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	flow := types.Flow{
+		Steps: []types.Step{
+			{ID: "greet", If: `${input.name == "bob"}`, Ask: &types.Ask{Message: "Say \"hi\"\nto bob?"}},
+		},
+	}
+	got := render(t, New("greet", flow, nil), FormatDOT)
+	for _, line := range []string{
+		`digraph "greet" {`,
+		`  n1 [label="start", shape=ellipse, style=filled];`,
+		`  n2 [label="if ${input.name == \"bob\"}", shape=diamond, style=filled];`,
+		`  n3 [label="greet\nask: Say \"hi\" to bob?"];`,
+		`  n2 -> n3 [label="yes"];`,
+		`  n2 -> n4 [label="no"];`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected %s in:\n%s", line, got)
+		}
+	}
+
+	if err := New("greet", flow, nil).Render(&strings.Builder{}, "svg"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := &Run{
+		Steps: map[string]StepRun{
+			"search": {Start: start, End: start.Add(1500 * time.Millisecond)},
+			"a":      {Start: start.Add(2 * time.Second), End: start.Add(3 * time.Second)},
+			"b":      {Start: start.Add(2 * time.Second), End: start.Add(4 * time.Second), Failed: true},
+			// The group is done when its last branch is, it started with its first branch
+			"fanout": {End: start.Add(4 * time.Second)},
+		},
+	}
+
+	got := render(t, New("test", testFlow, run), FormatMermaid)
+	for _, line := range []string{
+		`  n2["search<br/>tool: search/query<br/>retry: 3<br/>✓ succeeded in 1.5s"]`,
+		`  class n2 succeeded`,
+		`    n8["b<br/>script<br/>✗ failed in 2s"]`,
+		`    class n8 failed`,
+		`  subgraph c1 ["fanout<br/>✓ succeeded in 2s<br/>parallel, join any"]`,
+		`  style c1 fill:#d4edda,stroke:#28a745`,
+		`  n4["check<br/>agent: summarizer"]`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected %s in:\n%s", line, got)
+		}
+	}
+}
+
+func progress(t *testing.T, flow map[string]any) json.RawMessage {
+	t.Helper()
+	params, err := json.Marshal(map[string]any{
+		"data": map[string]any{
+			"type": "nanobot/flow/state",
+			"flow": flow,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := json.Marshal(mcp.Message{JSONRPC: "2.0", Method: "notifications/progress", Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestReadRun(t *testing.T) {
+	dir := t.TempDir()
+	r, err := audit.NewFileRecorder(dir, audit.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	call, _ := json.Marshal(mcp.Message{JSONRPC: "2.0", Method: "tools/call", Params: json.RawMessage(`{"name":"test"}`)})
+	records := []mcp.Record{
+		{Time: start, Message: call},
+		{Time: start.Add(time.Second), Message: progress(t, map[string]any{"flow": "test", "id": "run1", "search": map[string]any{"output": 1}})},
+		{Time: start.Add(3 * time.Second), Message: progress(t, map[string]any{"flow": "test", "id": "run1", "search": map[string]any{"output": 1}, "check": nil})},
+		{Time: start.Add(4 * time.Second), Message: progress(t, map[string]any{"flow": "other", "id": "run2", "search": map[string]any{}})},
+		{Time: start.Add(5 * time.Second), Message: progress(t, map[string]any{"flow": "test", "id": "run3", "search": map[string]any{"isError": true}})},
+	}
+	for _, record := range records {
+		r.Record(context.Background(), record)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := ReadRun(dir, "test", "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]StepRun{
+		"search": {Start: start, End: start.Add(time.Second)},
+		"check":  {Start: start.Add(time.Second), End: start.Add(3 * time.Second), Failed: true},
+	}
+	if len(run.Steps) != len(expected) {
+		t.Errorf("expected steps %v, got %v", expected, run.Steps)
+	}
+	for id, stepRun := range expected {
+		got := run.Steps[id]
+		if !got.Start.Equal(stepRun.Start) || !got.End.Equal(stepRun.End) || got.Failed != stepRun.Failed {
+			t.Errorf("expected %s to be %+v, got %+v", id, stepRun, got)
+		}
+	}
+
+	latest, err := ReadRun(dir, "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != "run3" || !latest.Steps["search"].Failed {
+		t.Errorf("expected the latest run with a failed search, got %+v", latest)
+	}
+
+	if _, err := ReadRun(dir, "test", "missing"); err == nil {
+		t.Error("expected an error for a missing run")
+	}
+}
@@ -0,0 +1,145 @@
+package flowgraph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatMermaid = "mermaid"
+	FormatDOT     = "dot"
+)
+
+var statusColors = map[string][2]string{
+	StatusSucceeded: {"#d4edda", "#28a745"},
+	StatusFailed:    {"#f8d7da", "#dc3545"},
+}
+
+// Render writes the graph in the given format, mermaid or dot.
+func (g *Graph) Render(out io.Writer, format string) error {
+	var buf strings.Builder
+	switch format {
+	case FormatMermaid, "":
+		g.mermaid(&buf)
+	case FormatDOT:
+		g.dot(&buf)
+	default:
+		return fmt.Errorf("unknown graph format %q, must be %s or %s", format, FormatMermaid, FormatDOT)
+	}
+	_, err := io.WriteString(out, buf.String())
+	return err
+}
+
+func (g *Graph) mermaid(buf *strings.Builder) {
+	buf.WriteString("---\ntitle: " + mermaidText(g.Name, "") + "\n---\nflowchart TD\n")
+	for _, status := range []string{StatusSucceeded, StatusFailed} {
+		colors := statusColors[status]
+		fmt.Fprintf(buf, "  classDef %s fill:%s,stroke:%s\n", status, colors[0], colors[1])
+	}
+	g.mermaidCluster(buf, g.Root, "  ")
+	for _, edge := range g.Edges {
+		arrow := "-->"
+		if edge.Dashed {
+			arrow = "-.->"
+		}
+		if edge.Label != "" {
+			arrow += "|" + mermaidText(edge.Label, "") + "|"
+		}
+		fmt.Fprintf(buf, "  %s %s %s\n", edge.From, arrow, edge.To)
+	}
+}
+
+func (g *Graph) mermaidCluster(buf *strings.Builder, c *Cluster, indent string) {
+	for _, n := range c.Nodes {
+		text := mermaidText(strings.Join(n.Label, "\n"), " ")
+		switch n.Shape {
+		case ShapeDecision:
+			fmt.Fprintf(buf, "%s%s{%s}\n", indent, n.ID, text)
+		case ShapeFork:
+			fmt.Fprintf(buf, "%s%s((%s))\n", indent, n.ID, text)
+		case ShapeTerminal:
+			fmt.Fprintf(buf, "%s%s([%s])\n", indent, n.ID, text)
+		default:
+			fmt.Fprintf(buf, "%s%s[%s]\n", indent, n.ID, text)
+		}
+		if n.Status != "" {
+			fmt.Fprintf(buf, "%sclass %s %s\n", indent, n.ID, n.Status)
+		}
+	}
+	for _, sub := range c.Clusters {
+		fmt.Fprintf(buf, "%ssubgraph %s [%s]\n", indent, sub.ID, mermaidText(strings.Join(sub.Label, "\n"), ""))
+		g.mermaidCluster(buf, sub, indent+"  ")
+		fmt.Fprintf(buf, "%send\n", indent)
+		if colors, ok := statusColors[sub.Status]; ok {
+			fmt.Fprintf(buf, "%sstyle %s fill:%s,stroke:%s\n", indent, sub.ID, colors[0], colors[1])
+		}
+	}
+}
+
+// mermaidText quotes a label, escaping quotes and line breaks. Mermaid requires a non empty
+// label for some shapes so empty is used if s is empty.
+func mermaidText(s, empty string) string {
+	if s == "" {
+		s = empty
+	}
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
+
+func (g *Graph) dot(buf *strings.Builder) {
+	fmt.Fprintf(buf, "digraph %s {\n", dotText(g.Name))
+	buf.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white, fontname=Helvetica];\n")
+	buf.WriteString("  edge [fontname=Helvetica, fontsize=10];\n")
+	g.dotCluster(buf, g.Root, "  ")
+	for _, edge := range g.Edges {
+		var attrs []string
+		if edge.Label != "" {
+			attrs = append(attrs, "label="+dotText(edge.Label))
+		}
+		if edge.Dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(buf, "  %s -> %s", edge.From, edge.To)
+		if len(attrs) > 0 {
+			fmt.Fprintf(buf, " [%s]", strings.Join(attrs, ", "))
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("}\n")
+}
+
+func (g *Graph) dotCluster(buf *strings.Builder, c *Cluster, indent string) {
+	for _, n := range c.Nodes {
+		attrs := []string{"label=" + dotText(strings.Join(n.Label, "\n"))}
+		switch n.Shape {
+		case ShapeDecision:
+			attrs = append(attrs, "shape=diamond", "style=filled")
+		case ShapeFork:
+			attrs = append(attrs, "shape=circle", "width=0.2", "fixedsize=true")
+		case ShapeTerminal:
+			attrs = append(attrs, "shape=ellipse", "style=filled")
+		}
+		if colors, ok := statusColors[n.Status]; ok {
+			attrs = append(attrs, "fillcolor="+dotText(colors[0]), "color="+dotText(colors[1]))
+		}
+		fmt.Fprintf(buf, "%s%s [%s];\n", indent, n.ID, strings.Join(attrs, ", "))
+	}
+	for _, sub := range c.Clusters {
+		fmt.Fprintf(buf, "%ssubgraph cluster_%s {\n", indent, sub.ID)
+		fmt.Fprintf(buf, "%s  label=%s;\n", indent, dotText(strings.Join(sub.Label, "\n")))
+		if colors, ok := statusColors[sub.Status]; ok {
+			fmt.Fprintf(buf, "%s  style=filled; fillcolor=%s; color=%s;\n", indent, dotText(colors[0]), dotText(colors[1]))
+		}
+		g.dotCluster(buf, sub, indent+"  ")
+		fmt.Fprintf(buf, "%s}\n", indent)
+	}
+}
+
+func dotText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,133 @@
+package flowgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/audit"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// Run is a recorded execution of a flow, rebuilt from the nanobot/flow/state progress
+// notifications in an audit trail.
+type Run struct {
+	ID    string
+	Flow  string
+	Steps map[string]StepRun
+}
+
+type StepRun struct {
+	Start  time.Time
+	End    time.Time
+	Failed bool
+}
+
+type runState struct {
+	run    *Run
+	last   time.Time
+	values map[string]string
+}
+
+// ReadRun finds the run of the flow in the audit trail at path. If runID is empty the most
+// recently updated run of the flow is returned.
+//
+// The state of a flow is sent after every step so a step is considered started when the previous
+// state was sent and ended when its result last changed.
+func ReadRun(path, flowName, runID string) (*Run, error) {
+	var (
+		runs     = map[string]*runState{}
+		lastCall time.Time
+		latest   *runState
+	)
+
+	err := audit.Read(path, audit.Filter{}, func(record mcp.Record, msg mcp.Message) error {
+		switch msg.Method {
+		case "tools/call":
+			var call mcp.CallToolRequest
+			if err := json.Unmarshal(msg.Params, &call); err == nil && call.Name == flowName {
+				lastCall = record.Time
+			}
+			return nil
+		case "notifications/progress":
+		default:
+			return nil
+		}
+
+		var progress struct {
+			Data struct {
+				Type string                     `json:"type"`
+				Flow map[string]json.RawMessage `json:"flow"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(msg.Params, &progress); err != nil || progress.Data.Type != "nanobot/flow/state" {
+			return nil
+		}
+
+		var name, id string
+		_ = json.Unmarshal(progress.Data.Flow["flow"], &name)
+		_ = json.Unmarshal(progress.Data.Flow["id"], &id)
+		if name != flowName || (runID != "" && id != runID) {
+			return nil
+		}
+
+		state, ok := runs[id]
+		if !ok {
+			state = &runState{
+				run: &Run{
+					ID:    id,
+					Flow:  name,
+					Steps: map[string]StepRun{},
+				},
+				last:   lastCall,
+				values: map[string]string{},
+			}
+			if state.last.IsZero() {
+				state.last = record.Time
+			}
+			runs[id] = state
+		}
+		state.update(record.Time, progress.Data.Flow)
+		latest = state
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		if runID != "" {
+			return nil, fmt.Errorf("run %s of flow %s not found in %s", runID, flowName, path)
+		}
+		return nil, fmt.Errorf("no recorded runs of flow %s found in %s", flowName, path)
+	}
+	return latest.run, nil
+}
+
+func (s *runState) update(t time.Time, data map[string]json.RawMessage) {
+	for key, value := range data {
+		switch key {
+		case "id", "flow", "input", "previous", "error":
+			continue
+		}
+		if s.values[key] == string(value) {
+			continue
+		}
+		s.values[key] = string(value)
+
+		var output struct {
+			IsError bool `json:"isError"`
+		}
+		_ = json.Unmarshal(value, &output)
+
+		stepRun, seen := s.run.Steps[key]
+		if !seen {
+			stepRun.Start = s.last
+		}
+		stepRun.End = t
+		// A step that returned an error instead of a result is recorded as null
+		stepRun.Failed = output.IsError || string(value) == "null"
+		s.run.Steps[key] = stepRun
+	}
+	s.last = t
+}
@@ -13,6 +13,16 @@ func toResponse(resp *Response) (*types.CompletionResponse, error) {
 		Model: resp.Model,
 	}
 
+	if resp.Usage != nil {
+		result.Usage = &types.Usage{}
+		if resp.Usage.InputTokens != nil {
+			result.Usage.InputTokens = *resp.Usage.InputTokens
+		}
+		if resp.Usage.OutputTokens != nil {
+			result.Usage.OutputTokens = *resp.Usage.OutputTokens
+		}
+	}
+
 	for _, content := range resp.Content {
 		if content.Type == "tool_use" {
 			args, _ := json.Marshal(content.Input)
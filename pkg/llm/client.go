@@ -2,10 +2,14 @@ package llm
 
 import (
 	"context"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/llm/anthropic"
 	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
@@ -35,6 +39,7 @@ func (c Client) Complete(ctx context.Context, req types.CompletionRequest, opts
 	if req.Model == "default" || req.Model == "" {
 		req.Model = c.defaultModel
 	}
+	ctx = log.WithFields(log.WithComponent(ctx, "llm"), "model", req.Model)
 	if len(req.Input) > 0 {
 		if last := req.Input[len(req.Input)-1]; last.ToolCallResult != nil &&
 			last.ToolCallResult.OutputRole == "assistant" &&
@@ -70,5 +75,24 @@ func (c Client) Complete(ctx context.Context, req types.CompletionRequest, opts
 		newInput = append(newInput, input)
 	}
 	req.Input = newInput
-	return c.responses.Complete(ctx, req, opts...)
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "llm "+req.Model,
+		"gen_ai.request.model", req.Model,
+		"gen_ai.request.tools", len(req.Tools),
+		"gen_ai.request.inputs", len(req.Input))
+	resp, err := c.responses.Complete(ctx, req, opts...)
+	if resp != nil {
+		span.SetAttributes("gen_ai.response.model", resp.Model, "gen_ai.response.outputs", len(resp.Output))
+		if resp.Usage != nil {
+			span.SetAttributes("gen_ai.usage.input_tokens", resp.Usage.InputTokens,
+				"gen_ai.usage.output_tokens", resp.Usage.OutputTokens)
+			metrics.LLMTokens.Add(float64(resp.Usage.InputTokens), req.Model, "input")
+			metrics.LLMTokens.Add(float64(resp.Usage.OutputTokens), req.Model, "output")
+		}
+	}
+	span.End(err)
+	metrics.LLMRequests.Inc(req.Model, metrics.Status(err))
+	metrics.LLMRequestDuration.Observe(time.Since(start).Seconds(), req.Model)
+	return resp, err
 }
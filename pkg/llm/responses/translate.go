@@ -14,6 +14,13 @@ func toResponse(req *types.CompletionRequest, resp *Response) (*types.Completion
 		Model: resp.Model,
 	}
 
+	if resp.Usage.InputTokens != 0 || resp.Usage.OutputTokens != 0 {
+		result.Usage = &types.Usage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+		}
+	}
+
 	for _, output := range resp.Output {
 		if output.ComputerCall != nil {
 			for _, tool := range req.Tools {
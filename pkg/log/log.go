@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"regexp"
 	"slices"
@@ -21,41 +22,81 @@ var (
 	Base64Replacement = []byte(`$1..."`)
 )
 
-func Messages(_ context.Context, server string, out bool, data []byte) {
+func Messages(ctx context.Context, server string, out bool, data []byte) {
+	ctx = WithComponent(ctx, "mcp:"+server)
+	explicit := explicitlyEnabled(ctx, slog.LevelDebug)
 	if EnableProgress && bytes.Contains(data, []byte(`"notifications/progress"`)) {
-	} else if EnableMessages && !bytes.Contains(data, []byte(`"notifications/progress"`)) {
+	} else if (EnableMessages || explicit) && !bytes.Contains(data, []byte(`"notifications/progress"`)) {
 	} else {
 		return
 	}
 
+	data = Base64Replace.ReplaceAll(data, Base64Replacement)
+	msg := redact(ctx, strings.TrimSpace(string(data)))
+	if h := structured(); h != nil {
+		direction := "out"
+		if !out {
+			direction = "in"
+		}
+		write(ctx, h, slog.LevelDebug, "message", "server", server, "direction", direction,
+			"data", msg)
+		return
+	}
+
 	prefixFmt := "->(%s)"
 	if !out {
 		prefixFmt = "<-(%s)"
 	}
-	data = Base64Replace.ReplaceAll(data, Base64Replacement)
-	printer.Prefix(fmt.Sprintf(prefixFmt, server), strings.ReplaceAll(strings.TrimSpace(string(data)), "\n", " ")+"\n")
+	printer.Prefix(fmt.Sprintf(prefixFmt, server), strings.ReplaceAll(msg, "\n", " ")+"\n")
 }
 
-func StderrMessages(_ context.Context, server, line string) {
+func StderrMessages(ctx context.Context, server, line string) {
+	ctx = WithComponent(ctx, "mcp:"+server)
+	if !enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	line = redact(ctx, line)
+	if h := structured(); h != nil {
+		write(ctx, h, slog.LevelInfo, line, "server", server, "stream", "stderr")
+		return
+	}
 	printer.Prefix(fmt.Sprintf("<-(%s:stderr)", server), line+"\n")
 }
 
-func Errorf(_ context.Context, format string, args ...any) {
-	printer.Prefix("error", fmt.Sprintf(format+"\n", args...))
+func logf(ctx context.Context, level slog.Level, prefix, format string, args ...any) {
+	if !enabled(ctx, level) {
+		return
+	}
+	msg := redact(ctx, fmt.Sprintf(format, args...))
+	if h := structured(); h != nil {
+		write(ctx, h, level, msg)
+		return
+	}
+	printer.Prefix(prefix, msg+"\n")
 }
 
-func Infof(_ context.Context, format string, args ...any) {
-	printer.Prefix("info", fmt.Sprintf(format+"\n", args...))
+func Errorf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelError, "error", format, args...)
 }
 
-func Fatalf(_ context.Context, format string, args ...any) {
-	printer.Prefix("fatal", fmt.Sprintf(format+"\n", args...))
-	os.Exit(1)
+func Warnf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelWarn, "warn", format, args...)
 }
 
-func Debugf(_ context.Context, format string, args ...any) {
-	if !DebugLog {
-		return
+func Infof(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelInfo, "info", format, args...)
+}
+
+func Fatalf(ctx context.Context, format string, args ...any) {
+	msg := redact(ctx, fmt.Sprintf(format, args...))
+	if h := structured(); h != nil {
+		write(ctx, h, slog.LevelError, msg, "fatal", true)
+	} else {
+		printer.Prefix("fatal", msg+"\n")
 	}
-	printer.Prefix("debug", fmt.Sprintf(format+"\n", args...))
+	os.Exit(1)
+}
+
+func Debugf(ctx context.Context, format string, args ...any) {
+	logf(ctx, slog.LevelDebug, "debug", format, args...)
 }
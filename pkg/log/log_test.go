@@ -2,6 +2,11 @@ package log
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -24,3 +29,75 @@ func TestRegexp2(t *testing.T) {
 		t.Errorf("Expected data to be modified, but it was not. %s", expected)
 	}
 }
+
+func TestParseLevels(t *testing.T) {
+	l, err := parseLevels("warn,llm=debug,mcp:github,flow=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for component, expected := range map[string]slog.Level{
+		"":           slog.LevelWarn,
+		"llm":        slog.LevelDebug,
+		"mcp:github": slog.LevelDebug,
+		"mcp:other":  slog.LevelWarn,
+		"flow":       slog.LevelError,
+	} {
+		if level, _ := l.componentLevel(component); level != expected {
+			t.Errorf("component %q: expected level %s, got %s", component, expected, level)
+		}
+	}
+
+	if _, err := parseLevels("llm=loud"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log.json")
+	if err := Setup(Options{Level: "info,flow=debug", Format: FormatJSON, File: file}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = Setup(Options{})
+	}()
+
+	ctx := WithFields(context.Background(), "sessionId", "a")
+	ctx = WithFields(ctx, "sessionId", "b", "flowId", "c")
+	Debugf(ctx, "hidden")
+	Debugf(WithComponent(ctx, "flow"), "step %d", 1)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatalf("expected a single JSON line, got %s: %v", data, err)
+	}
+	if line["msg"] != "step 1" || line["component"] != "flow" || line["sessionId"] != "b" || line["flowId"] != "c" {
+		t.Errorf("unexpected log line: %s", data)
+	}
+}
+
+func TestSetupClosesPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := Setup(Options{File: filepath.Join(dir, "first.log")}); err != nil {
+		t.Fatal(err)
+	}
+	first := file
+	if err := Setup(Options{File: filepath.Join(dir, "second.log")}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = Setup(Options{})
+	}()
+
+	if _, err := first.Write([]byte("x")); err == nil {
+		t.Error("expected the first log file to be closed")
+	}
+	if file == nil || file == first {
+		t.Error("expected the second log file to be open")
+	}
+}
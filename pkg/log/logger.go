@@ -0,0 +1,228 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	// Level is the default level optionally followed by per component levels, for example
+	// "info,llm=debug,mcp:github=debug,flow=warn". A component without a level is set to debug.
+	Level string
+	// Format is text or json
+	Format string
+	// File is a path to append logs to instead of stderr
+	File string
+}
+
+type levels struct {
+	base       slog.Level
+	components map[string]slog.Level
+}
+
+var (
+	lock    sync.RWMutex
+	filter  = levels{base: slog.LevelInfo}
+	handler slog.Handler
+	// file is the log file opened by the last Setup, it is closed when Setup is called again
+	file *os.File
+)
+
+// Setup configures the level filters and output of the logger. If neither a file nor the json format
+// is set, logs are printed as prefixed text to stderr.
+func Setup(opts Options) error {
+	parsed, err := parseLevels(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	var (
+		out       io.Writer = os.Stderr
+		newFile   *os.File
+		newHandle slog.Handler
+	)
+	if opts.File != "" {
+		newFile, err = os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open log file %s: %w", opts.File, err)
+		}
+		out = newFile
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		// Filtering is done before the record is built
+		Level: slog.LevelDebug,
+	}
+	switch opts.Format {
+	case FormatJSON:
+		newHandle = slog.NewJSONHandler(out, handlerOpts)
+	case FormatText, "":
+		if opts.File != "" {
+			newHandle = slog.NewTextHandler(out, handlerOpts)
+		}
+	default:
+		if newFile != nil {
+			_ = newFile.Close()
+		}
+		return fmt.Errorf("invalid log format %q, must be %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	filter = parsed
+	handler = newHandle
+	if file != nil {
+		_ = file.Close()
+	}
+	file = newFile
+	return nil
+}
+
+func parseLevels(spec string) (result levels, _ error) {
+	result.base = slog.LevelInfo
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		component, levelName, hasLevel := strings.Cut(part, "=")
+		if !hasLevel {
+			if level, err := parseLevel(part); err == nil {
+				result.base = level
+				continue
+			}
+			levelName = "debug"
+		}
+
+		level, err := parseLevel(levelName)
+		if err != nil {
+			return result, err
+		}
+		if result.components == nil {
+			result.components = map[string]slog.Level{}
+		}
+		result.components[strings.TrimSpace(component)] = level
+	}
+	return result, nil
+}
+
+func parseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return level, fmt.Errorf("invalid log level %q, must be one of debug, info, warn, error", name)
+	}
+	return level, nil
+}
+
+// componentLevel returns the level of the component and whether it was explicitly configured. A
+// component such as mcp:github also matches a filter for mcp.
+func (l levels) componentLevel(component string) (slog.Level, bool) {
+	if component == "" {
+		return l.base, false
+	}
+	if level, ok := l.components[component]; ok {
+		return level, true
+	}
+	if prefix, _, ok := strings.Cut(component, ":"); ok {
+		if level, ok := l.components[prefix]; ok {
+			return level, true
+		}
+	}
+	return l.base, false
+}
+
+func enabled(ctx context.Context, level slog.Level) bool {
+	lock.RLock()
+	componentLevel, explicit := filter.componentLevel(componentFromContext(ctx))
+	lock.RUnlock()
+
+	if !explicit && DebugLog && componentLevel > slog.LevelDebug {
+		componentLevel = slog.LevelDebug
+	}
+	return level >= componentLevel
+}
+
+func explicitlyEnabled(ctx context.Context, level slog.Level) bool {
+	lock.RLock()
+	componentLevel, explicit := filter.componentLevel(componentFromContext(ctx))
+	lock.RUnlock()
+	return explicit && level >= componentLevel
+}
+
+func structured() slog.Handler {
+	lock.RLock()
+	defer lock.RUnlock()
+	return handler
+}
+
+type fieldsKey struct{}
+
+type componentKey struct{}
+
+// WithFields returns a context whose log lines include the given key value pairs, for example a
+// session or flow ID. Keys that are already set in the context are replaced.
+func WithFields(ctx context.Context, keyValues ...any) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	existing, _ := ctx.Value(fieldsKey{}).([]any)
+	fields := make([]any, 0, len(existing)+len(keyValues))
+	for i := 0; i+1 < len(existing); i += 2 {
+		if !slices.Contains(keyValues, existing[i]) {
+			fields = append(fields, existing[i], existing[i+1])
+		}
+	}
+	fields = append(fields, keyValues...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithComponent returns a context whose log lines are filtered by the level of the component, such as
+// llm, flow or mcp:server-name.
+func WithComponent(ctx context.Context, component string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, componentKey{}, component)
+}
+
+func componentFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	component, _ := ctx.Value(componentKey{}).(string)
+	return component
+}
+
+func fieldsFromContext(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+	return fields
+}
+
+func write(ctx context.Context, h slog.Handler, level slog.Level, msg string, keyValues ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	if component := componentFromContext(ctx); component != "" {
+		record.AddAttrs(slog.String("component", component))
+	}
+	record.Add(fieldsFromContext(ctx)...)
+	record.Add(keyValues...)
+	_ = h.Handle(ctx, record)
+}
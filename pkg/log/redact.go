@@ -0,0 +1,111 @@
+package log
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	Redacted = "[REDACTED]"
+	// MinRedactLength is the length of the shortest value that is redacted, replacing shorter
+	// values would mangle unrelated content.
+	MinRedactLength = 4
+)
+
+var (
+	sensitiveLock   sync.RWMutex
+	sensitiveValues = map[string]struct{}{}
+	// redactor is built on first use after values were added
+	redactor *strings.Replacer
+)
+
+type sensitiveKey struct{}
+
+// AddSensitive registers values of the process, such as resolved secrets, that are replaced with
+// [REDACTED] in everything that is logged. Values that belong to a session are added to the
+// context with WithSensitive instead, so they are dropped with the session.
+func AddSensitive(values ...string) {
+	sensitiveLock.Lock()
+	defer sensitiveLock.Unlock()
+
+	for _, value := range values {
+		if len(value) < MinRedactLength {
+			continue
+		}
+		if _, ok := sensitiveValues[value]; !ok {
+			sensitiveValues[value] = struct{}{}
+			redactor = nil
+		}
+	}
+}
+
+// SensitiveValues returns the values registered with AddSensitive.
+func SensitiveValues() []string {
+	sensitiveLock.RLock()
+	defer sensitiveLock.RUnlock()
+
+	return slices.Collect(maps.Keys(sensitiveValues))
+}
+
+// WithSensitive returns a context whose log messages also have the values returned by values
+// replaced with [REDACTED].
+func WithSensitive(ctx context.Context, values func() []string) context.Context {
+	return context.WithValue(ctx, sensitiveKey{}, values)
+}
+
+// Redact replaces the sensitive values in s.
+func Redact(s string) string {
+	if r := globalRedactor(); r != nil {
+		return r.Replace(s)
+	}
+	return s
+}
+
+// redact replaces the sensitive values and the sensitive values of the context in s.
+func redact(ctx context.Context, s string) string {
+	s = Redact(s)
+	if values, ok := ctx.Value(sensitiveKey{}).(func() []string); ok {
+		if r := newRedactor(values()); r != nil {
+			s = r.Replace(s)
+		}
+	}
+	return s
+}
+
+func globalRedactor() *strings.Replacer {
+	sensitiveLock.RLock()
+	r, empty := redactor, len(sensitiveValues) == 0
+	sensitiveLock.RUnlock()
+	if r != nil || empty {
+		return r
+	}
+
+	sensitiveLock.Lock()
+	defer sensitiveLock.Unlock()
+	if redactor == nil {
+		redactor = newRedactor(slices.Collect(maps.Keys(sensitiveValues)))
+	}
+	return redactor
+}
+
+// newRedactor returns a replacer of the values, or nil if none of them is long enough.
+func newRedactor(values []string) *strings.Replacer {
+	// Longer values first, so a value containing another one is replaced as a whole
+	slices.SortFunc(values, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	oldNew := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		if len(value) >= MinRedactLength {
+			oldNew = append(oldNew, value, Redacted)
+		}
+	}
+	if len(oldNew) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(oldNew...)
+}
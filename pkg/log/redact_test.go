@@ -0,0 +1,48 @@
+package log
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedactContext(t *testing.T) {
+	AddSensitive("process-secret", "abc")
+
+	session := []string{"session-token", "session-token-long", "xyz"}
+	ctx := WithSensitive(context.Background(), func() []string {
+		return session
+	})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		in       string
+		expected string
+	}{
+		{
+			name:     "process values",
+			ctx:      context.Background(),
+			in:       "process-secret session-token abc",
+			expected: "[REDACTED] session-token abc",
+		},
+		{
+			name:     "context values",
+			ctx:      ctx,
+			in:       "process-secret session-token-long session-token xyz",
+			expected: "[REDACTED] [REDACTED] [REDACTED] xyz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.ctx, tt.in); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	// The replacer is rebuilt once values are added
+	AddSensitive("another-secret")
+	if got := Redact("another-secret"); got != Redacted {
+		t.Errorf("expected the added value to be redacted, got %q", got)
+	}
+}
@@ -22,6 +22,7 @@ type ClientOption struct {
 	Roots         []Root
 	OnSampling    func(ctx context.Context, sampling CreateMessageRequest) (CreateMessageResult, error)
 	OnRoots       func(ctx context.Context, msg Message) error
+	OnElicit      func(ctx context.Context, elicit ElicitRequest) (ElicitResult, error)
 	OnLogging     func(ctx context.Context, logMsg LoggingMessage) error
 	OnMessage     func(ctx context.Context, msg Message) error
 	OnNotify      func(ctx context.Context, msg Message) error
@@ -39,6 +40,10 @@ func (c ClientOption) Merge(other ClientOption) (result ClientOption) {
 	if other.OnRoots != nil {
 		result.OnRoots = other.OnRoots
 	}
+	result.OnElicit = c.OnElicit
+	if other.OnElicit != nil {
+		result.OnElicit = other.OnElicit
+	}
 	result.OnLogging = c.OnLogging
 	if other.OnLogging != nil {
 		result.OnLogging = other.OnLogging
@@ -115,6 +120,22 @@ func toHandler(opts ClientOption) MessageHandler {
 					log.Errorf(ctx, "failed to reply to sampling/createMessage: %v", err)
 				}
 			}()
+		} else if msg.Method == "elicitation/create" && opts.OnElicit != nil {
+			var param ElicitRequest
+			if err := json.Unmarshal(msg.Params, &param); err != nil {
+				msg.SendError(ctx, fmt.Errorf("failed to unmarshal elicitation/create: %w", err))
+				return
+			}
+			go func() {
+				resp, err := opts.OnElicit(ctx, param)
+				if err != nil {
+					msg.SendError(ctx, fmt.Errorf("failed to handle elicitation/create: %w", err))
+					return
+				}
+				if err := msg.Reply(ctx, resp); err != nil {
+					log.Errorf(ctx, "failed to reply to elicitation/create: %v", err)
+				}
+			}()
 		} else if msg.Method == "roots/list" && opts.OnRoots != nil {
 			go func() {
 				if err := opts.OnRoots(ctx, msg); err != nil {
@@ -177,6 +198,8 @@ func NewSession(ctx context.Context, serverName string, config Server, opts ...C
 		opt  = complete.Complete(opts...)
 	)
 
+	ctx = log.WithComponent(ctx, "mcp:"+serverName)
+
 	if config.Command == "" && config.BaseURL == "" {
 		return nil, fmt.Errorf("no command or base URL provided")
 	} else if config.BaseURL != "" {
@@ -198,11 +221,17 @@ func NewSession(ctx context.Context, serverName string, config Server, opts ...C
 		}
 	}
 
-	session, err := newSession(ctx, wire, toHandler(opt), opt.SessionID, nil)
+	var recorder Recorder
+	if opt.ParentSession != nil {
+		recorder = opt.ParentSession.recorder
+	}
+
+	session, err := newSession(ctx, wire, toHandler(opt), opt.SessionID, recorder)
 	if err != nil {
 		return nil, err
 	}
 	session.Parent = opt.ParentSession
+	session.serverName = serverName
 	return session, nil
 }
 
@@ -221,20 +250,25 @@ func NewClient(ctx context.Context, serverName string, config Server, opts ...Cl
 	}
 
 	var (
-		sampling *struct{}
-		roots    *RootsCapability
+		sampling    *struct{}
+		elicitation *struct{}
+		roots       *RootsCapability
 	)
 	if opt.OnSampling != nil {
 		sampling = &struct{}{}
 	}
+	if opt.OnElicit != nil {
+		elicitation = &struct{}{}
+	}
 	if opt.OnRoots != nil {
 		roots = &RootsCapability{}
 	}
 	_, err = c.Initialize(ctx, InitializeRequest{
 		ProtocolVersion: "2025-03-26",
 		Capabilities: ClientCapabilities{
-			Sampling: sampling,
-			Roots:    roots,
+			Sampling:    sampling,
+			Elicitation: elicitation,
+			Roots:       roots,
 		},
 		ClientInfo: ClientInfo{
 			Name:    "nanobot",
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
@@ -81,6 +82,7 @@ func (s *HTTPClient) newRequest(ctx context.Context, method string, in any) (*ht
 	for k, v := range s.headers {
 		req.Header.Set(k, v)
 	}
+	tracing.Inject(ctx, req.Header)
 	req.Header.Set("Accept", "text/event-stream")
 	if method != http.MethodGet {
 		// Don't add because some *cough* CloudFront *cough* proxies don't like it
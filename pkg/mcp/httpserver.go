@@ -9,11 +9,15 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 type HTTPServer struct {
 	env            map[string]string
 	MessageHandler MessageHandler
+	Recorder       Recorder
 	sessions       sync.Map
 }
 
@@ -98,6 +102,12 @@ func (h *HTTPServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Requests are handled with the context of the session, so the trace context of the HTTP request is
+	// passed along in the message.
+	if traceParent := req.Header.Get("traceparent"); traceParent != "" && msg.Method != "" && msg.TraceParent() == "" {
+		_ = msg.SetTraceParent(traceParent)
+	}
+
 	if streamingID != "" {
 		session, ok := h.sessions.Load(streamingID)
 		if !ok {
@@ -148,7 +158,7 @@ func (h *HTTPServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	session, err := newServerSession(context.Background(), h.MessageHandler)
+	session, err := newServerSession(context.Background(), h.MessageHandler, h.Recorder)
 	if err != nil {
 		http.Error(rw, "Failed to create session: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -163,6 +173,12 @@ func (h *HTTPServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	h.sessions.Store(session.session.sessionID, session)
+	metrics.ActiveSessions.Inc()
+	// However the session ends, it is no longer active
+	context.AfterFunc(session.session.ctx, func() {
+		h.sessions.CompareAndDelete(session.session.sessionID, session)
+		metrics.ActiveSessions.Dec()
+	})
 
 	rw.Header().Set("Mcp-Session-Id", session.session.sessionID)
 	rw.Header().Set("Content-Type", "application/json")
@@ -173,16 +189,25 @@ func (h *HTTPServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 }
 
 func (h *HTTPServer) getEnv(req *http.Request) map[string]string {
-	env := make(map[string]string)
-	maps.Copy(env, h.env)
+	return EnvFromRequest(h.env, req)
+}
+
+// EnvFromRequest returns a copy of env with the bearer token and X-Nanobot-Env-* headers of the
+// request added. Secret references are dropped, clients must not be able to read files or run
+// commands on the server.
+func EnvFromRequest(env map[string]string, req *http.Request) map[string]string {
+	result := make(map[string]string)
+	maps.Copy(result, env)
 	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
-	if ok {
-		env["http:bearer-token"] = token
+	if ok && !secrets.IsReference(token) {
+		result[bearerTokenEnvKey] = token
 	}
 	for k, v := range req.Header {
 		if key, ok := strings.CutPrefix(k, "X-Nanobot-Env-"); ok {
-			env[key] = strings.Join(v, ", ")
+			if value := strings.Join(v, ", "); !secrets.IsReference(value) {
+				result[key] = value
+			}
 		}
 	}
-	return env
+	return result
 }
@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+)
+
+func activeSessions(t *testing.T) string {
+	t.Helper()
+	var out strings.Builder
+	if err := metrics.Default.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, "nanobot_active_sessions "); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestActiveSessions(t *testing.T) {
+	srv := NewHTTPServer(nil, MessageHandlerFunc(func(ctx context.Context, msg Message) {
+		_ = msg.Reply(ctx, InitializeResult{ProtocolVersion: "2025-06-18"})
+	}))
+
+	initialize := func() string {
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)))
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to initialize: %d %s", rw.Code, rw.Body)
+		}
+		return rw.Header().Get("Mcp-Session-Id")
+	}
+
+	deleted, closed := initialize(), initialize()
+	if got := activeSessions(t); got != "2" {
+		t.Fatalf("expected 2 active sessions, got %s", got)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set("Mcp-Session-Id", deleted)
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Sessions closed without a DELETE request are also no longer active
+	s, _ := srv.sessions.Load(closed)
+	s.(*serverSession).session.Close()
+
+	for i := 0; activeSessions(t) != "0"; i++ {
+		if i > 100 {
+			t.Fatalf("expected 0 active sessions, got %s", activeSessions(t))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := srv.sessions.Load(closed); ok {
+		t.Error("expected the closed session to be removed")
+	}
+}
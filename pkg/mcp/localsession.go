@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+)
+
+var _ wire = (*localWire)(nil)
+
+// NewLocalSession creates a session that is driven from within the process instead of by a remote
+// client. Everything sent to the client, such as progress notifications, is passed to onSend.
+// Requests to the client, like sampling or roots, are not supported.
+func NewLocalSession(ctx context.Context, sessionID string, onSend func(ctx context.Context, msg Message) error, recorder Recorder) (*Session, error) {
+	return newSession(ctx, &localWire{
+		onSend: onSend,
+	}, nil, sessionID, recorder)
+}
+
+type localWire struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	onSend func(ctx context.Context, msg Message) error
+}
+
+func (l *localWire) Close() {
+	l.cancel()
+}
+
+func (l *localWire) Wait() {
+	<-l.ctx.Done()
+}
+
+func (l *localWire) Start(ctx context.Context, _ wireHandler) error {
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	return nil
+}
+
+func (l *localWire) Send(ctx context.Context, msg Message) error {
+	if msg.Method != "" && msg.ID != nil {
+		return fmt.Errorf("request %s is not supported by a local session", msg.Method)
+	}
+	if l.onSend == nil {
+		return nil
+	}
+	return l.onSend(ctx, msg)
+}
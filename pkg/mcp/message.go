@@ -42,10 +42,35 @@ func (r *Message) IsRequest() bool {
 }
 
 func (r *Message) SetProgressToken(token any) error {
+	return r.setMeta("progressToken", token)
+}
+
+// SetTraceParent sets the W3C traceparent of the request in _meta so that the handler of the
+// message can continue the trace.
+func (r *Message) SetTraceParent(traceParent string) error {
+	return r.setMeta("traceparent", traceParent)
+}
+
+func (r *Message) TraceParent() string {
+	if len(r.Params) == 0 || !bytes.Contains(r.Params, []byte("traceparent")) {
+		return ""
+	}
+	var meta struct {
+		Meta struct {
+			TraceParent string `json:"traceparent"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(r.Params, &meta); err == nil {
+		return meta.Meta.TraceParent
+	}
+	return ""
+}
+
+func (r *Message) setMeta(key string, value any) error {
 	params := map[string]any{}
 	if len(r.Params) > 0 {
 		if err := json.Unmarshal(r.Params, &params); err != nil {
-			return fmt.Errorf("failed to unmarshal params to set progress token: %w", err)
+			return fmt.Errorf("failed to unmarshal params to set %s: %w", key, err)
 		}
 	}
 
@@ -54,11 +79,11 @@ func (r *Message) SetProgressToken(token any) error {
 		meta = make(map[string]any)
 	}
 
-	meta["progressToken"] = token
+	meta[key] = value
 	params["_meta"] = meta
 	data, err := json.Marshal(params)
 	if err != nil {
-		return fmt.Errorf("failed to marshal params to set progress token: %w", err)
+		return fmt.Errorf("failed to marshal params to set %s: %w", key, err)
 	}
 
 	r.Params = data
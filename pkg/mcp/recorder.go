@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Recorder receives every JSON-RPC message that is sent or received by a session. Child sessions
+// inherit the recorder of their parent session.
+type Recorder interface {
+	Record(ctx context.Context, record Record)
+}
+
+type Record struct {
+	Time            time.Time       `json:"time"`
+	SessionID       string          `json:"sessionId"`
+	ParentSessionID string          `json:"parentSessionId,omitempty"`
+	Server          string          `json:"server,omitempty"`
+	Direction       string          `json:"direction"`
+	Message         json.RawMessage `json:"message"`
+}
+
+func (s *Session) record(ctx context.Context, send bool, msg Message) {
+	if s.recorder == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf(ctx, "failed to marshal message for recording: %v", err)
+		return
+	}
+
+	direction := DirectionIn
+	if send {
+		direction = DirectionOut
+	}
+
+	s.recorder.Record(ctx, Record{
+		Time:            time.Now().UTC(),
+		SessionID:       s.sessionID,
+		ParentSessionID: s.Parent.ID(),
+		Server:          s.serverName,
+		Direction:       direction,
+		Message:         Redact(data, s.SensitiveValues()),
+	})
+}
+
+// Redact replaces all the occurrences of the given values in the strings of the JSON data.
+func Redact(data []byte, values []string) []byte {
+	var oldNew []string
+	// Longer values first, so a value containing another one is replaced as a whole
+	for _, value := range slices.SortedFunc(slices.Values(values), func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	}) {
+		if len(value) < log.MinRedactLength {
+			continue
+		}
+		quoted, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		// Strip the quotes so that values embedded in larger strings are also found
+		if bytes.Contains(data, quoted[1:len(quoted)-1]) {
+			oldNew = append(oldNew, value, log.Redacted)
+		}
+	}
+	if len(oldNew) == 0 {
+		return data
+	}
+
+	// Only strings are redacted, so a value such as true can not break the JSON
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return data
+	}
+	result, err := json.Marshal(redactStrings(v, strings.NewReplacer(oldNew...)))
+	if err != nil {
+		return data
+	}
+	return result
+}
+
+func redactStrings(v any, r *strings.Replacer) any {
+	switch v := v.(type) {
+	case string:
+		return r.Replace(v)
+	case []any:
+		for i := range v {
+			v[i] = redactStrings(v[i], r)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = redactStrings(v[k], r)
+		}
+	}
+	return v
+}
@@ -0,0 +1,40 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	data := []byte(`{"token":"s3cret-value","text":"use s3cret-value and s3cret","enabled":true,"count":1234,"short":"abc"}`)
+	got := string(Redact(data, []string{"s3cret", "s3cret-value", "true", "1234", "abc"}))
+	want := `{"count":1234,"enabled":true,"short":"abc","text":"use [REDACTED] and [REDACTED]","token":"[REDACTED]"}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := string(Redact([]byte(`{"a":"b"}`), []string{"missing"})); got != `{"a":"b"}` {
+		t.Errorf("expected data without sensitive values to be unchanged, got %s", got)
+	}
+}
+
+func TestSensitiveValues(t *testing.T) {
+	session := NewEmptySession(context.Background(), "id")
+	session.EnvMap()["API_KEY"] = "key-value"
+	session.EnvMap()["REGION"] = "us-east"
+
+	// Before the env is reconciled with the config, everything is sensitive
+	values := session.SensitiveValues()
+	if !slices.Contains(values, "key-value") || !slices.Contains(values, "us-east") {
+		t.Errorf("expected all env values to be sensitive, got %v", values)
+	}
+
+	session.Set(SessionSensitiveEnvKey, []string{"API_KEY"})
+	child := NewEmptySession(context.Background(), "child")
+	child.Parent = session
+	values = child.SensitiveValues()
+	if !slices.Contains(values, "key-value") || slices.Contains(values, "us-east") {
+		t.Errorf("expected only the sensitive env values of the root session, got %v", values)
+	}
+}
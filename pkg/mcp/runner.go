@@ -16,6 +16,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/mcp/sandbox"
 	"github.com/nanobot-ai/nanobot/pkg/supervise"
 	"github.com/nanobot-ai/nanobot/pkg/system"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 )
 
 type runner struct {
@@ -186,7 +187,14 @@ func (r *runner) doStream(ctx context.Context, serverName string, cmd *sandbox.C
 	}, nil
 }
 
-func (r *runner) Run(ctx context.Context, roots []Root, env map[string]string, serverName string, config Server) (Server, error) {
+func startSpan(ctx context.Context, serverName string, config Server) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "start "+serverName,
+		"nanobot.mcp.server", serverName,
+		"nanobot.mcp.command", config.Command,
+		"nanobot.mcp.sandboxed", !config.Unsandboxed && config.Command != "nanobot")
+}
+
+func (r *runner) Run(ctx context.Context, roots []Root, env map[string]string, serverName string, config Server) (_ Server, err error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
@@ -194,6 +202,11 @@ func (r *runner) Run(ctx context.Context, roots []Root, env map[string]string, s
 		return c, nil
 	}
 
+	ctx, span := startSpan(ctx, serverName, config)
+	defer func() {
+		span.End(err)
+	}()
+
 	newConfig, cmd, err := r.newCommand(ctx, env, roots, config)
 	if err != nil {
 		return config, err
@@ -202,7 +215,12 @@ func (r *runner) Run(ctx context.Context, roots []Root, env map[string]string, s
 	return r.doRun(ctx, serverName, newConfig, cmd)
 }
 
-func (r *runner) Stream(ctx context.Context, roots []Root, env map[string]string, serverName string, config Server) (*streamResult, error) {
+func (r *runner) Stream(ctx context.Context, roots []Root, env map[string]string, serverName string, config Server) (_ *streamResult, err error) {
+	ctx, span := startSpan(ctx, serverName, config)
+	defer func() {
+		span.End(err)
+	}()
+
 	ctx, cancel := context.WithCancel(ctx)
 	_, cmd, err := r.newCommand(ctx, env, roots, config)
 	if err != nil {
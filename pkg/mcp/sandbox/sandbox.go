@@ -12,8 +12,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/supervise"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"github.com/nanobot-ai/nanobot/pkg/version"
@@ -56,12 +58,19 @@ type Cmd struct {
 	*exec.Cmd
 	cancel    func()
 	postStart func() error
+	container bool
+	running   atomic.Bool
 }
 
 func (c *Cmd) Wait() error {
 	if c.cancel != nil {
 		defer c.cancel()
 	}
+	defer func() {
+		if c.running.CompareAndSwap(true, false) {
+			metrics.SandboxContainers.Dec()
+		}
+	}()
 	return c.Cmd.Wait()
 }
 
@@ -69,16 +78,19 @@ func (c *Cmd) Start() error {
 	if err := c.Cmd.Start(); err != nil {
 		return err
 	}
-	if c.postStart == nil {
-		return nil
-	}
 
-	if err := c.postStart(); err != nil {
-		c.cancel()
-		_ = c.Wait()
-		return fmt.Errorf("post-start hook failed: %w", err)
+	if c.postStart != nil {
+		if err := c.postStart(); err != nil {
+			c.cancel()
+			_ = c.Wait()
+			return fmt.Errorf("post-start hook failed: %w", err)
+		}
 	}
 
+	if c.container {
+		c.running.Store(true)
+		metrics.SandboxContainers.Inc()
+	}
 	return nil
 }
 
@@ -153,8 +165,9 @@ func NewCmd(ctx context.Context, sandbox Command) (*Cmd, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	cmd := supervise.Cmd(ctx, "docker", dockerArgs...)
 	return &Cmd{
-		cancel: cancel,
-		Cmd:    cmd,
+		cancel:    cancel,
+		Cmd:       cmd,
+		container: true,
 		postStart: func() error {
 			for _, port := range sandbox.ReversePorts {
 				if err := startReversePort(ctx, containerName, port, cancel); err != nil {
@@ -166,6 +179,60 @@ func NewCmd(ctx context.Context, sandbox Command) (*Cmd, error) {
 	}, nil
 }
 
+// DefaultImage returns the image a command runs in when it sets no image.
+func DefaultImage() string {
+	return version.BaseImage
+}
+
+// ImageDigest pulls the image and returns the digest of it in the registry, such as sha256:1a2b...
+func ImageDigest(ctx context.Context, image string) (string, error) {
+	if !validChars.MatchString(image) {
+		return "", fmt.Errorf("invalid image: %s", image)
+	}
+	if out, err := exec.CommandContext(ctx, "docker", "pull", "-q", image).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %w, output: %s", image, err, string(out))
+	}
+	out, err := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{join .RepoDigests \"\\n\"}}", image).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return repoDigest(image, strings.Fields(string(out)))
+}
+
+// repoDigest returns the digest of the repo digest, such as ghcr.io/org/image@sha256:1a2b..., in
+// the repository of the image. An image pulled from several registries has a digest for each.
+func repoDigest(image string, repoDigests []string) (string, error) {
+	repo := repository(image)
+	for _, ref := range repoDigests {
+		name, digest, ok := strings.Cut(ref, "@")
+		if ok && repository(name) == repo {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("image %s has no digest in registry repository %s", image, repo)
+}
+
+// repository returns the full repository of an image reference without its tag and digest, such
+// as docker.io/library/alpine for alpine:3.
+func repository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	domain, path, ok := strings.Cut(image, "/")
+	if !ok || (!strings.ContainsAny(domain, ".:") && domain != "localhost") {
+		domain, path = "docker.io", image
+	}
+	if domain == "index.docker.io" {
+		domain = "docker.io"
+	}
+	if domain == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return domain + "/" + path
+}
+
 func buildImage(ctx context.Context, baseImage string, config Command) (string, error) {
 	var (
 		source   = config.Source.Repo
@@ -0,0 +1,42 @@
+package sandbox
+
+import "testing"
+
+func TestRepoDigest(t *testing.T) {
+	repoDigests := []string{
+		"ghcr.io/org/tool@sha256:aaa",
+		"alpine@sha256:bbb",
+		"localhost:5000/tool@sha256:ccc",
+		"org/tool@sha256:ddd",
+	}
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "ghcr.io/org/tool", expected: "sha256:aaa"},
+		{image: "ghcr.io/org/tool:v1", expected: "sha256:aaa"},
+		{image: "alpine", expected: "sha256:bbb"},
+		{image: "alpine:3", expected: "sha256:bbb"},
+		{image: "docker.io/library/alpine:3", expected: "sha256:bbb"},
+		{image: "index.docker.io/library/alpine", expected: "sha256:bbb"},
+		{image: "localhost:5000/tool:latest", expected: "sha256:ccc"},
+		{image: "org/tool", expected: "sha256:ddd"},
+		{image: "docker.io/org/tool@sha256:ddd", expected: "sha256:ddd"},
+		{image: "quay.io/org/tool"},
+		{image: "tool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			digest, err := repoDigest(tt.image, repoDigests)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("expected no digest, got %s", digest)
+				}
+				return
+			}
+			if err != nil || digest != tt.expected {
+				t.Errorf("expected %s, got %s, %v", tt.expected, digest, err)
+			}
+		})
+	}
+}
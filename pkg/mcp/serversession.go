@@ -9,12 +9,12 @@ import (
 
 var _ wire = (*serverWire)(nil)
 
-func newServerSession(ctx context.Context, handler MessageHandler) (*serverSession, error) {
+func newServerSession(ctx context.Context, handler MessageHandler, recorder Recorder) (*serverSession, error) {
 	s := &serverWire{
 		read: make(chan Message),
 	}
 	id := uuid.String()
-	session, err := newSession(ctx, s, handler, id, nil)
+	session, err := newSession(ctx, s, handler, id, recorder)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +60,7 @@ type serverWire struct {
 }
 
 func (s *serverWire) exchange(ctx context.Context, msg Message) (Message, error) {
-	ch := s.pending.waitFor(msg.ID)
+	ch := s.pending.waitFor(ctx, msg.ID)
 	defer s.pending.done(msg.ID)
 
 	go func() {
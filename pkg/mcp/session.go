@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
@@ -32,19 +36,36 @@ type wireHandler func(msg Message)
 type pendingRequest struct {
 	lock sync.Mutex
 	ids  map[any]chan Message
+	ctxs map[any]context.Context
 }
 
-func (p *pendingRequest) waitFor(id any) chan Message {
+func (p *pendingRequest) waitFor(ctx context.Context, id any) chan Message {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	if p.ids == nil {
 		p.ids = make(map[any]chan Message)
+		p.ctxs = make(map[any]context.Context)
 	}
 	ch := make(chan Message, 1)
 	p.ids[id] = ch
+	p.ctxs[id] = ctx
 	return ch
 }
 
+// only returns the context of the request if there is exactly one pending request.
+func (p *pendingRequest) only() (context.Context, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.ctxs) != 1 {
+		return nil, false
+	}
+	for _, ctx := range p.ctxs {
+		return ctx, true
+	}
+	return nil, false
+}
+
 func (p *pendingRequest) notify(msg Message) bool {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -58,6 +79,7 @@ func (p *pendingRequest) notify(msg Message) bool {
 		default:
 		}
 		delete(p.ids, msg.ID)
+		delete(p.ctxs, msg.ID)
 	}
 	return false
 }
@@ -67,6 +89,7 @@ func (p *pendingRequest) done(id any) {
 	defer p.lock.Unlock()
 
 	delete(p.ids, id)
+	delete(p.ctxs, id)
 }
 
 var sessionKey = struct{}{}
@@ -86,7 +109,8 @@ func WithSession(ctx context.Context, s *Session) context.Context {
 	if s == nil {
 		return ctx
 	}
-	return context.WithValue(ctx, sessionKey, s)
+	// The sensitive env values of the session are redacted from what is logged for it
+	return log.WithSensitive(context.WithValue(ctx, sessionKey, s), s.SensitiveValues)
 }
 
 type Session struct {
@@ -97,14 +121,19 @@ type Session struct {
 	pendingRequest     pendingRequest
 	ClientCapabilities *ClientCapabilities
 	InitializeResult   *InitializeResult
-	recorder           *recorder
+	recorder           Recorder
 	sessionID          string
+	serverName         string
 	Parent             *Session
 	attributes         map[string]any
 	lock               sync.Mutex
 }
 
-const SessionEnvMapKey = "env"
+const (
+	SessionEnvMapKey       = "env"
+	SessionSensitiveEnvKey = "sensitiveEnv"
+	bearerTokenEnvKey      = "http:bearer-token"
+)
 
 func (s *Session) EnvMap() map[string]string {
 	if s == nil {
@@ -122,6 +151,34 @@ func (s *Session) EnvMap() map[string]string {
 	return env
 }
 
+// SensitiveValues returns the values of the env vars that were marked sensitive on the root
+// session. The bearer token and the secrets resolved by the process are always considered
+// sensitive. Until the env of the session is reconciled with the config, all of its values are
+// considered sensitive.
+func (s *Session) SensitiveValues() []string {
+	if s == nil {
+		return log.SensitiveValues()
+	}
+	for s.Parent != nil {
+		s = s.Parent
+	}
+
+	env := s.EnvMap()
+	keys, ok := s.Get(SessionSensitiveEnvKey).([]string)
+	if !ok {
+		keys = slices.Collect(maps.Keys(env))
+	}
+	keys = append(keys, bearerTokenEnvKey)
+
+	values := log.SensitiveValues()
+	for _, key := range keys {
+		if val := env[key]; val != "" {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
 func (s *Session) Set(key string, value any) {
 	if s == nil {
 		return
@@ -150,6 +207,16 @@ func (s *Session) ID() string {
 	return s.sessionID
 }
 
+// RequestContext returns the context of the request the session is waiting for a response to.
+// Requests from the server, such as sampling, do not say which request they are made for, so
+// there is no context if more than one request is waiting.
+func (s *Session) RequestContext() (context.Context, bool) {
+	if s == nil {
+		return nil, false
+	}
+	return s.pendingRequest.only()
+}
+
 func (s *Session) Close() {
 	if s.wire != nil {
 		s.wire.Close()
@@ -165,6 +232,11 @@ func (s *Session) Wait() {
 	s.wire.Wait()
 }
 
+// Done is closed when the session is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
 func (s *Session) normalizeProgress(progress *NotificationProgressRequest) {
 	var (
 		progressKey     = fmt.Sprintf("progress-token:%v", progress.ProgressToken)
@@ -220,7 +292,11 @@ func (s *Session) Send(ctx context.Context, req Message) error {
 		}
 		s.ClientCapabilities = &init.Capabilities
 	}
-	s.recorder.save(ctx, s.sessionID, true, req)
+	if strings.HasPrefix(req.Method, "notifications/") {
+		// Progress and log messages can echo env values and the output of tools using them
+		req.Params = Redact(req.Params, s.SensitiveValues())
+	}
+	s.record(ctx, true, req)
 	return s.wire.Send(ctx, req)
 }
 
@@ -256,7 +332,7 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 		}
 	}
 
-	ch := s.pendingRequest.waitFor(req.ID)
+	ch := s.pendingRequest.waitFor(ctx, req.ID)
 	defer s.pendingRequest.done(req.ID)
 
 	if err := s.Send(ctx, *req); err != nil {
@@ -272,7 +348,7 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 			return nil
 		}
 		if m.Error != nil {
-			return fmt.Errorf("error from server: %s", m.Error.Message)
+			return serverError{err: m.Error}
 		}
 		if m.Result == nil {
 			return fmt.Errorf("no result in response")
@@ -284,8 +360,22 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 	}
 }
 
+// serverError is an error response to a request. The RPCError with the code and data of the
+// error can be retrieved with errors.As.
+type serverError struct {
+	err *RPCError
+}
+
+func (e serverError) Error() string {
+	return "error from server: " + e.err.Message
+}
+
+func (e serverError) Unwrap() error {
+	return e.err
+}
+
 func (s *Session) onWire(message Message) {
-	s.recorder.save(s.ctx, s.sessionID, false, message)
+	s.record(s.ctx, false, message)
 	message.Session = s
 	if s.pendingRequest.notify(message) {
 		return
@@ -301,7 +391,10 @@ func NewEmptySession(ctx context.Context, sessionID string) *Session {
 	return s
 }
 
-func newSession(ctx context.Context, wire wire, handler MessageHandler, sessionID string, r *recorder) (*Session, error) {
+func newSession(ctx context.Context, wire wire, handler MessageHandler, sessionID string, r Recorder) (*Session, error) {
+	if sessionID != "" {
+		ctx = log.WithFields(ctx, "sessionId", sessionID)
+	}
 	s := &Session{
 		wire:      wire,
 		handler:   handler,
@@ -311,9 +404,3 @@ func newSession(ctx context.Context, wire wire, handler MessageHandler, sessionI
 	s.ctx, s.cancel = context.WithCancel(WithSession(ctx, s))
 	return s, wire.Start(s.ctx, s.onWire)
 }
-
-type recorder struct {
-}
-
-func (r *recorder) save(ctx context.Context, sessionID string, send bool, msg Message) {
-}
@@ -0,0 +1,31 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestRequestContext(t *testing.T) {
+	var p pendingRequest
+	if _, ok := p.only(); ok {
+		t.Error("expected no context without pending requests")
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "first")
+	p.waitFor(ctx, 1)
+	if got, ok := p.only(); !ok || got.Value(ctxKey{}) != "first" {
+		t.Errorf("expected the context of the only pending request, got %v", got)
+	}
+
+	p.waitFor(context.Background(), 2)
+	if _, ok := p.only(); ok {
+		t.Error("expected no context with two pending requests")
+	}
+
+	p.done(1)
+	if got, ok := p.only(); !ok || got.Value(ctxKey{}) != nil {
+		t.Errorf("expected the context of the remaining request, got %v", got)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 type StdioServer struct {
 	MessageHandler MessageHandler
+	Recorder       Recorder
 	stdio          *Stdio
 	env            map[string]string
 }
@@ -30,7 +31,7 @@ func (s *StdioServer) Wait() {
 }
 
 func (s *StdioServer) Start(ctx context.Context, in io.ReadCloser, out io.WriteCloser) error {
-	session, err := newServerSession(ctx, s.MessageHandler)
+	session, err := newServerSession(ctx, s.MessageHandler, s.Recorder)
 	if err != nil {
 		return fmt.Errorf("failed to create stdio session: %w", err)
 	}
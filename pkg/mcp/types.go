@@ -5,8 +5,9 @@ import (
 )
 
 type ClientCapabilities struct {
-	Roots    *RootsCapability `json:"roots,omitempty"`
-	Sampling *struct{}        `json:"sampling,omitzero"`
+	Roots       *RootsCapability `json:"roots,omitempty"`
+	Sampling    *struct{}        `json:"sampling,omitzero"`
+	Elicitation *struct{}        `json:"elicitation,omitzero"`
 }
 
 type RootsCapability struct {
@@ -96,6 +97,22 @@ type Root struct {
 	Name string `json:"name,omitempty"`
 }
 
+type ElicitRequest struct {
+	Message         string          `json:"message"`
+	RequestedSchema json.RawMessage `json:"requestedSchema"`
+}
+
+const (
+	ElicitActionAccept  = "accept"
+	ElicitActionDecline = "decline"
+	ElicitActionCancel  = "cancel"
+)
+
+type ElicitResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
 type LoggingMessage struct {
 	Level  string `json:"level"`
 	Logger string `json:"logger,omitempty"`
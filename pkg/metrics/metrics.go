@@ -0,0 +1,40 @@
+package metrics
+
+var (
+	ActiveSessions = NewGauge("nanobot_active_sessions",
+		"Number of active MCP sessions on the HTTP server")
+	ToolCalls = NewCounter("nanobot_tool_calls_total",
+		"Number of tool calls by server, tool, type (mcp, agent, flow) and status (ok, error)",
+		"server", "tool", "type", "status")
+	ToolCallDuration = NewHistogram("nanobot_tool_call_duration_seconds",
+		"Duration of tool calls in seconds", nil,
+		"server", "tool")
+	LLMRequests = NewCounter("nanobot_llm_requests_total",
+		"Number of LLM requests by model and status (ok, error)",
+		"model", "status")
+	LLMRequestDuration = NewHistogram("nanobot_llm_request_duration_seconds",
+		"Duration of LLM requests in seconds", nil,
+		"model")
+	LLMTokens = NewCounter("nanobot_llm_tokens_total",
+		"Number of LLM tokens by model and type (input, output)",
+		"model", "type")
+	FlowExecutions = NewCounter("nanobot_flow_executions_total",
+		"Number of flow executions by flow and status (ok, error)",
+		"flow", "status")
+	TriggerRuns = NewCounter("nanobot_trigger_runs_total",
+		"Number of trigger runs by trigger and status (ok, error, skipped, unauthorized)",
+		"trigger", "status")
+	ConfirmationWait = NewHistogram("nanobot_confirmation_wait_seconds",
+		"Time spent waiting for a tool call to be confirmed, by result (accepted, rejected, timeout, canceled)",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 900},
+		"result")
+	SandboxContainers = NewGauge("nanobot_sandbox_containers",
+		"Number of running sandbox containers")
+)
+
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	counter := NewCounter("test_calls_total", "Calls by tool\nand status", "tool", "status")
+	gauge := NewGauge("test_sessions", `Sessions \ connections`)
+	histogram := NewHistogram("test_duration_seconds", "Duration", []float64{1, 0.5}, "tool")
+
+	counter.Inc(`say "hi"`, "ok")
+	counter.Add(2, `C:\tools`+"\n", "error")
+	counter.Add(-1, "ignored", "ok")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	histogram.Observe(0.2, "a")
+	histogram.Observe(0.7, "a")
+	histogram.Observe(3, "a")
+
+	r := &Registry{}
+	r.register(counter)
+	r.register(gauge)
+	r.register(histogram)
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP test_calls_total Calls by tool\nand status
+# TYPE test_calls_total counter
+test_calls_total{tool="C:\\tools\n",status="error"} 2
+test_calls_total{tool="say \"hi\"",status="ok"} 1
+# HELP test_sessions Sessions \\ connections
+# TYPE test_sessions gauge
+test_sessions 1
+# HELP test_duration_seconds Duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{tool="a",le="0.5"} 1
+test_duration_seconds_bucket{tool="a",le="1"} 2
+test_duration_seconds_bucket{tool="a",le="+Inf"} 3
+test_duration_seconds_sum{tool="a"} 3.9
+test_duration_seconds_count{tool="a"} 3
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
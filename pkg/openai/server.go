@@ -0,0 +1,580 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm/responses"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+const (
+	// sessionIdleTimeout is how long the session of a caller is kept after its last request
+	sessionIdleTimeout = 15 * time.Minute
+	// maxSessions is the number of caller sessions kept, the least recently used idle session is
+	// closed to make room for a new one
+	maxSessions = 100
+)
+
+// Server exposes the published agents and flows of a nanobot as models of an OpenAI compatible chat
+// completions API. The OpenAI API has no notion of a session, so requests of the same caller (bearer
+// token, X-Nanobot-Env-* headers and user) share a session and its MCP server connections until it
+// is idle for too long. Requests without any of these get a session of their own.
+type Server struct {
+	Recorder mcp.Recorder
+
+	runtime   *runtime.Runtime
+	env       map[string]string
+	lock      sync.Mutex
+	sessions  map[string]*callerSession
+	listeners sync.Map
+	mux       *http.ServeMux
+	now       func() time.Time
+}
+
+type callerSession struct {
+	session  *mcp.Session
+	lastUsed time.Time
+	// active is the number of requests using the session, it is not closed while in use
+	active int
+}
+
+func NewServer(r *runtime.Runtime, env map[string]string) *Server {
+	s := &Server{
+		runtime:  r,
+		env:      env,
+		sessions: map[string]*callerSession{},
+		mux:      http.NewServeMux(),
+		now:      time.Now,
+	}
+	s.mux.HandleFunc("GET /v1/models", s.listModels)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	return s
+}
+
+func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(rw, req)
+}
+
+// Close closes the sessions of all callers and their MCP servers.
+func (s *Server) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, caller := range s.sessions {
+		s.closeSession(caller.session)
+		delete(s.sessions, key)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, code int, obj any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(obj)
+}
+
+func writeError(rw http.ResponseWriter, code int, errType string, err error) {
+	writeJSON(rw, code, ErrorResponse{
+		Error: Error{
+			Message: err.Error(),
+			Type:    errType,
+		},
+	})
+}
+
+// published returns the agents and flows that are published as the entrypoint or tools of the
+// config, these are the models of the API.
+func published(c types.Config) []string {
+	var result []string
+	for _, ref := range append([]string{c.Publish.Entrypoint}, c.Publish.Tools...) {
+		name := types.ParseToolRef(ref).Server
+		if _, ok := c.Agents[name]; !ok {
+			if _, ok := c.Flows[name]; !ok {
+				continue
+			}
+		}
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (s *Server) listModels(rw http.ResponseWriter, _ *http.Request) {
+	result := ModelList{
+		Object: "list",
+		Data:   []Model{},
+	}
+
+	for _, name := range published(s.runtime.GetConfig()) {
+		result.Data = append(result.Data, Model{
+			ID:      name,
+			Object:  "model",
+			OwnedBy: "nanobot",
+		})
+	}
+
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// getSession returns the session of the caller of the request. The returned function must be
+// called when the request is done with the session.
+func (s *Server) getSession(req *http.Request, user string) (*mcp.Session, func(), error) {
+	env := mcp.EnvFromRequest(s.env, req)
+
+	if user == "" && maps.Equal(env, s.env) {
+		// Anonymous callers can not be told apart, so they do not share anything
+		session, err := s.newSession(env)
+		if err != nil {
+			return nil, nil, err
+		}
+		return session, func() {
+			s.closeSession(session)
+		}, nil
+	}
+
+	hash := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		_, _ = fmt.Fprintf(hash, "%s=%s\x00", k, env[k])
+	}
+	_, _ = fmt.Fprintf(hash, "\x00%s", user)
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.evict()
+
+	caller, ok := s.sessions[key]
+	if !ok {
+		session, err := s.newSession(env)
+		if err != nil {
+			return nil, nil, err
+		}
+		caller = &callerSession{
+			session: session,
+		}
+		s.sessions[key] = caller
+	}
+
+	caller.active++
+	caller.lastUsed = s.now()
+	return caller.session, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		caller.active--
+		caller.lastUsed = s.now()
+	}, nil
+}
+
+// evict closes the sessions that are idle for too long and, if there are too many sessions, the
+// least recently used idle session.
+func (s *Server) evict() {
+	var (
+		oldestKey string
+		oldest    *callerSession
+	)
+	for key, caller := range s.sessions {
+		if caller.active > 0 {
+			continue
+		}
+		if s.now().Sub(caller.lastUsed) > sessionIdleTimeout {
+			s.closeSession(caller.session)
+			delete(s.sessions, key)
+			continue
+		}
+		if oldest == nil || caller.lastUsed.Before(oldest.lastUsed) {
+			oldestKey, oldest = key, caller
+		}
+	}
+	if len(s.sessions) >= maxSessions && oldest != nil {
+		s.closeSession(oldest.session)
+		delete(s.sessions, oldestKey)
+	}
+}
+
+func (s *Server) newSession(env map[string]string) (*mcp.Session, error) {
+	session, err := mcp.NewLocalSession(context.Background(), "openai-"+uuid.String(), s.onSend, s.Recorder)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(session.EnvMap(), env)
+
+	if err := runtime.ReconcileEnv(session, s.runtime.GetConfig()); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *Server) closeSession(session *mcp.Session) {
+	s.runtime.Service().CloseSession(session.ID())
+	session.Close()
+}
+
+// onSend routes progress notifications to the request that owns the progress token. Everything else
+// sent to the client is dropped.
+func (s *Server) onSend(_ context.Context, msg mcp.Message) error {
+	if msg.Method != "notifications/progress" {
+		return nil
+	}
+
+	var progress mcp.NotificationProgressRequest
+	if err := json.Unmarshal(msg.Params, &progress); err != nil {
+		return nil
+	}
+
+	if listener, ok := s.listeners.Load(fmt.Sprint(progress.ProgressToken)); ok {
+		listener.(*streamState).onProgress(progress.Data)
+	}
+	return nil
+}
+
+func toCreateMessageRequest(req ChatCompletionRequest) (*mcp.CreateMessageRequest, error) {
+	var (
+		result = &mcp.CreateMessageRequest{
+			Temperature: req.Temperature,
+			MaxTokens:   req.MaxTokens,
+		}
+		systemPrompts []string
+	)
+
+	if req.MaxCompletionTokens != 0 {
+		result.MaxTokens = req.MaxCompletionTokens
+	}
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			systemPrompts = append(systemPrompts, msg.Content.String())
+			continue
+		case "user", "assistant":
+		default:
+			// tool results and function calls are produced by the agent itself
+			continue
+		}
+
+		if msg.Content.Parts == nil {
+			result.Messages = append(result.Messages, mcp.SamplingMessage{
+				Role: msg.Role,
+				Content: mcp.Content{
+					Type: "text",
+					Text: msg.Content.Text,
+				},
+			})
+			continue
+		}
+
+		for _, part := range msg.Content.Parts {
+			content := mcp.Content{
+				Type: "text",
+				Text: part.Text,
+			}
+			if part.Type == "image_url" && part.ImageURL != nil {
+				mimeType, data, ok := strings.Cut(strings.TrimPrefix(part.ImageURL.URL, "data:"), ";base64,")
+				if !ok || !strings.HasPrefix(part.ImageURL.URL, "data:") {
+					return nil, fmt.Errorf("invalid image URL, only base64 data URIs are supported")
+				}
+				content = mcp.Content{
+					Type:     "image",
+					Data:     data,
+					MIMEType: mimeType,
+				}
+			} else if part.Type != "text" {
+				return nil, fmt.Errorf("unsupported content part type %q", part.Type)
+			}
+			result.Messages = append(result.Messages, mcp.SamplingMessage{
+				Role:    msg.Role,
+				Content: content,
+			})
+		}
+	}
+
+	if len(result.Messages) == 0 {
+		return nil, fmt.Errorf("at least one user message is required")
+	}
+
+	result.SystemPrompt = strings.Join(systemPrompts, "\n\n")
+	return result, nil
+}
+
+// toFlowInput uses the last user message as the input of a flow. JSON objects are passed as is,
+// anything else is passed as the prompt.
+func toFlowInput(req ChatCompletionRequest) (any, error) {
+	for _, msg := range slices.Backward(req.Messages) {
+		if msg.Role != "user" {
+			continue
+		}
+		text := msg.Content.String()
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(text), &obj); err == nil {
+			return obj, nil
+		}
+		return map[string]any{
+			"prompt": text,
+		}, nil
+	}
+	return nil, fmt.Errorf("at least one user message is required")
+}
+
+func (m MessageContent) String() string {
+	if m.Parts == nil {
+		return m.Text
+	}
+	var texts []string
+	for _, part := range m.Parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func (s *Server) chatCompletions(rw http.ResponseWriter, req *http.Request) {
+	var (
+		chatRequest ChatCompletionRequest
+		c           = s.runtime.GetConfig()
+		args        any
+		err         error
+	)
+
+	if err := json.NewDecoder(req.Body).Decode(&chatRequest); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request_error", fmt.Errorf("failed to decode request: %w", err))
+		return
+	}
+
+	if !slices.Contains(published(c), chatRequest.Model) {
+		writeError(rw, http.StatusNotFound, "invalid_request_error", fmt.Errorf("model %q not found", chatRequest.Model))
+		return
+	}
+	if _, ok := c.Agents[chatRequest.Model]; ok {
+		args, err = toCreateMessageRequest(chatRequest)
+	} else {
+		args, err = toFlowInput(chatRequest)
+	}
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	session, release, err := s.getSession(req, chatRequest.User)
+	if err != nil {
+		var rpcErr *mcp.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == http.StatusUnauthorized {
+			writeError(rw, http.StatusUnauthorized, "authentication_error", err)
+		} else {
+			writeError(rw, http.StatusInternalServerError, "server_error", err)
+		}
+		return
+	}
+	defer release()
+
+	var (
+		ctx           = mcp.WithSession(req.Context(), session)
+		progressToken = uuid.String()
+		stream        = &streamState{
+			id:      "chatcmpl-" + uuid.String(),
+			model:   chatRequest.Model,
+			created: time.Now().Unix(),
+			// The call to the model itself is reported as a call too
+			depth: -1,
+		}
+	)
+
+	if chatRequest.Stream {
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			writeError(rw, http.StatusInternalServerError, "server_error", fmt.Errorf("streaming is not supported"))
+			return
+		}
+		stream.rw = rw
+		stream.flusher = flusher
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+		stream.send(&ResponseMessage{Role: "assistant"}, nil)
+	}
+
+	s.listeners.Store(progressToken, stream)
+	defer s.listeners.Delete(progressToken)
+
+	chatHistory := false
+	result, err := s.runtime.Service().Call(ctx, chatRequest.Model, "", args, tools.CallOptions{
+		ProgressToken: progressToken,
+		AgentOverride: types.AgentCall{
+			ChatHistory: &chatHistory,
+			Temperature: chatRequest.Temperature,
+			TopP:        chatRequest.TopP,
+		},
+	})
+	if err == nil && result.IsError {
+		err = errors.New(toText(result))
+	}
+
+	if !chatRequest.Stream {
+		if err != nil {
+			writeError(rw, http.StatusInternalServerError, "server_error", err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, ChatCompletionResponse{
+			ID:      stream.id,
+			Object:  "chat.completion",
+			Created: stream.created,
+			Model:   stream.model,
+			Choices: []Choice{
+				{
+					Message: &ResponseMessage{
+						Role:    "assistant",
+						Content: toText(result),
+					},
+					FinishReason: &[]string{"stop"}[0],
+				},
+			},
+		})
+		return
+	}
+
+	if err != nil {
+		// The status is already sent, so the error is reported in the stream
+		log.Errorf(ctx, "chat completion for model %s failed: %v", chatRequest.Model, err)
+		stream.sendRaw(ErrorResponse{
+			Error: Error{
+				Message: err.Error(),
+				Type:    "server_error",
+			},
+		})
+	} else {
+		stream.finish(toText(result))
+	}
+	stream.done()
+}
+
+func toText(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	var texts []string
+	for _, content := range result.Content {
+		if content.Type == "text" || content.Text != "" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// streamState writes the chat completion chunks of a single streaming request. Text deltas are only
+// streamed from the top level agent, output of agents called as tools is not part of the answer.
+type streamState struct {
+	id       string
+	model    string
+	created  int64
+	rw       http.ResponseWriter
+	flusher  http.Flusher
+	lock     sync.Mutex
+	depth    int
+	streamed bool
+}
+
+func (s *streamState) onProgress(data any) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.rw == nil {
+		return
+	}
+
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return
+		}
+	}
+
+	var event responses.Progress
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "nanobot/call":
+		s.depth++
+	case "nanobot/call/complete", "nanobot/toolcall/error":
+		s.depth--
+	case "response.output_text.delta":
+		if s.depth == 0 && event.Delta != "" {
+			s.streamed = true
+			s.sendLocked(&ResponseMessage{Content: event.Delta}, nil)
+		}
+	}
+}
+
+func (s *streamState) finish(text string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.streamed && text != "" {
+		s.sendLocked(&ResponseMessage{Content: text}, nil)
+	}
+	s.sendLocked(&ResponseMessage{}, &[]string{"stop"}[0])
+}
+
+func (s *streamState) send(delta *ResponseMessage, finishReason *string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sendLocked(delta, finishReason)
+}
+
+func (s *streamState) sendLocked(delta *ResponseMessage, finishReason *string) {
+	s.sendRawLocked(ChatCompletionResponse{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []Choice{
+			{
+				Delta:        delta,
+				FinishReason: finishReason,
+			},
+		},
+	})
+}
+
+func (s *streamState) sendRaw(obj any) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sendRawLocked(obj)
+}
+
+func (s *streamState) sendRawLocked(obj any) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(s.rw, "data: %s\n\n", data)
+	s.flusher.Flush()
+}
+
+func (s *streamState) done() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, _ = fmt.Fprint(s.rw, "data: [DONE]\n\n")
+	s.flusher.Flush()
+	// Late progress notifications must not write to the finished response
+	s.rw = nil
+}
@@ -0,0 +1,183 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	c := types.Config{
+		Publish: types.Publish{
+			Entrypoint: "assistant",
+			Tools:      types.StringList{"echo"},
+		},
+		Agents: map[string]types.Agent{
+			"assistant": {},
+			"internal":  {},
+		},
+		Flows: map[string]types.Flow{
+			"echo": {
+				Steps: []types.Step{{Script: `return "echo: " + input.prompt`}},
+			},
+			"hidden": {
+				Steps: []types.Step{{Script: `return "hidden"`}},
+			},
+		},
+	}
+	s := NewServer(runtime.NewRuntime(llm.Config{}, c), map[string]string{})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func chat(t *testing.T, s *Server, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestListModels(t *testing.T) {
+	s := newTestServer(t)
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	var models ModelList
+	if err := json.Unmarshal(rw.Body.Bytes(), &models); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, model := range models.Data {
+		ids = append(ids, model.ID)
+	}
+	if strings.Join(ids, ",") != "assistant,echo" {
+		t.Errorf("expected only the published agents and flows, got %v", ids)
+	}
+}
+
+func TestChatCompletions(t *testing.T) {
+	s := newTestServer(t)
+
+	rw := chat(t, s, `{"model":"echo","messages":[{"role":"user","content":"hi"}]}`)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rw.Code, rw.Body)
+	}
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Object != "chat.completion" || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "echo: hi" {
+		t.Errorf("unexpected response %s", rw.Body)
+	}
+
+	rw = chat(t, s, `{"model":"echo","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rw.Header().Get("Content-Type") != "text/event-stream" || !strings.Contains(rw.Body.String(), `"content":"echo: hi"`) ||
+		!strings.HasSuffix(rw.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("unexpected stream %s", rw.Body)
+	}
+
+	for body, code := range map[string]int{
+		`{"model":"hidden","messages":[{"role":"user","content":"hi"}]}`:   http.StatusNotFound,
+		`{"model":"internal","messages":[{"role":"user","content":"hi"}]}`: http.StatusNotFound,
+		`{"model":"assistant","messages":[]}`:                              http.StatusBadRequest,
+		`not json`:                                                         http.StatusBadRequest,
+	} {
+		if rw := chat(t, s, body); rw.Code != code {
+			t.Errorf("%s: expected status %d, got %d: %s", body, code, rw.Code, rw.Body)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	s.now = func() time.Time {
+		return now
+	}
+
+	for _, headers := range [][]string{
+		nil,
+		nil,
+		{"Authorization", "Bearer a"},
+		{"Authorization", "Bearer a"},
+		{"Authorization", "Bearer b"},
+	} {
+		if rw := chat(t, s, `{"model":"echo","messages":[{"role":"user","content":"hi"}]}`, headers...); rw.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rw.Code, rw.Body)
+		}
+	}
+	if rw := chat(t, s, `{"model":"echo","user":"u1","messages":[{"role":"user","content":"hi"}]}`, "Authorization", "Bearer a"); rw.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rw.Code, rw.Body)
+	}
+	// Anonymous callers do not keep a session, each token and user has one
+	if len(s.sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(s.sessions))
+	}
+
+	var sessions []*callerSession
+	for _, caller := range s.sessions {
+		sessions = append(sessions, caller)
+	}
+
+	now = now.Add(sessionIdleTimeout + time.Minute)
+	if rw := chat(t, s, `{"model":"echo","messages":[{"role":"user","content":"hi"}]}`, "Authorization", "Bearer c"); rw.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rw.Code, rw.Body)
+	}
+	if len(s.sessions) != 1 {
+		t.Errorf("expected idle sessions to be evicted, got %d sessions", len(s.sessions))
+	}
+	for _, caller := range sessions {
+		select {
+		case <-caller.session.Done():
+		default:
+			t.Errorf("expected evicted session %s to be closed", caller.session.ID())
+		}
+	}
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	s.now = func() time.Time {
+		return now
+	}
+
+	for i := range maxSessions {
+		s.sessions[fmt.Sprint(i)] = &callerSession{
+			session:  mcp.NewEmptySession(context.Background(), fmt.Sprint(i)),
+			lastUsed: now.Add(time.Duration(i) * time.Second),
+			// The oldest session is in use
+			active: max(1-i, 0),
+		}
+	}
+	oldestIdle := s.sessions["1"].session
+
+	s.evict()
+	if _, ok := s.sessions["1"]; ok || len(s.sessions) != maxSessions-1 {
+		t.Errorf("expected the least recently used idle session to be evicted")
+	}
+	if _, ok := s.sessions["0"]; !ok {
+		t.Errorf("expected the session in use to be kept")
+	}
+	select {
+	case <-oldestIdle.Done():
+	default:
+		t.Error("expected the evicted session to be closed")
+	}
+}
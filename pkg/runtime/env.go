@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func getEnvVal(envMap map[string]string, envKey string, envDef types.EnvDef) string {
+	val, ok := expr.Lookup(envMap, envKey)
+	if ok {
+		return val
+	}
+
+	if envDef.UseBearerToken {
+		bearer, ok := envMap["http:bearer-token"]
+		if ok && bearer != "" {
+			return bearer
+		}
+	}
+
+	if !envDef.Optional {
+		return ""
+	}
+
+	return envDef.Default
+}
+
+// ReconcileEnv fills in the env of the session from the env definitions of the config and returns
+// an error listing the required variables that are missing and the variables with invalid values.
+// Secret references are not checked, they are only resolved when they are used.
+func ReconcileEnv(session *mcp.Session, c types.Config) error {
+	envMap := session.EnvMap()
+	var (
+		missing, invalid []string
+		data             = map[string]any{}
+		sensitive        []string
+	)
+
+	var missingEnv, invalidEnv []types.EnvProblem
+	for _, envKey := range slices.Sorted(maps.Keys(c.Env)) {
+		envDef := c.Env[envKey]
+		envVal := getEnvVal(envMap, envKey, envDef)
+		if envVal == "" && !envDef.Optional {
+			missing = append(missing, envKey)
+			missingEnv = append(missingEnv, envDef.Problem(envKey, nil))
+			continue
+		}
+		if envVal != "" && !secrets.IsReference(envVal) {
+			if err := envDef.Check(envVal); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s %v", envKey, err))
+				invalidEnv = append(invalidEnv, envDef.Problem(envKey, err))
+				continue
+			}
+		}
+		envMap[envKey] = envVal
+	}
+
+	for envKey, envDef := range c.Env {
+		if envDef.Sensitive != nil && !*envDef.Sensitive {
+			continue
+		}
+		// Defaults and options are in the config, so they are not redacted
+		if val := envMap[envKey]; val != "" && (val == envDef.Default || slices.Contains(envDef.Options, val)) {
+			continue
+		}
+		sensitive = append(sensitive, envKey)
+	}
+	session.Set(mcp.SessionSensitiveEnvKey, sensitive)
+
+	var messages []string
+	if len(missing) > 0 {
+		messages = append(messages, fmt.Sprintf("missing required environment variables: %v", missing))
+		data["missingEnv"] = missingEnv
+	}
+	if len(invalid) > 0 {
+		messages = append(messages, "invalid environment variables: "+strings.Join(invalid, ", "))
+		data["invalidEnv"] = invalidEnv
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return &mcp.RPCError{
+		Code:       401,
+		Message:    strings.Join(messages, "; "),
+		DataObject: data,
+	}
+}
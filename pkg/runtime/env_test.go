@@ -0,0 +1,44 @@
+package runtime
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestReconcileEnvSensitive(t *testing.T) {
+	notSensitive := false
+	session := mcp.NewEmptySession(context.Background(), "test")
+	session.EnvMap()["TOKEN"] = "token-value"
+	session.EnvMap()["PUBLIC"] = "public-value"
+	session.EnvMap()["REGION"] = "us-east-1"
+
+	err := ReconcileEnv(session, types.Config{
+		Env: map[string]types.EnvDef{
+			"TOKEN":  {},
+			"PUBLIC": {Sensitive: &notSensitive},
+			"MODEL":  {Default: "default-model", Optional: true},
+			"REGION": {Options: types.StringList{"us-east-1", "eu-west-1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := session.SensitiveValues()
+	if !slices.Contains(values, "token-value") {
+		t.Errorf("expected the token to be sensitive, got %v", values)
+	}
+	for _, value := range []string{"public-value", "default-model", "us-east-1"} {
+		if slices.Contains(values, value) {
+			t.Errorf("expected %s to not be sensitive, got %v", value, values)
+		}
+	}
+	if slices.Contains(log.SensitiveValues(), "token-value") {
+		t.Error("expected the sensitive values of the session to not be added to the process")
+	}
+}
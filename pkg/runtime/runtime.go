@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/nanobot-ai/nanobot/pkg/agents"
+	"github.com/nanobot-ai/nanobot/pkg/checkpoint"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/confirm"
 	"github.com/nanobot-ai/nanobot/pkg/llm"
@@ -18,10 +21,13 @@ import (
 )
 
 type Runtime struct {
-	*tools.Service
-	config    types.Config
 	llmConfig llm.Config
 	opt       Options
+
+	// lock guards config and service, which Reload replaces while requests are served
+	lock    sync.RWMutex
+	config  types.Config
+	service *tools.Service
 }
 
 type Options struct {
@@ -29,11 +35,13 @@ type Options struct {
 	Roots          []mcp.Root
 	Profiles       []string
 	MaxConcurrency int
+	Checkpoints    *checkpoint.Store
 }
 
 func (o Options) Merge(other Options) (result Options) {
 	result.Confirmations = complete.Last(o.Confirmations, other.Confirmations)
 	result.MaxConcurrency = complete.Last(o.MaxConcurrency, other.MaxConcurrency)
+	result.Checkpoints = complete.Last(o.Checkpoints, other.Checkpoints)
 	result.Profiles = append(o.Profiles, other.Profiles...)
 	result.Roots = append(o.Roots, other.Roots...)
 	return
@@ -43,8 +51,10 @@ func NewRuntime(cfg llm.Config, config types.Config, opts ...Options) *Runtime {
 	opt := complete.Complete(opts...)
 	completer := llm.NewClient(cfg, config)
 	registry := tools.NewToolsService(config, tools.RegistryOptions{
-		Roots:       opt.Roots,
-		Concurrency: opt.MaxConcurrency,
+		Roots:         opt.Roots,
+		Concurrency:   opt.MaxConcurrency,
+		Checkpoints:   opt.Checkpoints,
+		Confirmations: opt.Confirmations,
 	})
 	agents := agents.New(completer, registry, opt.Confirmations, config)
 	sampler := sampling.NewSampler(config, agents)
@@ -54,22 +64,39 @@ func NewRuntime(cfg llm.Config, config types.Config, opts ...Options) *Runtime {
 
 	return &Runtime{
 		config:    config,
-		Service:   registry,
+		service:   registry,
 		llmConfig: cfg,
 		opt:       opt,
 	}
 }
 
-func (r *Runtime) Reload(cfg types.Config) {
+// Reload swaps in the config, keeping the running MCP servers whose definition did not change.
+// It returns the names of the MCP servers that were stopped, to be restarted when used next.
+func (r *Runtime) Reload(cfg types.Config) []string {
 	newRuntime := NewRuntime(r.llmConfig, cfg, r.opt)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	closed := newRuntime.service.TakeServers(r.service)
 	r.config = cfg
-	r.Service = newRuntime.Service
+	r.service = newRuntime.service
+	return closed
 }
 
 func (r *Runtime) GetConfig() types.Config {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 	return r.config
 }
 
+// Service returns the tools service of the current config. Calls that are running when the config
+// is reloaded finish with the service they started with.
+func (r *Runtime) Service() *tools.Service {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.service
+}
+
 func (r *Runtime) WithTempSession(ctx context.Context) context.Context {
 	return mcp.WithSession(ctx, mcp.NewEmptySession(ctx, "temp"))
 }
@@ -81,7 +108,7 @@ func (r *Runtime) getToolFromRef(ctx context.Context, serverRef string) (*tools.
 
 	toolRef := strings.Split(serverRef, "/")
 	if len(toolRef) == 1 {
-		_, ok := r.config.Agents[toolRef[0]]
+		_, ok := r.GetConfig().Agents[toolRef[0]]
 		if ok {
 			server, tool = toolRef[0], toolRef[0]
 		} else {
@@ -93,7 +120,7 @@ func (r *Runtime) getToolFromRef(ctx context.Context, serverRef string) (*tools.
 		return nil, fmt.Errorf("invalid tool reference: %s", serverRef)
 	}
 
-	toolList, err := r.ListTools(ctx, tools.ListToolsOptions{
+	toolList, err := r.Service().ListTools(ctx, tools.ListToolsOptions{
 		Servers: []string{server},
 		Tools:   []string{tool},
 	})
@@ -150,12 +177,47 @@ func (r *Runtime) CallFromCLI(ctx context.Context, serverRef string, args ...str
 			i++
 		}
 		argMap[strings.TrimPrefix(k, "--")] = v
-		argValue = argMap
+		argValue = coerceArgs(tools.Tools[0].InputSchema, argMap)
 	}
 
 	if argValue == nil {
 		argValue = map[string]any{}
 	}
 
-	return r.Call(ctx, tools.Server, tools.Tools[0].Name, argValue)
+	return r.Service().Call(ctx, tools.Server, tools.Tools[0].Name, argValue)
+}
+
+// coerceArgs converts the string values of --key=value arguments to the types of the input schema.
+func coerceArgs(schema json.RawMessage, args map[string]string) map[string]any {
+	var parsed struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+	_ = json.Unmarshal(schema, &parsed)
+
+	result := make(map[string]any, len(args))
+	for k, v := range args {
+		result[k] = v
+		switch parsed.Properties[k].Type {
+		case "integer":
+			if i, err := strconv.Atoi(v); err == nil {
+				result[k] = i
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				result[k] = f
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(v); err == nil {
+				result[k] = b
+			}
+		case "array", "object":
+			var obj any
+			if err := json.Unmarshal([]byte(v), &obj); err == nil {
+				result[k] = obj
+			}
+		}
+	}
+	return result
 }
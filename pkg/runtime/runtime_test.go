@@ -0,0 +1,230 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/nanobot-ai/nanobot/pkg/llm"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestCoerceArgs(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"count": {"type": "integer"},
+			"ratio": {"type": "number"},
+			"force": {"type": "boolean"},
+			"tags": {"type": "array"},
+			"meta": {"type": "object"},
+			"name": {"type": "string"}
+		}
+	}`)
+
+	tests := []struct {
+		name     string
+		schema   json.RawMessage
+		args     map[string]string
+		expected map[string]any
+	}{
+		{
+			name:   "types of the schema",
+			schema: schema,
+			args: map[string]string{
+				"count": "3", "ratio": "0.5", "force": "true", "tags": `["a","b"]`, "meta": `{"k":"v"}`, "name": "42",
+			},
+			expected: map[string]any{
+				"count": 3, "ratio": 0.5, "force": true, "tags": []any{"a", "b"}, "meta": map[string]any{"k": "v"}, "name": "42",
+			},
+		},
+		{
+			// Invalid values are passed as strings so that validation reports them
+			name:     "invalid values",
+			schema:   schema,
+			args:     map[string]string{"count": "three", "force": "maybe", "tags": "a,b"},
+			expected: map[string]any{"count": "three", "force": "maybe", "tags": "a,b"},
+		},
+		{
+			name:     "unknown args",
+			schema:   schema,
+			args:     map[string]string{"other": "1"},
+			expected: map[string]any{"other": "1"},
+		},
+		{
+			name:     "no schema",
+			args:     map[string]string{"count": "1"},
+			expected: map[string]any{"count": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coerceArgs(tt.schema, tt.args); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// newMCPServer starts an MCP server that keeps asking the client for sampling once it is
+// initialized, until the session or the test is done.
+func newMCPServer(t *testing.T) string {
+	t.Helper()
+	sampleCtx, stopSampling := context.WithCancel(context.Background())
+	srv := httptest.NewServer(mcp.NewHTTPServer(nil, mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		switch msg.Method {
+		case "initialize":
+			var init mcp.InitializeRequest
+			if err := json.Unmarshal(msg.Params, &init); err != nil {
+				msg.SendError(ctx, err)
+				return
+			}
+			_ = msg.Reply(ctx, mcp.InitializeResult{ProtocolVersion: init.ProtocolVersion})
+		case "notifications/initialized":
+			go func() {
+				for {
+					select {
+					case <-sampleCtx.Done():
+						return
+					case <-msg.Session.Done():
+						return
+					case <-time.After(5 * time.Millisecond):
+					}
+					// fails as there is no model, but the client reads the sampler of its service
+					var result mcp.CreateMessageResult
+					_ = msg.Session.Exchange(sampleCtx, "sampling/createMessage", mcp.CreateMessageRequest{}, &result)
+				}
+			}()
+		}
+	})))
+	t.Cleanup(func() {
+		// a reader may have started a client on the old service while it was replaced
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	t.Cleanup(stopSampling)
+	return srv.URL
+}
+
+func TestReloadOnChange(t *testing.T) {
+	interval := config.WatchInterval
+	config.WatchInterval = 20 * time.Millisecond
+	t.Cleanup(func() {
+		config.WatchInterval = interval
+	})
+
+	urlA, urlB := newMCPServer(t), newMCPServer(t)
+	file := filepath.Join(t.TempDir(), "nanobot.yaml")
+	write := func(headerB string) {
+		t.Helper()
+		data := fmt.Sprintf(`publish:
+  mcpServers: [a, b]
+mcpServers:
+  a:
+    url: %s
+  b:
+    url: %s
+    headers:
+      X-Version: %q
+`, urlA, urlB, headerB)
+		if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1")
+
+	cfg, _, err := config.Load(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := NewRuntime(llm.Config{}, *cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	session := mcp.NewEmptySession(ctx, "test")
+	ctx = mcp.WithSession(ctx, session)
+	defer func() {
+		rt.Service().CloseSession(session.ID())
+	}()
+
+	a, err := rt.Service().GetClient(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := rt.Service().GetClient(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan []string, 1)
+	go func() {
+		_ = config.Watch(ctx, file, config.Options{}, func(cfg *types.Config, err error) {
+			if err != nil {
+				t.Errorf("failed to load the changed config: %v", err)
+				return
+			}
+			closed <- rt.Reload(*cfg)
+		})
+	}()
+
+	// requests keep reading the config and service while it is reloaded
+	var wg sync.WaitGroup
+	readCtx, stopReading := context.WithCancel(ctx)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for readCtx.Err() == nil {
+				_ = rt.GetConfig().MCPServers["b"].Headers
+				_, _ = rt.Service().GetClient(readCtx, "a")
+			}
+		}()
+	}
+
+	// Watch only notices changes after it read the config, the MCP servers are sampling by then
+	time.Sleep(200 * time.Millisecond)
+	write("2")
+
+	select {
+	case names := <-closed:
+		if !reflect.DeepEqual(names, []string{"b"}) {
+			t.Errorf("expected only b to be closed, got %v", names)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the config to be reloaded")
+	}
+	// a keeps sampling through the service that started it
+	time.Sleep(50 * time.Millisecond)
+	stopReading()
+	wg.Wait()
+
+	if rt.GetConfig().MCPServers["b"].Headers["X-Version"] != "2" {
+		t.Errorf("expected the changed config, got %v", rt.GetConfig().MCPServers["b"])
+	}
+	select {
+	case <-b.Session.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("expected the changed MCP server to be closed")
+	}
+	select {
+	case <-a.Session.Done():
+		t.Error("expected the unchanged MCP server to keep running")
+	default:
+	}
+
+	if kept, err := rt.Service().GetClient(ctx, "a"); err != nil || kept != a {
+		t.Errorf("expected the unchanged MCP server to be kept, got %v", err)
+	}
+	if restarted, err := rt.Service().GetClient(ctx, "b"); err != nil || restarted == b {
+		t.Errorf("expected the changed MCP server to be restarted, got %v", err)
+	}
+}
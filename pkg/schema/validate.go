@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+var compiled sync.Map
+
+func compile(schema json.RawMessage) (*jsonschema.Schema, error) {
+	if s, ok := compiled.Load(string(schema)); ok {
+		return s.(*jsonschema.Schema), nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("error adding schema resource: %w", err)
+	}
+
+	s, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema: %w", err)
+	}
+
+	compiled.Store(string(schema), s)
+	return s, nil
+}
+
+// Validate checks that value matches the JSON schema. An empty schema accepts any value.
+func Validate(schema json.RawMessage, value any) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	s, err := compile(schema)
+	if err != nil {
+		return err
+	}
+
+	// Round trip the value so it has the same types as parsed JSON
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	if err := s.Validate(doc); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return errors.New(formatValidationError(validationErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// formatValidationError flattens the leaf causes of the error to one line per problem.
+func formatValidationError(err *jsonschema.ValidationError) string {
+	var lines []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			lines = append(lines, e.Error())
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(err)
+	return strings.Join(lines, "; ")
+}
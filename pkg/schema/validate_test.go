@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"count": {"type": "integer", "minimum": 1}
+		},
+		"required": ["name"]
+	}`)
+
+	tests := []struct {
+		name   string
+		schema json.RawMessage
+		value  any
+		errs   []string
+	}{
+		{name: "empty schema", value: map[string]any{"anything": true}},
+		{name: "valid", schema: schema, value: map[string]any{"name": "a", "count": 2}},
+		// Go ints are checked as JSON numbers
+		{name: "go types", schema: schema, value: struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		}{"a", 3}},
+		{name: "missing required", schema: schema, value: map[string]any{"count": 2}, errs: []string{"missing property 'name'"}},
+		{name: "every problem", schema: schema, value: map[string]any{"name": 1, "count": 0}, errs: []string{"/name", "/count"}},
+		{name: "wrong type", schema: schema, value: "a", errs: []string{"want object"}},
+		{name: "invalid schema", schema: json.RawMessage(`{"type": `), value: "a", errs: []string{"failed to parse schema"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.schema, tt.value)
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error containing %v", tt.errs)
+			}
+			for _, e := range tt.errs {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("expected %q in %q", e, err.Error())
+				}
+			}
+			if strings.Contains(err.Error(), "\n") {
+				t.Errorf("expected the problems on one line, got %q", err.Error())
+			}
+		})
+	}
+}
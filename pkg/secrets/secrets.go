@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+// Prefix starts the name of a secret reference, ${secret:file:/run/secrets/token} reads the
+// secret from the file provider.
+const Prefix = "secret:"
+
+// Provider looks up secrets by the key that follows the name of the provider in a reference.
+type Provider interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+type ProviderFunc func(ctx context.Context, key string) (string, error)
+
+func (f ProviderFunc) Get(ctx context.Context, key string) (string, error) {
+	return f(ctx, key)
+}
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]Provider{
+		"file":  ProviderFunc(readFile),
+		"cmd":   ProviderFunc(runCommand),
+		"vault": ProviderFunc(readVault),
+	}
+
+	cache sync.Map
+)
+
+// Register adds a provider, or replaces the provider with the same name.
+func Register(name string, provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = provider
+}
+
+// IsName returns whether the name of a ${...} placeholder is a secret reference.
+func IsName(name string) bool {
+	return strings.HasPrefix(name, Prefix)
+}
+
+// IsReference returns whether the value is a single ${secret:...} reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, "${"+Prefix) && strings.HasSuffix(value, "}")
+}
+
+// Expand resolves the value if it is a secret reference, other values are returned as is.
+func Expand(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	return Resolve(ctx, value[2:len(value)-1])
+}
+
+// Resolve looks up the secret of a reference such as secret:cmd:pass show foo. Secrets are only
+// looked up once per process and are redacted from the logs from then on.
+func Resolve(ctx context.Context, name string) (string, error) {
+	ref, ok := strings.CutPrefix(name, Prefix)
+	if !ok {
+		return "", fmt.Errorf("invalid secret reference %q, must start with %s", name, Prefix)
+	}
+	if value, ok := cache.Load(ref); ok {
+		return value.(string), nil
+	}
+
+	providerName, key, ok := strings.Cut(ref, ":")
+	if !ok || key == "" {
+		return "", fmt.Errorf("invalid secret reference %q, must be in the form secret:{provider}:{key}", name)
+	}
+
+	providersLock.RLock()
+	provider, ok := providers[providerName]
+	providersLock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q in %s", providerName, name)
+	}
+
+	value, err := provider.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	log.AddSensitive(value)
+	cache.Store(ref, value)
+	return value, nil
+}
+
+func readFile(_ context.Context, file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// runCommand runs the command with the shell and returns its output, so that password managers
+// such as pass or op can provide secrets. Stdin is not passed on, it is the transport of the MCP
+// server when serving over stdio.
+func runCommand(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vaultFile := filepath.Join(dir, "vault")
+	t.Setenv(VaultFileEnv, vaultFile)
+	t.Setenv(VaultKeyEnv, "test-key")
+	v, err := OpenVault(vaultFile, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Set("token", "vault-secret")
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "${secret:file:" + file + "}", want: "file-secret"},
+		{value: "${secret:cmd:echo cmd-secret}", want: "cmd-secret"},
+		{value: "${secret:vault:token}", want: "vault-secret"},
+		{value: "plain", want: "plain"},
+		{value: "prefix ${secret:cmd:echo x}", want: "prefix ${secret:cmd:echo x}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Expand(context.Background(), tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := log.Redact("token is vault-secret"); got != "token is [REDACTED]" {
+		t.Errorf("resolved secret was not redacted from logs: %q", got)
+	}
+
+	for _, value := range []string{"${secret:vault:missing}", "${secret:nope:x}", "${secret:file}", "${secret:cmd:exit 1}"} {
+		if _, err := Expand(context.Background(), value); err == nil {
+			t.Errorf("expected an error resolving %s", value)
+		}
+	}
+}
+
+func TestVault(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "nanobot", "vault")
+	v, err := OpenVault(file, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Set("a", "1")
+	v.Set("b", "2")
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"a"`) {
+		t.Errorf("vault is not encrypted: %s", data)
+	}
+
+	v, err = OpenVault(file, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := v.Get("b"); !ok || got != "2" {
+		t.Errorf("got %q, %v", got, ok)
+	}
+	if !v.Delete("a") || v.Delete("a") {
+		t.Error("expected a to be deleted once")
+	}
+	if names := v.Names(); len(names) != 1 || names[0] != "b" {
+		t.Errorf("got %v", names)
+	}
+
+	if _, err := OpenVault(file, "wrong"); err == nil {
+		t.Error("expected an error opening the vault with the wrong key")
+	}
+	if _, err := OpenVault(file, ""); err == nil {
+		t.Error("expected an error opening the vault without a key")
+	}
+}
@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// VaultKeyEnv is the environment variable with the key that unlocks the vault.
+	VaultKeyEnv = "NANOBOT_VAULT_KEY"
+	// VaultFileEnv is the environment variable that overrides the location of the vault.
+	VaultFileEnv = "NANOBOT_VAULT_FILE"
+
+	vaultVersion    = 1
+	vaultIterations = 600_000
+)
+
+// Vault is a local file of secrets encrypted with AES-GCM, using a key derived from the vault key
+// with PBKDF2.
+type Vault struct {
+	file    string
+	key     string
+	salt    []byte
+	secrets map[string]string
+}
+
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// DefaultVaultFile returns $NANOBOT_VAULT_FILE, or the vault in the user config directory.
+func DefaultVaultFile() (string, error) {
+	if file := os.Getenv(VaultFileEnv); file != "" {
+		return file, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "nanobot", "vault"), nil
+}
+
+// OpenVault decrypts the vault with the key. A vault that does not exist yet is empty and is
+// created on Save.
+func OpenVault(file, key string) (*Vault, error) {
+	if key == "" {
+		return nil, fmt.Errorf("no key to unlock the vault %s, set %s", file, VaultKeyEnv)
+	}
+
+	v := &Vault{
+		file:    file,
+		key:     key,
+		secrets: map[string]string{},
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read vault %s: %w", file, err)
+	}
+
+	var encrypted vaultFile
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, fmt.Errorf("failed to parse vault %s: %w", file, err)
+	}
+	if encrypted.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported version %d of vault %s", encrypted.Version, file)
+	}
+
+	v.salt = encrypted.Salt
+	gcm, err := v.cipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, encrypted.Nonce, encrypted.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock vault %s, the key is wrong or the file is corrupt", file)
+	}
+	if err := json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets of vault %s: %w", file, err)
+	}
+	return v, nil
+}
+
+func (v *Vault) cipher() (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, v.key, v.salt, vaultIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+	return value, ok
+}
+
+func (v *Vault) Set(name, value string) {
+	v.secrets[name] = value
+}
+
+// Delete removes the secret and returns whether it was in the vault.
+func (v *Vault) Delete(name string) bool {
+	_, ok := v.secrets[name]
+	delete(v.secrets, name)
+	return ok
+}
+
+func (v *Vault) Names() []string {
+	return slices.Sorted(maps.Keys(v.secrets))
+}
+
+// Save encrypts the secrets with a new salt and nonce and replaces the vault file.
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+
+	v.salt = make([]byte, 16)
+	if _, err := rand.Read(v.salt); err != nil {
+		return err
+	}
+	gcm, err := v.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(vaultFile{
+		Version: vaultVersion,
+		Salt:    v.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.file), 0700); err != nil {
+		return fmt.Errorf("failed to create directory of vault %s: %w", v.file, err)
+	}
+	tmp := v.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault %s: %w", v.file, err)
+	}
+	if err := os.Rename(tmp, v.file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write vault %s: %w", v.file, err)
+	}
+	return nil
+}
+
+// readVault is the vault provider, which reads secrets from the default vault.
+func readVault(_ context.Context, name string) (string, error) {
+	file, err := DefaultVaultFile()
+	if err != nil {
+		return "", err
+	}
+	v, err := OpenVault(file, os.Getenv(VaultKeyEnv))
+	if err != nil {
+		return "", err
+	}
+	value, ok := v.Get(name)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found in vault %s", name, file)
+	}
+	return value, nil
+}
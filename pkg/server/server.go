@@ -8,20 +8,29 @@ import (
 	"maps"
 	"regexp"
 	"slices"
+	"sync"
+	"time"
 
-	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
 	"github.com/nanobot-ai/nanobot/pkg/schema"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
 type Server struct {
-	handlers []handler
-	runtime  *runtime.Runtime
+	handlers     []handler
+	runtime      *runtime.Runtime
+	sessions     map[*mcp.Session]struct{}
+	sessionsLock sync.Mutex
 }
 
+// reloadTimeout bounds updating a session on reload, clients that are not listening for
+// notifications would otherwise block it.
+const reloadTimeout = 30 * time.Second
+
 const (
 	toolMappingKey             = "toolMapping"
 	promptMappingKey           = "promptMapping"
@@ -31,7 +40,8 @@ const (
 
 func NewServer(r *runtime.Runtime) *Server {
 	s := &Server{
-		runtime: r,
+		runtime:  r,
+		sessions: map[*mcp.Session]struct{}{},
 	}
 	s.init()
 	return s
@@ -106,7 +116,7 @@ func (s *Server) handleReadResource(ctx context.Context, msg mcp.Message, payloa
 		}
 	}
 
-	c, err := s.runtime.GetClient(ctx, resourceMapping.MCPServer)
+	c, err := s.runtime.Service().GetClient(ctx, resourceMapping.MCPServer)
 	if err != nil {
 		return fmt.Errorf("failed to get client for server %s: %w", resourceMapping.MCPServer, err)
 	}
@@ -126,7 +136,7 @@ func (s *Server) handleGetPrompt(ctx context.Context, msg mcp.Message, payload m
 		return fmt.Errorf("prompt %s not found", payload.Name)
 	}
 
-	c, err := s.runtime.GetClient(ctx, promptMapping.MCPServer)
+	c, err := s.runtime.Service().GetClient(ctx, promptMapping.MCPServer)
 	if err != nil {
 		return fmt.Errorf("failed to get client for server %s: %w", promptMapping.MCPServer, err)
 	}
@@ -172,7 +182,7 @@ func (s *Server) handleCallTool(ctx context.Context, msg mcp.Message, payload mc
 		return fmt.Errorf("tool %s not found", payload.Name)
 	}
 
-	result, err := s.runtime.Call(ctx, toolMapping.MCPServer, toolMapping.TargetName, payload.Arguments, tools.CallOptions{
+	result, err := s.runtime.Service().Call(ctx, toolMapping.MCPServer, toolMapping.TargetName, payload.Arguments, tools.CallOptions{
 		ProgressToken: msg.ProgressToken(),
 		LogData: map[string]any{
 			"mcpToolName": payload.Name,
@@ -214,7 +224,7 @@ func (s *Server) buildResourceMappings(ctx context.Context) (types.ResourceMappi
 			continue
 		}
 
-		c, err := s.runtime.GetClient(ctx, toolRef.Server)
+		c, err := s.runtime.Service().GetClient(ctx, toolRef.Server)
 		if err != nil {
 			return nil, err
 		}
@@ -243,7 +253,7 @@ func (s *Server) buildResourceTemplateMappings(ctx context.Context) (types.Resou
 			continue
 		}
 
-		c, err := s.runtime.GetClient(ctx, toolRef.Server)
+		c, err := s.runtime.Service().GetClient(ctx, toolRef.Server)
 		if err != nil {
 			return nil, err
 		}
@@ -287,7 +297,7 @@ func (s *Server) buildPromptMappings(ctx context.Context) (types.PromptMappings,
 
 		prompts, ok := serverPrompts[toolRef.Server]
 		if !ok {
-			c, err := s.runtime.GetClient(ctx, toolRef.Server)
+			c, err := s.runtime.Service().GetClient(ctx, toolRef.Server)
 			if err != nil {
 				return nil, err
 			}
@@ -312,132 +322,145 @@ func (s *Server) buildPromptMappings(ctx context.Context) (types.PromptMappings,
 	return result, nil
 }
 
-func getEnvVal(envMap map[string]string, envKey string, envDef types.EnvDef) string {
-	val, ok := expr.Lookup(envMap, envKey)
-	if ok {
-		return val
-	}
-
-	if envDef.UseBearerToken {
-		bearer, ok := envMap["http:bearer-token"]
-		if ok && bearer != "" {
-			return bearer
-		}
-	}
-
-	if !envDef.Optional {
-		return ""
-	}
-
-	return envDef.Default
-}
+func (s *Server) handleInitialize(ctx context.Context, msg mcp.Message, payload mcp.InitializeRequest) error {
+	c := s.runtime.GetConfig()
+	session := mcp.SessionFromContext(ctx)
+	msg.Session.ClientCapabilities = &payload.Capabilities
 
-func reconcileEnv(session *mcp.Session, c types.Config) error {
-	envMap := session.EnvMap()
-	var missing []string
-	for envKey, envDef := range c.Env {
-		envVal := getEnvVal(envMap, envKey, envDef)
-		if envVal == "" && !envDef.Optional {
-			missing = append(missing, envKey)
-			continue
-		}
-		envMap[envKey] = envVal
+	if err := runtime.ReconcileEnv(session, c); err != nil {
+		return err
 	}
 
-	if len(missing) == 0 {
-		return nil
+	if err := s.buildMappings(ctx, msg.Session); err != nil {
+		return err
 	}
+	s.track(msg.Session)
 
-	var missingEnv []any
-	for _, key := range missing {
-		values := map[string]any{
-			"name":        key,
-			"description": c.Env[key].Description,
-			"default":     c.Env[key].Default,
+	var experimental map[string]any
+	if c.Publish.Introduction.IsSet() {
+		intro, err := s.runtime.Service().GetDynamicInstruction(ctx, c.Publish.Introduction)
+		if err != nil {
+			return fmt.Errorf("failed to get introduction: %w", err)
 		}
-		if len(c.Env[key].Options) > 0 {
-			values["options"] = c.Env[key].Options
+		experimental = map[string]any{
+			"nanobot/intro": intro,
 		}
-		missingEnv = append(missingEnv, values)
 	}
-	return &mcp.RPCError{
-		Code:    401,
-		Message: fmt.Sprintf("missing required environment variables: %v", missing),
-		DataObject: map[string]any{
-			"missingEnv": missingEnv,
+
+	return msg.Reply(ctx, mcp.InitializeResult{
+		ProtocolVersion: payload.ProtocolVersion,
+		Capabilities: mcp.ServerCapabilities{
+			Experimental: experimental,
+			Logging:      &struct{}{},
+			Prompts:      &mcp.PromptsServerCapability{ListChanged: true},
+			Resources:    &mcp.ResourcesServerCapability{ListChanged: true},
+			Tools:        &mcp.ToolsServerCapability{ListChanged: true},
 		},
-	}
+		ServerInfo: mcp.ServerInfo{
+			Name:    c.Publish.Name,
+			Version: c.Publish.Version,
+		},
+		Instructions: s.runtime.GetConfig().Publish.Instructions,
+	})
 }
 
-func (s *Server) handleInitialize(ctx context.Context, msg mcp.Message, payload mcp.InitializeRequest) error {
+func (s *Server) buildMappings(ctx context.Context, session *mcp.Session) error {
 	c := s.runtime.GetConfig()
-	session := mcp.SessionFromContext(ctx)
 
-	if err := reconcileEnv(session, c); err != nil {
-		return err
-	}
-
-	toolMappings, err := s.runtime.BuildToolMappings(ctx, append(c.Publish.Tools, c.Publish.MCPServers...))
+	toolMappings, err := s.runtime.Service().BuildToolMappings(ctx, append(c.Publish.Tools, c.Publish.MCPServers...))
 	if err != nil {
 		return err
 	}
-	if s.runtime.GetConfig().Publish.Entrypoint != "" {
-		toolMappings[types.AgentTool], err = s.runtime.GetEntryPoint(ctx, toolMappings)
+	if c.Publish.Entrypoint != "" {
+		toolMappings[types.AgentTool], err = s.runtime.Service().GetEntryPoint(ctx, toolMappings)
 		if err != nil {
 			return err
 		}
 	}
 
-	toolMappings = schema.ValidateToolMappings(toolMappings)
-	msg.Session.Set(toolMappingKey, toolMappings)
-
 	promptMappings, err := s.buildPromptMappings(ctx)
 	if err != nil {
 		return err
 	}
-	msg.Session.Set(promptMappingKey, promptMappings)
 
 	resourceMappings, err := s.buildResourceMappings(ctx)
 	if err != nil {
 		return err
 	}
-	msg.Session.Set(resourceMappingKey, resourceMappings)
 
 	resourceTemplateMappings, err := s.buildResourceTemplateMappings(ctx)
 	if err != nil {
 		return err
 	}
-	msg.Session.Set(resourceTemplateMappingKey, resourceTemplateMappings)
 
-	var experimental map[string]any
-	if c.Publish.Introduction.IsSet() {
-		intro, err := s.runtime.GetDynamicInstruction(ctx, c.Publish.Introduction)
-		if err != nil {
-			return fmt.Errorf("failed to get introduction: %w", err)
-		}
-		experimental = map[string]any{
-			"nanobot/intro": intro,
+	session.Set(toolMappingKey, schema.ValidateToolMappings(toolMappings))
+	session.Set(promptMappingKey, promptMappings)
+	session.Set(resourceMappingKey, resourceMappings)
+	session.Set(resourceTemplateMappingKey, resourceTemplateMappings)
+	return nil
+}
+
+// track remembers the session until it is closed, so it can be updated when the config is
+// reloaded.
+func (s *Server) track(session *mcp.Session) {
+	s.sessionsLock.Lock()
+	defer s.sessionsLock.Unlock()
+	if _, ok := s.sessions[session]; ok {
+		return
+	}
+	s.sessions[session] = struct{}{}
+	go func() {
+		<-session.Done()
+		s.sessionsLock.Lock()
+		defer s.sessionsLock.Unlock()
+		delete(s.sessions, session)
+	}()
+}
+
+// Reload rebuilds the tools, prompts and resources of the connected sessions from the current
+// config of the runtime and notifies their clients that the lists changed.
+func (s *Server) Reload(ctx context.Context) {
+	s.sessionsLock.Lock()
+	sessions := slices.Collect(maps.Keys(s.sessions))
+	s.sessionsLock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.reloadSession(ctx, session)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) reloadSession(ctx context.Context, session *mcp.Session) {
+	ctx, cancel := context.WithTimeout(mcp.WithSession(ctx, session), reloadTimeout)
+	defer cancel()
+
+	if err := runtime.ReconcileEnv(session, s.runtime.GetConfig()); err != nil {
+		log.Errorf(ctx, "failed to reload session %s: %v", session.ID(), err)
+		return
+	}
+	if err := s.buildMappings(ctx, session); err != nil {
+		log.Errorf(ctx, "failed to reload session %s: %v", session.ID(), err)
+		return
+	}
+	for _, method := range []string{
+		"notifications/tools/list_changed",
+		"notifications/prompts/list_changed",
+		"notifications/resources/list_changed",
+	} {
+		if err := session.SendPayload(ctx, method, struct{}{}); err != nil {
+			log.Debugf(ctx, "failed to send %s to session %s: %v", method, session.ID(), err)
+			return
 		}
 	}
-
-	return msg.Reply(ctx, mcp.InitializeResult{
-		ProtocolVersion: payload.ProtocolVersion,
-		Capabilities: mcp.ServerCapabilities{
-			Experimental: experimental,
-			Logging:      &struct{}{},
-			Prompts:      &mcp.PromptsServerCapability{},
-			Resources:    &mcp.ResourcesServerCapability{},
-			Tools:        &mcp.ToolsServerCapability{},
-		},
-		ServerInfo: mcp.ServerInfo{
-			Name:    c.Publish.Name,
-			Version: c.Publish.Version,
-		},
-		Instructions: s.runtime.GetConfig().Publish.Instructions,
-	})
 }
 
 func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
+	ctx = tracing.WithTraceParent(ctx, msg.TraceParent())
 	for _, h := range s.handlers {
 		ok, err := h(ctx, msg)
 		if err != nil {
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/confirm"
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+var errCanNotAsk = errors.New("the client does not support elicitation or confirmations")
+
+// runStepAsk pauses the flow until the user answers the ask of the step. The answer is returned as
+// the output of the step in the form {"action": "accept", "content": {...}}.
+func (r *Service) runStepAsk(ctx flowContext, step types.Step) (*mcp.CallToolResult, error) {
+	ask := *step.Ask
+
+	message, err := expr.EvalAny(ctx.ctx, ctx.env, ctx.data, ask.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate ask message for step %s: %w", step.ID, err)
+	}
+	messageText, ok := message.(string)
+	if !ok {
+		data, _ := json.Marshal(message)
+		messageText = string(data)
+	}
+
+	timeout := confirm.Timeout
+	if ask.Timeout != "" {
+		timeout, err = time.ParseDuration(ask.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ask timeout for step %s: %w", step.ID, err)
+		}
+	}
+
+	askCtx, cancel := context.WithTimeout(ctx.ctx, timeout)
+	defer cancel()
+
+	result, err := r.ask(askCtx, ask, messageText)
+	if err != nil {
+		if ctx.ctx.Err() != nil {
+			return nil, ctx.ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("no answer after %s", timeout)
+		}
+		if ask.Default == "" {
+			return nil, fmt.Errorf("failed to ask for step %s: %w", step.ID, err)
+		}
+		log.Warnf(ctx.ctx, "failed to ask for step %s, using default answer %s: %v", step.ID, ask.Default, err)
+		result = mcp.ElicitResult{
+			Action: ask.Default,
+		}
+	}
+
+	if result.Action != mcp.ElicitActionAccept {
+		return nil, fmt.Errorf("step %s was not accepted, the answer was %s", step.ID, result.Action)
+	}
+
+	output := map[string]any{
+		"action":  result.Action,
+		"content": result.Content,
+	}
+	if result.Content == nil {
+		output["content"] = map[string]any{}
+	}
+	text, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				Type:              "text",
+				Text:              string(text),
+				StructuredContent: output,
+			},
+		},
+	}, nil
+}
+
+// ask uses elicitation if the client supports it, otherwise approvals fall back to the confirm service.
+func (r *Service) ask(ctx context.Context, ask types.Ask, message string) (result mcp.ElicitResult, _ error) {
+	session := mcp.SessionFromContext(ctx)
+	if session == nil {
+		return result, errCanNotAsk
+	}
+	for session.Parent != nil {
+		session = session.Parent
+	}
+
+	if session.ClientCapabilities != nil && session.ClientCapabilities.Elicitation != nil {
+		err := session.Exchange(ctx, "elicitation/create", mcp.ElicitRequest{
+			Message:         message,
+			RequestedSchema: ask.ToSchema(),
+		}, &result)
+		return result, err
+	}
+
+	if ask.IsApproval() && r.confirm != nil {
+		approved, err := r.confirm.Approve(ctx, session, message)
+		if err != nil {
+			return result, err
+		}
+		result.Action = mcp.ElicitActionAccept
+		if !approved {
+			result.Action = mcp.ElicitActionDecline
+		}
+		return result, nil
+	}
+
+	return result, errCanNotAsk
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/confirm"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// callAsk runs a flow with a single ask step for a client connected over MCP, so the ask goes
+// through the elicitation of a real client if the client handles it.
+func callAsk(t *testing.T, ask types.Ask, confirmations *confirm.Service, opt mcp.ClientOption) (map[string]any, error) {
+	t.Helper()
+	s := NewToolsService(types.Config{
+		Flows: map[string]types.Flow{
+			"test": {Steps: []types.Step{{ID: "ask", Ask: &ask}}},
+		},
+	}, RegistryOptions{Confirmations: confirmations})
+
+	srv := httptest.NewServer(mcp.NewHTTPServer(nil, mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		switch msg.Method {
+		case "initialize":
+			var init mcp.InitializeRequest
+			if err := json.Unmarshal(msg.Params, &init); err != nil {
+				msg.SendError(ctx, err)
+				return
+			}
+			msg.Session.ClientCapabilities = &init.Capabilities
+			_ = msg.Reply(ctx, mcp.InitializeResult{ProtocolVersion: init.ProtocolVersion})
+		case "tools/call":
+			ret, err := s.Call(ctx, "test", "", map[string]any{})
+			if err != nil {
+				msg.SendError(ctx, err)
+				return
+			}
+			_ = msg.Reply(ctx, ret)
+		}
+	})))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mcp.NewClient(ctx, "test", mcp.Server{BaseURL: srv.URL}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Session.Close()
+
+	ret, err := client.Call(ctx, "test", map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	var output map[string]any
+	if err := json.Unmarshal([]byte(ret.Content[0].Text), &output); err != nil {
+		t.Fatalf("failed to parse output %q: %v", ret.Content[0].Text, err)
+	}
+	return output, nil
+}
+
+func TestAskApproval(t *testing.T) {
+	var message string
+	output, err := callAsk(t, types.Ask{Message: "Deploy?"}, nil, mcp.ClientOption{
+		OnElicit: func(_ context.Context, req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			message = req.Message
+			return mcp.ElicitResult{Action: mcp.ElicitActionAccept}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != "Deploy?" {
+		t.Errorf("expected the message of the ask, got %q", message)
+	}
+	if output["action"] != mcp.ElicitActionAccept {
+		t.Errorf("expected the ask to be accepted, got %v", output)
+	}
+
+	_, err = callAsk(t, types.Ask{Message: "Deploy?"}, nil, mcp.ClientOption{
+		OnElicit: func(context.Context, mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			return mcp.ElicitResult{Action: mcp.ElicitActionDecline}, nil
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "the answer was decline") {
+		t.Errorf("expected the declined ask to fail the step, got %v", err)
+	}
+}
+
+func TestAskInput(t *testing.T) {
+	var schema map[string]any
+	output, err := callAsk(t, types.Ask{
+		Message: "Which environment?",
+		Fields: map[string]types.Field{
+			"env": {Description: "The environment to deploy to"},
+		},
+	}, nil, mcp.ClientOption{
+		OnElicit: func(_ context.Context, req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			if err := json.Unmarshal(req.RequestedSchema, &schema); err != nil {
+				return mcp.ElicitResult{}, err
+			}
+			return mcp.ElicitResult{
+				Action:  mcp.ElicitActionAccept,
+				Content: map[string]any{"env": "staging"},
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema["properties"].(map[string]any)["env"]; !ok {
+		t.Errorf("expected the fields in the requested schema, got %v", schema)
+	}
+	if env := output["content"].(map[string]any)["env"]; env != "staging" {
+		t.Errorf("expected the answer in the output, got %v", output)
+	}
+}
+
+func TestAskWithoutElicitation(t *testing.T) {
+	_, err := callAsk(t, types.Ask{Message: "Deploy?"}, nil, mcp.ClientOption{})
+	if err == nil || !strings.Contains(err.Error(), errCanNotAsk.Error()) {
+		t.Errorf("expected the ask to fail without elicitation, got %v", err)
+	}
+
+	output, err := callAsk(t, types.Ask{Message: "Deploy?", Default: mcp.ElicitActionAccept}, nil, mcp.ClientOption{})
+	if err != nil || output["action"] != mcp.ElicitActionAccept {
+		t.Errorf("expected the default answer, got %v, %v", output, err)
+	}
+
+	// Approvals fall back to the confirmations of the nanobot UI
+	confirmations := confirm.NewService()
+	reject := mcp.ClientOption{
+		OnLogging: func(_ context.Context, logMsg mcp.LoggingMessage) error {
+			var data struct {
+				Type    string `json:"type"`
+				Request struct {
+					ID string `json:"id"`
+				} `json:"request"`
+			}
+			raw, _ := json.Marshal(logMsg.Data)
+			if err := json.Unmarshal(raw, &data); err == nil && data.Type == "nanobot/confirm" {
+				confirmations.Reply(data.Request.ID, false)
+			}
+			return nil
+		},
+	}
+	_, err = callAsk(t, types.Ask{Message: "Deploy?"}, confirmations, reject)
+	if err == nil || !strings.Contains(err.Error(), "the answer was decline") {
+		t.Errorf("expected the rejected confirmation to decline the ask, got %v", err)
+	}
+
+	_, err = callAsk(t, types.Ask{
+		Message: "Which environment?",
+		Fields:  map[string]types.Field{"env": {}},
+	}, confirmations, reject)
+	if err == nil || !strings.Contains(err.Error(), errCanNotAsk.Error()) {
+		t.Errorf("expected input to require elicitation, got %v", err)
+	}
+}
@@ -2,58 +2,308 @@ package tools
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"iter"
 	"maps"
 	"sync"
+	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/checkpoint"
 	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/schema"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	defaultMaxIterations = 1000
+	// flowRunIDArg is the tool argument used to resume a previous run of a flow from its checkpoint
+	flowRunIDArg = "flowRunId"
+)
+
 type flowContext struct {
 	ctx  context.Context
 	opt  mcp.CallOption
 	env  map[string]string
 	data map[string]any
+	// run is only set for the top level steps of a flow, nested steps are checkpointed as part
+	// of their parent step
+	run *flowRun
+}
+
+type flowRun struct {
+	store *checkpoint.Store
+	run   *checkpoint.Run
+}
+
+func (f *flowRun) result(i int, id string) (*mcp.CallToolResult, bool, error) {
+	if f == nil {
+		return nil, false, nil
+	}
+	step, ok := f.run.Steps[i]
+	if !ok {
+		return nil, false, nil
+	}
+	if step.ID != id {
+		return nil, false, fmt.Errorf("step %d of flow run %s is step %q, not %q", i, f.run.ID, step.ID, id)
+	}
+	return step.Result, true, nil
+}
+
+func (f *flowRun) complete(ctx flowContext, i int, id string, ret *mcp.CallToolResult) {
+	if f == nil {
+		return
+	}
+	if f.run.Steps == nil {
+		f.run.Steps = map[int]checkpoint.Step{}
+	}
+	f.run.Steps[i] = checkpoint.Step{
+		ID:     id,
+		Result: ret,
+	}
+	f.run.Data = ctx.data
+	f.save(ctx.ctx)
+}
+
+func (f *flowRun) finish(ctx flowContext, err error) {
+	if f == nil {
+		return
+	}
+	if err == nil {
+		f.run.Status = checkpoint.StatusSucceeded
+		f.run.Error = ""
+	} else {
+		f.run.Status = checkpoint.StatusFailed
+		f.run.Error = err.Error()
+	}
+	f.run.Data = ctx.data
+	f.save(ctx.ctx)
+}
+
+func (f *flowRun) save(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	if err := f.store.Save(f.run, mcp.SessionFromContext(ctx).SensitiveValues()...); err != nil {
+		log.Warnf(ctx, "failed to checkpoint flow run %s: %v", f.run.ID, err)
+	}
+}
+
+// ResumeFlow runs a previous run of a flow again, skipping the steps that already completed.
+func (r *Service) ResumeFlow(ctx context.Context, runID string, opts ...CallOptions) (*mcp.CallToolResult, error) {
+	if r.checkpoints == nil {
+		return nil, fmt.Errorf("flow checkpoints are disabled, can not resume flow run %s", runID)
+	}
+	run, err := r.checkpoints.Load(runID)
+	if err != nil {
+		return nil, err
+	}
+	return r.Call(ctx, run.Flow, "", map[string]any{
+		flowRunIDArg: runID,
+	}, opts...)
+}
+
+// flowHash returns the hash of the definition of a flow, so a flow run is not resumed after the
+// steps of the flow changed.
+func flowHash(flow types.Flow) (string, error) {
+	data, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (r *Service) loadFlowRun(ctx context.Context, flowName string, flow types.Flow, args any) (*flowRun, any, error) {
+	var runID string
+	if argMap, ok := args.(map[string]any); ok {
+		if id, _ := argMap[flowRunIDArg].(string); id != "" {
+			runID = id
+			argMap = maps.Clone(argMap)
+			delete(argMap, flowRunIDArg)
+			args = argMap
+		}
+	}
+
+	if runID == "" && r.checkpoints == nil {
+		return nil, args, nil
+	}
+
+	hash, err := flowHash(flow)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash flow %s: %w", flowName, err)
+	}
+
+	if runID == "" {
+		if err := r.checkpoints.Prune(); err != nil {
+			log.Warnf(ctx, "failed to prune old flow runs: %v", err)
+		}
+		return &flowRun{
+			store: r.checkpoints,
+			run: &checkpoint.Run{
+				ID:       uuid.String(),
+				Flow:     flowName,
+				FlowHash: hash,
+			},
+		}, args, nil
+	}
+
+	if r.checkpoints == nil {
+		return nil, nil, fmt.Errorf("flow checkpoints are disabled, can not resume flow run %s", runID)
+	}
+	run, err := r.checkpoints.Load(runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if run.Flow != flowName {
+		return nil, nil, fmt.Errorf("flow run %s is a run of flow %s, not %s", runID, run.Flow, flowName)
+	}
+	if run.FlowHash != hash {
+		return nil, nil, fmt.Errorf("flow %s changed since flow run %s was started, it can not be resumed", flowName, runID)
+	}
+	if run.Redacted {
+		return nil, nil, fmt.Errorf("flow run %s can not be resumed, values of sensitive env vars were redacted from its checkpoint", runID)
+	}
+	return &flowRun{
+		store: r.checkpoints,
+		run:   run,
+	}, args, nil
 }
 
-func (r *Service) startFlow(ctx context.Context, flowName string, args any, opt CallOptions) (*mcp.CallToolResult, error) {
+func (r *Service) startFlow(ctx context.Context, flowName string, args any, opt CallOptions) (_ *mcp.CallToolResult, err error) {
 	flow, ok := r.config.Flows[flowName]
 	if !ok {
 		return nil, fmt.Errorf("failed to find flow %s in config", flowName)
 	}
 
+	defer func() {
+		metrics.FlowExecutions.Inc(flowName, metrics.Status(err))
+	}()
+
+	run, args, err := r.loadFlowRun(ctx, flowName, flow, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if run == nil || run.run.Data == nil {
+		if err := schema.Validate(flow.Input.ToSchema(), args); err != nil {
+			return nil, fmt.Errorf("invalid input for flow %s: %w", flowName, err)
+		}
+	}
+
 	data := map[string]any{
 		"id":    uuid.String(),
 		"flow":  flowName,
 		"input": args,
 	}
+	if run != nil {
+		if run.run.Data == nil {
+			data["id"] = run.run.ID
+			run.run.Data = data
+		} else {
+			data = run.run.Data
+			log.Infof(ctx, "resuming flow run %s of flow %s after %d completed steps", run.run.ID, flowName, len(run.run.Steps))
+		}
+		run.run.Status = checkpoint.StatusRunning
+		run.run.Error = ""
+	}
 
 	fCtx := flowContext{
-		ctx: ctx,
+		ctx: log.WithFields(log.WithComponent(ctx, "flow"), "flow", flowName, "flowId", data["id"]),
 		opt: mcp.CallOption{
 			ProgressToken: opt.ProgressToken,
 		},
 		env:  mcp.SessionFromContext(ctx).EnvMap(),
 		data: data,
+		run:  run,
 	}
 
-	return r.runSteps(fCtx, flow.Steps)
+	fCtx.run.save(fCtx.ctx)
+
+	defer func() {
+		fCtx.run.finish(fCtx, err)
+	}()
+
+	ret, err := r.runSteps(fCtx, flow.Steps)
+	if err != nil || flow.Output == nil {
+		return ret, err
+	}
+
+	return r.flowOutput(fCtx, flow.Output)
+}
+
+func (r *Service) flowOutput(ctx flowContext, output any) (*mcp.CallToolResult, error) {
+	val, err := expr.EvalAny(ctx.ctx, ctx.env, ctx.data, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate output of flow %s: %w", ctx.data["flow"], err)
+	}
+
+	ret, err := valueResult(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output of flow %s: %w", ctx.data["flow"], err)
+	}
+	return ret, nil
+}
+
+// valueResult returns a result with the value as text and, unless it is a string, as structured
+// content.
+func valueResult(val any) (*mcp.CallToolResult, error) {
+	if text, ok := val.(string); ok {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: text,
+				},
+			},
+		}, nil
+	}
+
+	text, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				Type:              "text",
+				Text:              string(text),
+				StructuredContent: val,
+			},
+		},
+	}, nil
 }
 
 func (r *Service) runSteps(ctx flowContext, steps []types.Step) (*mcp.CallToolResult, error) {
+	run := ctx.run
+	ctx.run = nil
+
 	for i, step := range steps {
+		id := step.ID
 		if step.ID == "" {
 			step.ID = uuid.String()
 		}
 
-		out, err := r.runStep(ctx, step)
+		out, completed, err := run.result(i, id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to run step %d (%s): %w", i, step.ID, err)
+			return nil, err
+		} else if completed {
+			log.Debugf(ctx.ctx, "skipping completed step %d (%s)", i, step.ID)
+		} else {
+			out, err = r.runStep(ctx, step)
+			if err != nil {
+				return nil, fmt.Errorf("failed to run step %d (%s): %w", i, step.ID, err)
+			}
+			run.complete(ctx, i, id, out)
 		}
 
 		if i == len(steps)-1 {
@@ -90,10 +340,23 @@ func (r *Service) runStepWhile(ctx flowContext, step types.Step) (ret *mcp.CallT
 		}
 	}()
 
-	step.Parallel = false
+	maxIterations := step.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+
+	step.Parallel.Enabled = false
 	return r.runStepEach(ctx, step, func(yield func(any) bool) {
 		i := 0
 		for {
+			if err := ctx.ctx.Err(); err != nil {
+				loopErr = err
+				return
+			}
+			if i >= maxIterations {
+				loopErr = fmt.Errorf("while loop of step %s exceeded the maximum of %d iterations", step.ID, maxIterations)
+				return
+			}
 			isTrue, err := expr.EvalBool(ctx.ctx, ctx.env, ctx.data, step.While)
 			if err != nil {
 				loopErr = err
@@ -110,64 +373,131 @@ func (r *Service) runStepWhile(ctx flowContext, step types.Step) (ret *mcp.CallT
 	})
 }
 
+// runStepEach runs the step once for every item. Sequential loops share the data of the flow so
+// a loop can see what the previous loop set, parallel loops each get a copy. The results are in
+// the order of the items regardless of when each loop completes.
 func (r *Service) runStepEach(ctx flowContext, step types.Step, forEachData iter.Seq[any]) (ret *mcp.CallToolResult, err error) {
 	var (
 		results     = make([]map[string]any, 0)
 		itemVarName = "item"
 		resultLock  sync.Mutex
 		eg          errgroup.Group
+		reduce      = step.Reduce
 	)
 
-	if step.Parallel {
-		eg.SetLimit(r.concurrency)
-	} else {
-		eg.SetLimit(1)
-	}
+	eg.SetLimit(max(r.concurrency, 1))
 
 	if step.ForEachVar != "" {
 		itemVarName = step.ForEachVar
 	}
 
-	oldVar, hadOldVar := ctx.data[itemVarName]
+	restore := saveVars(ctx.data, itemVarName, "index")
+	defer restore()
 	step.ForEach = nil
 	step.While = ""
+	step.Reduce = nil
 
-	for item := range forEachData {
-		newCtx := ctx
-		if step.Parallel {
-			newCtx.data = maps.Clone(ctx.data)
+	runLoop := func(loopCtx flowContext, index int) error {
+		result, err := r.runStep(loopCtx, step)
+		if err != nil {
+			return fmt.Errorf("failed to run forEach step %s (index %d): %w", step.ID, index, err)
 		}
-		ctx.data[itemVarName] = item
-		eg.Go(func() error {
-			result, err := r.runStep(newCtx, step)
-			if err != nil {
-				return fmt.Errorf("failed to run forEach step %s: %w", step.ID, err)
-			}
 
-			resultLock.Lock()
-			defer resultLock.Unlock()
-			results = append(results, toOutput(result))
-			return nil
-		})
+		resultLock.Lock()
+		defer resultLock.Unlock()
+		results[index] = toOutput(result)
+		return nil
+	}
+
+	for index, item := range enumerate(forEachData) {
+		loopCtx := ctx
+		if step.Parallel.Enabled {
+			loopCtx.data = maps.Clone(ctx.data)
+		}
+		loopCtx.data[itemVarName] = item
+		loopCtx.data["index"] = index
+
+		resultLock.Lock()
+		results = append(results, nil)
+		resultLock.Unlock()
+
+		if step.Parallel.Enabled {
+			eg.Go(func() error {
+				return runLoop(loopCtx, index)
+			})
+		} else if err := runLoop(loopCtx, index); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
 
-	if hadOldVar {
-		ctx.data[itemVarName] = oldVar
-	} else {
-		delete(ctx.data, itemVarName)
+	if reduce == nil {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				{
+					StructuredContent: results,
+				},
+			},
+		}, nil
 	}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			{
-				StructuredContent: results,
-			},
-		},
-	}, nil
+	// The loop variables are not in scope of reduce
+	restore()
+	defer saveVars(ctx.data, "results")()
+
+	outputs := make([]any, len(results))
+	for i, result := range results {
+		outputs[i] = result["output"]
+	}
+	ctx.data["results"] = outputs
+
+	if len(reduce.Steps) > 0 {
+		ret, err := r.runSteps(ctx, reduce.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reduce results of step %s: %w", step.ID, err)
+		}
+		return ret, nil
+	}
+
+	val, err := expr.EvalAny(ctx.ctx, ctx.env, ctx.data, reduce.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate reduce of step %s: %w", step.ID, err)
+	}
+	return valueResult(val)
+}
+
+func enumerate[T any](seq iter.Seq[T]) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for v := range seq {
+			if !yield(i, v) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// saveVars saves the values of the variables and returns a function that restores them.
+func saveVars(data map[string]any, names ...string) func() {
+	old := map[string]any{}
+	for _, name := range names {
+		if val, ok := data[name]; ok {
+			old[name] = val
+		}
+	}
+	return func() {
+		for _, name := range names {
+			if val, ok := old[name]; ok {
+				data[name] = val
+			} else {
+				delete(data, name)
+			}
+		}
+	}
 }
 
 func (r *Service) logFlowState(ctx flowContext) {
@@ -236,6 +566,19 @@ func (r *Service) runStep(ctx flowContext, step types.Step) (ret *mcp.CallToolRe
 	}
 
 	call := getCall(step)
+
+	var span *tracing.Span
+	ctx.ctx, span = tracing.Start(ctx.ctx, "flow step "+step.ID,
+		"nanobot.flow", fmt.Sprint(ctx.data["flow"]),
+		"nanobot.flow.run_id", fmt.Sprint(ctx.data["id"]),
+		"nanobot.flow.step", step.ID,
+		"nanobot.flow.step.call", call)
+	defer func() {
+		span.End(err)
+	}()
+
+	log.Debugf(ctx.ctx, "running step %s", step.ID)
+
 	if call != "" && len(step.Steps) > 0 {
 		return nil, fmt.Errorf("step %s cannot have both agent/tool/flow (%s) and steps defined (count: %d)",
 			step.ID, call, len(step.Steps))
@@ -262,7 +605,7 @@ func (r *Service) runStep(ctx flowContext, step types.Step) (ret *mcp.CallToolRe
 	}
 
 	if step.If != "" {
-		isTrue, err := expr.EvalBool(ctx.ctx, ctx.env, ctx.data, step.ForEach)
+		isTrue, err := expr.EvalBool(ctx.ctx, ctx.env, ctx.data, step.If)
 		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate if condition for step %s: %w", step.ID, err)
 		}
@@ -271,6 +614,148 @@ func (r *Service) runStep(ctx flowContext, step types.Step) (ret *mcp.CallToolRe
 		}
 	}
 
+	return r.runStepWithRetry(ctx, step, call)
+}
+
+// maxRetryBackoff is the longest the backoff between retries grows to, a longer initial backoff
+// is kept as is.
+const maxRetryBackoff = time.Minute
+
+func (r *Service) runStepWithRetry(ctx flowContext, step types.Step, call string) (*mcp.CallToolResult, error) {
+	var (
+		retry    types.Retry
+		backoff  time.Duration
+		oldError = ctx.data["error"]
+	)
+	if step.Retry != nil {
+		retry = *step.Retry
+	}
+	if retry.Backoff != "" {
+		d, err := time.ParseDuration(retry.Backoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry backoff for step %s: %w", step.ID, err)
+		}
+		backoff = d
+	}
+
+	for attempt := 1; ; attempt++ {
+		ret, err := r.runStepAttempt(ctx, step, call)
+		if err == nil && ret != nil && ret.IsError && (step.Retry != nil || step.OnError != nil) {
+			err = toolResultError(ret)
+		}
+		if err == nil {
+			restoreError(ctx, oldError)
+			return ret, nil
+		}
+
+		ctx.data["error"] = err.Error()
+		if attempt >= retry.Attempts {
+			return r.handleStepError(ctx, step, err, oldError)
+		}
+
+		if retry.RetryOn != "" {
+			retryOn, evalErr := expr.EvalBool(ctx.ctx, ctx.env, ctx.data, retry.RetryOn)
+			if evalErr != nil {
+				return nil, fmt.Errorf("failed to evaluate retryOn for step %s: %w", step.ID, evalErr)
+			}
+			if !retryOn {
+				return r.handleStepError(ctx, step, err, oldError)
+			}
+		}
+
+		log.Warnf(ctx.ctx, "step %s failed (attempt %d of %d), retrying in %s: %v", step.ID, attempt, retry.Attempts, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(err, ctx.ctx.Err())
+		case <-timer.C:
+		}
+		if backoff < maxRetryBackoff {
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+	}
+}
+
+// restoreError sets the error variable back to its value before the step ran, so only the step
+// and its onError steps see the error of the step.
+func restoreError(ctx flowContext, oldError any) {
+	if oldError == nil {
+		delete(ctx.data, "error")
+	} else {
+		ctx.data["error"] = oldError
+	}
+}
+
+func (r *Service) handleStepError(ctx flowContext, step types.Step, err error, oldError any) (*mcp.CallToolResult, error) {
+	if step.OnError == nil {
+		return nil, err
+	}
+	defer restoreError(ctx, oldError)
+
+	if len(step.OnError.Steps) > 0 {
+		log.Warnf(ctx.ctx, "step %s failed, running onError steps: %v", step.ID, err)
+		return r.runSteps(ctx, step.OnError.Steps)
+	}
+
+	if step.OnError.Action == types.OnErrorContinue {
+		log.Warnf(ctx.ctx, "step %s failed, continuing: %v", step.ID, err)
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	return nil, err
+}
+
+func toolResultError(ret *mcp.CallToolResult) error {
+	for _, content := range ret.Content {
+		if content.Text != "" {
+			return errors.New(content.Text)
+		}
+	}
+	return errors.New("tool call returned an error")
+}
+
+func (r *Service) runStepAttempt(ctx flowContext, step types.Step, call string) (*mcp.CallToolResult, error) {
+	if step.Timeout != "" {
+		timeout, err := time.ParseDuration(step.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for step %s: %w", step.ID, err)
+		}
+		var cancel context.CancelFunc
+		ctx.ctx, cancel = context.WithTimeout(ctx.ctx, timeout)
+		defer cancel()
+
+		ret, err := r.runStepCall(ctx, step, call)
+		if err != nil && errors.Is(ctx.ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("step %s timed out after %s: %w", step.ID, timeout, err)
+		}
+		return ret, err
+	}
+
+	return r.runStepCall(ctx, step, call)
+}
+
+func (r *Service) runStepCall(ctx flowContext, step types.Step, call string) (*mcp.CallToolResult, error) {
+	if step.Ask != nil {
+		return r.runStepAsk(ctx, step)
+	}
+
+	if step.Parallel.IsGroup() {
+		return r.runStepParallel(ctx, step)
+	}
+
+	if step.Script != "" {
+		return r.runStepScript(ctx, step)
+	}
+
 	inputData, err := expr.EvalObject(ctx.ctx, ctx.env, ctx.data, step.Input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate input for step %s: %w", step.ID, err)
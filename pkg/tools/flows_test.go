@@ -0,0 +1,339 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/checkpoint"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// failTwice fails on the first two runs and counts the runs in the input of the flow
+const failTwice = `input.n = (input.n || 0) + 1; if (input.n < 3) throw new Error("fail " + input.n); return "ok after " + input.n`
+
+func runFlow(t *testing.T, ctx context.Context, steps ...types.Step) (string, error) {
+	t.Helper()
+	s := NewToolsService(types.Config{
+		Flows: map[string]types.Flow{
+			"test": {Steps: steps},
+		},
+	})
+	ctx = mcp.WithSession(ctx, mcp.NewEmptySession(ctx, "test"))
+	ret, err := s.Call(ctx, "test", "", map[string]any{})
+	if err != nil {
+		return "", err
+	}
+	var texts []string
+	for _, content := range ret.Content {
+		texts = append(texts, content.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+func TestRetry(t *testing.T) {
+	out, err := runFlow(t, context.Background(), types.Step{
+		Retry:  &types.Retry{Attempts: 3},
+		Script: failTwice,
+	})
+	if err != nil || out != "ok after 3" {
+		t.Errorf("expected the third attempt to succeed, got %q, %v", out, err)
+	}
+
+	_, err = runFlow(t, context.Background(), types.Step{
+		Retry:  &types.Retry{Attempts: 2},
+		Script: failTwice,
+	})
+	if err == nil || !strings.Contains(err.Error(), "fail 2") {
+		t.Errorf("expected the error of the second attempt, got %v", err)
+	}
+
+	_, err = runFlow(t, context.Background(), types.Step{
+		Retry:  &types.Retry{Attempts: 3, RetryOn: `${error.includes("timeout")}`},
+		Script: failTwice,
+	})
+	if err == nil || !strings.Contains(err.Error(), "fail 1") {
+		t.Errorf("expected no retry when retryOn is false, got %v", err)
+	}
+}
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := runFlow(t, ctx, types.Step{
+		Retry:  &types.Retry{Attempts: 3, Backoff: "1h"},
+		Script: failTwice,
+	})
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected cancellation to stop the backoff, took %s", time.Since(start))
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := runFlow(t, context.Background(), types.Step{
+		Timeout: "100ms",
+		Script:  `while (true) {}`,
+	})
+	if err == nil {
+		t.Fatal("expected the step to time out")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected the step to be stopped after its timeout, took %s", time.Since(start))
+	}
+}
+
+func TestOnError(t *testing.T) {
+	fail := types.Step{Script: `throw new Error("boom")`}
+
+	withOnError := func(onError types.OnError) types.Step {
+		step := fail
+		step.OnError = &onError
+		return step
+	}
+
+	if _, err := runFlow(t, context.Background(), withOnError(types.OnError{Action: types.OnErrorFail}), types.Step{Script: `return "next"`}); err == nil ||
+		!strings.Contains(err.Error(), "boom") {
+		t.Errorf("fail: expected the flow to fail, got %v", err)
+	}
+
+	out, err := runFlow(t, context.Background(), withOnError(types.OnError{Action: types.OnErrorContinue}), types.Step{Script: `return "next after " + previous.output`})
+	if err != nil || !strings.HasPrefix(out, "next after ") || !strings.Contains(out, "boom") {
+		t.Errorf("continue: expected the next step to run with the error result, got %q, %v", out, err)
+	}
+
+	out, err = runFlow(t, context.Background(), withOnError(types.OnError{
+		Steps: []types.Step{{Script: `return "fallback for " + error`}},
+	}))
+	if err != nil || !strings.HasPrefix(out, "fallback for ") || !strings.Contains(out, "boom") {
+		t.Errorf("steps: expected the fallback steps to run, got %q, %v", out, err)
+	}
+
+	// the error of a handled step is only visible to the step and its onError steps
+	for _, onError := range []types.OnError{
+		{Action: types.OnErrorContinue},
+		{Steps: []types.Step{{Script: `return error`}}},
+	} {
+		out, err = runFlow(t, context.Background(), withOnError(onError), types.Step{Script: `return typeof error`})
+		if err != nil || out != "undefined" {
+			t.Errorf("expected the error to be restored after the step was handled, got %q, %v", out, err)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	store := checkpoint.NewStore(t.TempDir(), 0)
+	flow := types.Flow{
+		Steps: []types.Step{
+			{ID: "a", Script: `input.a = (input.a || 0) + 1; return "a"`},
+			// fails the first time it runs, the count is kept in the checkpointed input
+			{ID: "b", Script: `input.b = (input.b || 0) + 1; if (input.b < 2) throw new Error("fail b"); return "a ran " + input.a + " times"`},
+		},
+	}
+	s := NewToolsService(types.Config{Flows: map[string]types.Flow{"test": flow}}, RegistryOptions{Checkpoints: store})
+
+	session := mcp.NewEmptySession(context.Background(), "test")
+	session.EnvMap()["API_KEY"] = "s3cr3t-value"
+	ctx := mcp.WithSession(context.Background(), session)
+
+	if _, err := s.Call(ctx, "test", "", map[string]any{"key": "value"}); err == nil || !strings.Contains(err.Error(), "fail b") {
+		t.Fatalf("expected step b to fail, got %v", err)
+	}
+
+	runs, err := store.List()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one run, got %v, %v", runs, err)
+	}
+	run := runs[0]
+	if run.Status != checkpoint.StatusFailed || len(run.Steps) != 1 || run.Steps[0].ID != "a" {
+		t.Errorf("expected a failed run with step a completed, got %+v", run)
+	}
+	if run.Redacted {
+		t.Errorf("expected a run without sensitive values to not be redacted")
+	}
+
+	ret, err := s.ResumeFlow(ctx, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := ret.Content[0].Text; out != "a ran 1 times" {
+		t.Errorf("expected step a to be skipped, got %q", out)
+	}
+	if run, _ := store.Load(run.ID); run.Status != checkpoint.StatusSucceeded {
+		t.Errorf("expected the run to succeed, got %s", run.Status)
+	}
+
+	flow.Steps = append([]types.Step{{ID: "new", Script: `return "new"`}}, flow.Steps...)
+	changed := NewToolsService(types.Config{Flows: map[string]types.Flow{"test": flow}}, RegistryOptions{Checkpoints: store})
+	if _, err := changed.ResumeFlow(ctx, run.ID); err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Errorf("expected resuming a changed flow to fail, got %v", err)
+	}
+}
+
+func TestResumeRedacted(t *testing.T) {
+	store := checkpoint.NewStore(t.TempDir(), 0)
+	s := NewToolsService(types.Config{Flows: map[string]types.Flow{"test": {
+		Steps: []types.Step{
+			{ID: "auth", Set: map[string]any{"token": "Bearer ${API_KEY}"}},
+			{ID: "call", Script: `throw new Error("fail call")`},
+		},
+	}}}, RegistryOptions{Checkpoints: store})
+
+	session := mcp.NewEmptySession(context.Background(), "test")
+	session.EnvMap()["API_KEY"] = "s3cr3t-value"
+	session.Set(mcp.SessionSensitiveEnvKey, []string{"API_KEY"})
+	ctx := mcp.WithSession(context.Background(), session)
+
+	if _, err := s.Call(ctx, "test", "", map[string]any{"key": "s3cr3t-value"}); err == nil || !strings.Contains(err.Error(), "fail call") {
+		t.Fatalf("expected step call to fail, got %v", err)
+	}
+
+	runs, err := store.List()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one run, got %v, %v", runs, err)
+	}
+	run := runs[0]
+	if !run.Redacted {
+		t.Errorf("expected the run to be marked as redacted")
+	}
+	if key := run.Data["input"].(map[string]any)["key"]; key != "[REDACTED]" {
+		t.Errorf("expected the sensitive input to be redacted, got %q", key)
+	}
+	if token := run.Data["token"]; token != "Bearer [REDACTED]" {
+		t.Errorf("expected the sensitive env var to be redacted, got %q", token)
+	}
+
+	// the next steps would get [REDACTED] instead of the token
+	if _, err := s.ResumeFlow(ctx, run.ID); err == nil || !strings.Contains(err.Error(), "can not be resumed, values of sensitive env vars were redacted") {
+		t.Errorf("expected resuming a redacted run to fail, got %v", err)
+	}
+}
+
+func TestFlowInputAndOutput(t *testing.T) {
+	s := NewToolsService(types.Config{
+		Flows: map[string]types.Flow{
+			"test": {
+				Input: types.InputSchema{
+					Fields: map[string]types.Field{"name": {Description: "Who to greet"}},
+				},
+				Steps: []types.Step{
+					{ID: "greet", Script: `return "hello " + input.name`},
+				},
+				Output: map[string]any{
+					"greeting": "${greet.output}",
+					"name":     "${input.name}",
+				},
+			},
+		},
+	})
+	ctx := mcp.WithSession(context.Background(), mcp.NewEmptySession(context.Background(), "test"))
+
+	if _, err := s.Call(ctx, "test", "", map[string]any{}); err == nil || !strings.Contains(err.Error(), "invalid input for flow test") {
+		t.Errorf("expected the missing name to be rejected, got %v", err)
+	}
+
+	ret, err := s.Call(ctx, "test", "", map[string]any{"name": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, ok := ret.Content[0].StructuredContent.(map[string]any)
+	if !ok || output["greeting"] != "hello world" || output["name"] != "world" {
+		t.Errorf("expected the output expression to shape the result, got %#v", ret.Content[0])
+	}
+}
+
+func TestForEachOrder(t *testing.T) {
+	// later items finish first so the results are only ordered if they are collected by index
+	const sleep = `const end = Date.now() + (5 - item) * 50; while (Date.now() < end) {} return item * 10`
+	items := []any{1, 2, 3, 4}
+	tests := []struct {
+		name     string
+		step     types.Step
+		expected string
+	}{
+		{
+			name:     "sequential",
+			step:     types.Step{ID: "each", ForEach: items, Script: sleep},
+			expected: "10,20,30,40",
+		},
+		{
+			name:     "parallel",
+			step:     types.Step{ID: "each", ForEach: items, Parallel: types.Parallel{Enabled: true}, Script: sleep},
+			expected: "10,20,30,40",
+		},
+		{
+			name:     "index and custom variable",
+			step:     types.Step{ID: "each", ForEach: []any{"a", "b"}, ForEachVar: "letter", Parallel: types.Parallel{Enabled: true}, Script: `return index + letter`},
+			expected: "0a,1b",
+		},
+		{
+			name: "reduce expression",
+			step: types.Step{
+				ID:       "each",
+				ForEach:  items,
+				Parallel: types.Parallel{Enabled: true},
+				Script:   sleep,
+				Reduce:   &types.Reduce{Expression: `sum ${results.join("+")}`},
+			},
+			expected: "sum 10+20+30+40",
+		},
+		{
+			name: "reduce steps",
+			step: types.Step{
+				ID:       "each",
+				ForEach:  items,
+				Parallel: types.Parallel{Enabled: true},
+				Script:   sleep,
+				Reduce: &types.Reduce{Steps: []types.Step{
+					script("sum", `return typeof item + " " + results.reduce((a, b) => a + b, 0)`),
+				}},
+			},
+			expected: "undefined 100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []types.Step{tt.step}
+			if tt.step.Reduce == nil {
+				steps = append(steps, script("join", `return each.output.map(r => r.output).join(",")`))
+			}
+			s := NewToolsService(types.Config{
+				Flows: map[string]types.Flow{
+					"test": {Steps: steps},
+				},
+			}, RegistryOptions{Concurrency: 4})
+			ctx := mcp.WithSession(context.Background(), mcp.NewEmptySession(context.Background(), "test"))
+
+			start := time.Now()
+			ret, err := s.Call(ctx, "test", "", map[string]any{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := ret.Content[0].Text; out != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out)
+			}
+			if tt.step.Parallel.Enabled && tt.step.Script == sleep && time.Since(start) > 400*time.Millisecond {
+				t.Errorf("expected the items to run concurrently, took %s", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestForEachFailure(t *testing.T) {
+	_, err := runFlow(t, context.Background(), types.Step{
+		ID:       "each",
+		ForEach:  []any{1, 2, 3},
+		Parallel: types.Parallel{Enabled: true},
+		Script:   `if (item == 2) throw new Error("bad item"); return item`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "index 1") || !strings.Contains(err.Error(), "bad item") {
+		t.Errorf("expected the failed index in the error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync/atomic"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+type parallelSlotKey struct{}
+
+// parallelSlot is the slot of the parallel steps of the service that a branch of a group holds
+type parallelSlot struct {
+	held atomic.Bool
+}
+
+// releaseSlot gives up the slot of the branch ctx belongs to while it waits for a nested group,
+// so nested groups can't exhaust the slots and deadlock. The returned func takes the slot back.
+func (r *Service) releaseSlot(ctx context.Context) func() {
+	slot, _ := ctx.Value(parallelSlotKey{}).(*parallelSlot)
+	if slot == nil || !slot.held.CompareAndSwap(true, false) {
+		return func() {}
+	}
+	<-r.parallel
+	return func() {
+		r.parallel <- struct{}{}
+		slot.held.Store(true)
+	}
+}
+
+type branchResult struct {
+	id   string
+	data map[string]any
+	err  error
+}
+
+// runStepParallel runs the steps of a parallel group concurrently. The output of each step that
+// completes is stored under its ID and the output of the group is a map of those outputs by ID.
+// Everything else a step writes to the flow data, like the outputs of its nested steps or the
+// values it sets, is dropped because the branches run with their own copy of the data.
+//
+// All the groups of the service, including nested ones, share the same slots so --max-concurrency
+// limits the steps that run at once. A branch gives up its slot while it waits for a nested group.
+func (r *Service) runStepParallel(ctx flowContext, step types.Step) (*mcp.CallToolResult, error) {
+	join := step.Parallel.Join
+	if join == "" {
+		join = types.JoinAll
+	}
+
+	branchCtx, cancel := context.WithCancel(ctx.ctx)
+	defer cancel()
+
+	var (
+		branches = step.Parallel.Steps
+		results  = make(chan branchResult, len(branches))
+	)
+
+	for _, branch := range branches {
+		if branch.ID == "" {
+			branch.ID = uuid.String()
+		}
+
+		// Each branch gets its own copy of the data so branches don't see each other's intermediate values
+		slot := &parallelSlot{}
+		bCtx := ctx
+		bCtx.ctx = context.WithValue(branchCtx, parallelSlotKey{}, slot)
+		bCtx.data = maps.Clone(ctx.data)
+
+		go func() {
+			select {
+			case r.parallel <- struct{}{}:
+				slot.held.Store(true)
+				defer func() { <-r.parallel }()
+			case <-branchCtx.Done():
+				results <- branchResult{id: branch.ID, err: branchCtx.Err()}
+				return
+			}
+
+			_, err := r.runStep(bCtx, branch)
+			results <- branchResult{id: branch.ID, data: bCtx.data, err: err}
+		}()
+	}
+
+	defer r.releaseSlot(ctx.ctx)()
+
+	var (
+		outputs = map[string]any{}
+		errs    []error
+		// done is set once the result of the group is known, the remaining branches are canceled
+		done   bool
+		failed bool
+	)
+
+	for range branches {
+		res := <-results
+		if done {
+			continue
+		}
+
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("parallel step %s failed: %w", res.id, res.err))
+			if join == types.JoinRace || (join == types.JoinAll && step.Parallel.FailFast) {
+				done, failed = true, true
+				cancel()
+			}
+			continue
+		}
+
+		ctx.data[res.id] = res.data[res.id]
+		outputs[res.id] = res.data[res.id]
+		if join == types.JoinAny || join == types.JoinRace {
+			done = true
+			cancel()
+		}
+	}
+
+	switch {
+	case failed:
+		return nil, errors.Join(errs...)
+	case join == types.JoinAll && len(errs) > 0:
+		return nil, errors.Join(errs...)
+	case join == types.JoinAny && len(outputs) == 0:
+		return nil, fmt.Errorf("all parallel steps of step %s failed: %w", step.ID, errors.Join(errs...))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				StructuredContent: outputs,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const (
+	forever = `while (true) {}`
+	busy    = `const end = Date.now() + 100; while (Date.now() < end) {} return "done"`
+)
+
+func runGroup(t *testing.T, concurrency int, group types.Parallel) (*Service, map[string]any, error) {
+	t.Helper()
+	s := NewToolsService(types.Config{
+		Flows: map[string]types.Flow{
+			"test": {Steps: []types.Step{{ID: "group", Parallel: group}}},
+		},
+	}, RegistryOptions{Concurrency: concurrency})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ret, err := s.Call(mcp.WithSession(ctx, mcp.NewEmptySession(ctx, "test")), "test", "", map[string]any{})
+	if err != nil {
+		return s, nil, err
+	}
+	outputs := map[string]any{}
+	for id, output := range ret.Content[0].StructuredContent.(map[string]any) {
+		outputs[id] = output.(map[string]any)["output"]
+	}
+	return s, outputs, nil
+}
+
+func script(id, script string) types.Step {
+	return types.Step{ID: id, Script: script}
+}
+
+func TestParallelJoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		group    types.Parallel
+		expected map[string]any
+		err      string
+	}{
+		{
+			name:     "all",
+			group:    types.Parallel{Steps: []types.Step{script("a", `return "a"`), script("b", `return "b"`)}},
+			expected: map[string]any{"a": "a", "b": "b"},
+		},
+		{
+			name:  "all with a failure",
+			group: types.Parallel{Steps: []types.Step{script("a", `return "a"`), script("b", `throw new Error("b failed")`)}},
+			err:   "parallel step b failed",
+		},
+		{
+			name:  "all fails fast",
+			group: types.Parallel{FailFast: true, Steps: []types.Step{script("a", forever), script("b", `throw new Error("b failed")`)}},
+			err:   "b failed",
+		},
+		{
+			name:     "any",
+			group:    types.Parallel{Join: types.JoinAny, Steps: []types.Step{script("a", `throw new Error("a failed")`), script("b", busy), script("c", forever)}},
+			expected: map[string]any{"b": "done"},
+		},
+		{
+			name:  "any with every step failing",
+			group: types.Parallel{Join: types.JoinAny, Steps: []types.Step{script("a", `throw new Error("a failed")`), script("b", `throw new Error("b failed")`)}},
+			err:   "all parallel steps of step group failed",
+		},
+		{
+			name:     "race",
+			group:    types.Parallel{Join: types.JoinRace, Steps: []types.Step{script("a", `return "a"`), script("b", forever)}},
+			expected: map[string]any{"a": "a"},
+		},
+		{
+			name:  "race lost by a failure",
+			group: types.Parallel{Join: types.JoinRace, Steps: []types.Step{script("a", `throw new Error("a failed")`), script("b", forever)}},
+			err:   "a failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, outputs, err := runGroup(t, 10, tt.group)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outputs) != len(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, outputs)
+			}
+			for id, output := range tt.expected {
+				if outputs[id] != output {
+					t.Errorf("expected %v, got %v", tt.expected, outputs)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelNestedConcurrency(t *testing.T) {
+	nested := func(id string) types.Step {
+		return types.Step{ID: id, Parallel: types.Parallel{Steps: []types.Step{script(id+"1", busy), script(id+"2", busy)}}}
+	}
+
+	// The two groups nested in the group share the two slots, so the four steps run two at a time
+	start := time.Now()
+	s, outputs, err := runGroup(t, 2, types.Parallel{Steps: []types.Step{nested("a"), nested("b")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 {
+		t.Errorf("expected the outputs of both nested groups, got %v", outputs)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected at most two steps to run at once, took %s", elapsed)
+	}
+	if len(s.parallel) != 0 {
+		t.Errorf("expected every slot to be released, %d are held", len(s.parallel))
+	}
+
+	// A branch waiting for its nested group gives up its slot, so a single slot does not deadlock
+	if _, _, err := runGroup(t, 1, types.Parallel{Steps: []types.Step{nested("a"), nested("b")}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// runStepScript runs the script of the step with the flow data as global variables. The script
+// calls tools, agents and flows with call(target, args), which returns the same result a step
+// has, and its return value is the output of the step.
+func (r *Service) runStepScript(ctx flowContext, step types.Step) (*mcp.CallToolResult, error) {
+	val, err := expr.RunScript(ctx.ctx, ctx.env, ctx.data, step.Script, func(callCtx context.Context, target string, args any) (any, error) {
+		if args == nil {
+			args = map[string]any{}
+		}
+		ref := types.ParseToolRef(target)
+		ret, err := r.Call(callCtx, ref.Server, ref.Tool, args, CallOptions{
+			ProgressToken: ctx.opt.ProgressToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to call %s: %w", target, err)
+		}
+		return toOutput(ret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run script of step %s: %w", step.ID, err)
+	}
+
+	if val == nil {
+		return &mcp.CallToolResult{Content: make([]mcp.Content, 0)}, nil
+	}
+	ret, err := valueResult(val)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output of script of step %s: %w", step.ID, err)
+	}
+	return ret, nil
+}
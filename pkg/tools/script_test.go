@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestScriptStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []types.Step
+		expected string
+		err      string
+	}{
+		{
+			name:     "return value",
+			steps:    []types.Step{script("a", `return {n: 1, list: [1, 2]}`)},
+			expected: `{"list":[1,2],"n":1}`,
+		},
+		{
+			name:     "no return value",
+			steps:    []types.Step{script("a", `return "first"`), script("b", `const x = 1`)},
+			expected: "",
+		},
+		{
+			name: "outputs of earlier steps",
+			steps: []types.Step{
+				script("a", `return {n: 1}`),
+				script("b", `return "n is " + a.output.n + ", error " + a.isError`),
+			},
+			expected: "n is 1, error false",
+		},
+		{
+			name: "globals are written back",
+			steps: []types.Step{
+				script("a", `total = 5; input.extra = "x"`),
+				script("b", `return total + input.extra`),
+			},
+			expected: "5x",
+		},
+		{
+			name: "globals are not written back on failure",
+			steps: []types.Step{
+				{ID: "a", Script: `total = 5; throw new Error("boom")`, OnError: &types.OnError{Action: types.OnErrorContinue}},
+				script("b", `return typeof total`),
+			},
+			expected: "undefined",
+		},
+		{
+			name: "call a flow",
+			steps: []types.Step{
+				script("a", `const ret = call("double", {n: 21}); return ret.output.n + " " + ret.isError`),
+			},
+			expected: "42 false",
+		},
+		{
+			name: "call a flow that fails",
+			steps: []types.Step{
+				script("a", `call("double", {})`),
+			},
+			err: "failed to call double",
+		},
+		{
+			name: "catch a failed call",
+			steps: []types.Step{
+				script("a", `try { call("missing") } catch (e) { return "caught " + e.message }`),
+			},
+			expected: "caught failed to call missing: MCP server missing not found in config",
+		},
+		{
+			name: "env vars",
+			steps: []types.Step{
+				script("a", `return GREETING + " " + user_name`),
+			},
+			expected: "hello world",
+		},
+		{
+			name: "env vars are read-only",
+			steps: []types.Step{
+				script("a", `GREETING = "changed"; return GREETING`),
+				script("b", `return a.output + " " + Object.keys(globalThis).includes("GREETING")`),
+			},
+			expected: "hello false",
+		},
+		{
+			name: "data before env vars",
+			steps: []types.Step{
+				script("GREETING", `return "data"`),
+				script("b", `return GREETING.output`),
+			},
+			expected: "data",
+		},
+		{
+			name:  "error",
+			steps: []types.Step{script("a", `throw new Error("boom")`)},
+			err:   "failed to run script of step a",
+		},
+		{
+			name:  "syntax error",
+			steps: []types.Step{script("a", `return (`)},
+			err:   "failed to run script of step a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewToolsService(types.Config{
+				Flows: map[string]types.Flow{
+					"test": {Steps: tt.steps},
+					"double": {Steps: []types.Step{
+						script("double", `if (!input.n) throw new Error("n is required"); return {n: input.n * 2}`),
+					}},
+				},
+			})
+			session := mcp.NewEmptySession(context.Background(), "test")
+			session.EnvMap()["GREETING"] = "hello"
+			session.EnvMap()["USER-NAME"] = "world"
+			ctx := mcp.WithSession(context.Background(), session)
+
+			ret, err := s.Call(ctx, "test", "", map[string]any{})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, content := range ret.Content {
+				texts = append(texts, content.Text)
+			}
+			if out := strings.Join(texts, "\n"); out != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out)
+			}
+		})
+	}
+}
@@ -5,26 +5,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"reflect"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/checkpoint"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/confirm"
 	"github.com/nanobot-ai/nanobot/pkg/envvar"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
 	"github.com/nanobot-ai/nanobot/pkg/sampling"
+	"github.com/nanobot-ai/nanobot/pkg/tracing"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
 type Service struct {
-	servers     map[string]map[string]*mcp.Client
-	roots       []mcp.Root
-	config      types.Config
-	serverLock  sync.Mutex
+	servers    map[string]map[string]*mcp.Client
+	roots      []mcp.Root
+	config     types.Config
+	serverLock sync.Mutex
+	// samplerLock guards sampler, the MCP servers a reload keeps read it while it is replaced
+	samplerLock sync.RWMutex
 	sampler     Sampler
 	concurrency int
+	// parallel holds a slot for each step of a parallel group that is running
+	parallel    chan struct{}
+	checkpoints *checkpoint.Store
+	confirm     *confirm.Service
 }
 
 type Sampler interface {
@@ -34,11 +46,17 @@ type Sampler interface {
 type RegistryOptions struct {
 	Roots       []mcp.Root
 	Concurrency int
+	// Checkpoints stores the state of flow runs so they can be resumed, nil disables checkpoints
+	Checkpoints *checkpoint.Store
+	// Confirmations is used to ask for approvals in flows when the client does not support elicitation
+	Confirmations *confirm.Service
 }
 
 func (r RegistryOptions) Merge(other RegistryOptions) (result RegistryOptions) {
 	result.Roots = append(r.Roots, other.Roots...)
 	result.Concurrency = complete.Last(r.Concurrency, other.Concurrency)
+	result.Checkpoints = complete.Last(r.Checkpoints, other.Checkpoints)
+	result.Confirmations = complete.Last(r.Confirmations, other.Confirmations)
 	return result
 }
 
@@ -56,13 +74,70 @@ func NewToolsService(config types.Config, opts ...RegistryOptions) *Service {
 		config:      config,
 		roots:       opt.Roots,
 		concurrency: opt.Concurrency,
+		parallel:    make(chan struct{}, max(opt.Concurrency, 1)),
+		checkpoints: opt.Checkpoints,
+		confirm:     opt.Confirmations,
+	}
+}
+
+// TakeServers moves the running MCP servers of the old service, whose definition is the same in
+// the config of this service, to this service, and closes the others. It returns the names of the
+// MCP servers that were closed.
+func (r *Service) TakeServers(old *Service) (closed []string) {
+	old.serverLock.Lock()
+	defer old.serverLock.Unlock()
+	r.serverLock.Lock()
+	defer r.serverLock.Unlock()
+
+	for sessionID, servers := range old.servers {
+		kept := r.servers[sessionID]
+		for name, c := range servers {
+			newConfig, ok := r.config.MCPServers[name]
+			if ok && reflect.DeepEqual(old.config.MCPServers[name], newConfig) {
+				if kept == nil {
+					kept = map[string]*mcp.Client{}
+					r.servers[sessionID] = kept
+				}
+				kept[name] = c
+				continue
+			}
+			c.Session.Close()
+			if !slices.Contains(closed, name) {
+				closed = append(closed, name)
+			}
+		}
+	}
+	old.servers = map[string]map[string]*mcp.Client{}
+	// The kept MCP servers sample through the service that started them
+	old.SetSampler(r.getSampler())
+	slices.Sort(closed)
+	return closed
+}
+
+// CloseSession closes the MCP servers that were started for the session.
+func (r *Service) CloseSession(sessionID string) {
+	r.serverLock.Lock()
+	servers := r.servers[sessionID]
+	delete(r.servers, sessionID)
+	r.serverLock.Unlock()
+
+	for _, c := range servers {
+		c.Session.Close()
 	}
 }
 
 func (r *Service) SetSampler(sampler Sampler) {
+	r.samplerLock.Lock()
+	defer r.samplerLock.Unlock()
 	r.sampler = sampler
 }
 
+func (r *Service) getSampler() Sampler {
+	r.samplerLock.RLock()
+	defer r.samplerLock.RUnlock()
+	return r.sampler
+}
+
 func (r *Service) GetDynamicInstruction(ctx context.Context, instruction types.DynamicInstructions) (string, error) {
 	if !instruction.IsSet() {
 		return "", nil
@@ -161,9 +236,13 @@ func (r *Service) GetClient(ctx context.Context, name string) (*mcp.Client, erro
 			})
 		},
 	}
-	if r.sampler != nil {
+	if r.getSampler() != nil {
 		clientOpts.OnSampling = func(ctx context.Context, samplingRequest mcp.CreateMessageRequest) (mcp.CreateMessageResult, error) {
-			return r.sampler.Sample(ctx, samplingRequest, sampling.SamplerOptions{
+			// Sampling is traced as a child of the tool call it is made for, when it is known
+			if reqCtx, ok := mcp.SessionFromContext(ctx).RequestContext(); ok {
+				ctx = tracing.ContextWithSpan(ctx, tracing.SpanFromContext(reqCtx))
+			}
+			return r.getSampler().Sample(ctx, samplingRequest, sampling.SamplerOptions{
 				ProgressToken: uuid.String(),
 			})
 		}
@@ -187,7 +266,7 @@ func (r *Service) SampleCall(ctx context.Context, agent string, args any, opts .
 
 	opt := complete.Complete(opts...)
 
-	result, err := r.sampler.Sample(ctx, *createMessageRequest, sampling.SamplerOptions{
+	result, err := r.getSampler().Sample(ctx, *createMessageRequest, sampling.SamplerOptions{
 		ProgressToken: opt.ProgressToken,
 		AgentOverride: opt.AgentOverride,
 	})
@@ -238,6 +317,30 @@ func (r *Service) Call(ctx context.Context, server, tool string, args any, opts
 		target = server + "/" + tool
 	}
 
+	kind := "mcp"
+	if _, ok := r.config.Agents[server]; ok {
+		kind = "agent"
+	} else if _, ok := r.config.Flows[server]; ok {
+		kind = "flow"
+	}
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "call "+target,
+		"nanobot.call.target", target,
+		"nanobot.call.server", server,
+		"nanobot.call.tool", tool,
+		"nanobot.call.type", kind)
+	defer func() {
+		status := metrics.Status(err)
+		if err == nil && ret != nil && ret.IsError {
+			span.SetAttributes("nanobot.call.is_error", true)
+			status = "error"
+		}
+		span.End(err)
+		metrics.ToolCalls.Inc(server, tool, kind, status)
+		metrics.ToolCallDuration.Observe(time.Since(start).Seconds(), server, tool)
+	}()
+
 	if session != nil && opt.ProgressToken != nil {
 		callID := uuid.String()
 		_ = session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
@@ -278,14 +381,13 @@ func (r *Service) Call(ctx context.Context, server, tool string, args any, opts
 		}()
 	}
 
-	if _, ok := r.config.Agents[server]; ok {
+	switch kind {
+	case "agent":
 		return r.SampleCall(ctx, server, args, SampleCallOptions{
 			ProgressToken: opt.ProgressToken,
 			AgentOverride: opt.AgentOverride,
 		})
-	}
-
-	if _, ok := r.config.Flows[server]; ok {
+	case "flow":
 		return r.startFlow(ctx, server, args, opt)
 	}
 
@@ -525,6 +627,16 @@ func hasOnlySampleKeys(args map[string]any) bool {
 func (r *Service) convertToSampleRequest(agent string, args any) (*mcp.CreateMessageRequest, error) {
 	var sampleArgs types.SampleCallRequest
 	switch args := args.(type) {
+	case *mcp.CreateMessageRequest:
+		// A full conversation, the agent is always the one being called
+		sampleRequest := *args
+		sampleRequest.ModelPreferences.Hints = []mcp.ModelHint{
+			{Name: agent},
+		}
+		if sampleRequest.MaxTokens == 0 {
+			sampleRequest.MaxTokens = r.config.Agents[agent].MaxTokens
+		}
+		return &sampleRequest, nil
 	case string:
 		sampleArgs.Prompt = args
 	case map[string]any:
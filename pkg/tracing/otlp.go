@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+// The types below are the subset of the OTLP JSON encoding (ExportTraceServiceRequest) that
+// nanobot produces.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+func toValue(v any) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			s := fmt.Sprint(v)
+			return otlpValue{StringValue: &s}
+		}
+		return otlpValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func toRequest(serviceName string, spans []*Span) otlpRequest {
+	result := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.lock.Lock()
+		s := otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentSpanID,
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Status: otlpStatus{
+				Code: statusCodeOK,
+			},
+		}
+		for k, v := range span.attributes {
+			s.Attributes = append(s.Attributes, otlpKeyValue{
+				Key:   k,
+				Value: toValue(v),
+			})
+		}
+		if span.err != "" {
+			s.Status = otlpStatus{
+				Code:    statusCodeError,
+				Message: span.err,
+			}
+		}
+		span.lock.Unlock()
+		result = append(result, s)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpKeyValue{
+						{Key: "service.name", Value: toValue(serviceName)},
+						{Key: "service.version", Value: toValue(version.Get().String())},
+					},
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{
+							Name:    "github.com/nanobot-ai/nanobot",
+							Version: version.Get().String(),
+						},
+						Spans: result,
+					},
+				},
+			},
+		},
+	}
+}
+
+// OTLPExporter sends spans to an OTLP/HTTP collector using the JSON encoding.
+type OTLPExporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+}
+
+func NewOTLPExporter(endpoint string, headers map[string]string, serviceName string) *OTLPExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{
+		url:         url,
+		headers:     headers,
+		serviceName: serviceName,
+	}
+}
+
+func (o *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(toRequest(o.serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans to %s: %s: %s", o.url, resp.Status, body)
+	}
+	return nil
+}
+
+func (o *OTLPExporter) Close() error {
+	return nil
+}
+
+// FileExporter appends spans to a file, one OTLP JSON request per line. This is the format read
+// by the OpenTelemetry collector's otlpjsonfile receiver.
+type FileExporter struct {
+	serviceName string
+	lock        sync.Mutex
+	file        *os.File
+}
+
+func NewFileExporter(path, serviceName string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file %s: %w", path, err)
+	}
+	return &FileExporter{
+		serviceName: serviceName,
+		file:        f,
+	}, nil
+}
+
+func (f *FileExporter) Export(_ context.Context, spans []*Span) error {
+	data, err := json.Marshal(toRequest(f.serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	_, err = f.file.Write(append(data, '\n'))
+	return err
+}
+
+func (f *FileExporter) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Close()
+}
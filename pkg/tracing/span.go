@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type spanKey struct{}
+
+// Span is a single timed operation. A nil span is valid and all methods are no-ops, this is what
+// is returned when tracing is disabled.
+type Span struct {
+	tracer       *Tracer
+	traceID      string
+	spanID       string
+	parentSpanID string
+	name         string
+	start        time.Time
+	end          time.Time
+	attributes   map[string]any
+	err          string
+	ended        bool
+	lock         sync.Mutex
+}
+
+type remoteParent struct {
+	traceID string
+	spanID  string
+}
+
+// Start starts a new span as a child of the span in the context, if any. The keyValues are
+// pairs of attribute names and values.
+func Start(ctx context.Context, name string, keyValues ...any) (context.Context, *Span) {
+	t := global()
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		spanID:     newID(8),
+		name:       name,
+		start:      time.Now(),
+		attributes: map[string]any{},
+	}
+
+	switch parent := ctx.Value(spanKey{}).(type) {
+	case *Span:
+		span.traceID = parent.traceID
+		span.parentSpanID = parent.spanID
+	case remoteParent:
+		span.traceID = parent.traceID
+		span.parentSpanID = parent.spanID
+	default:
+		span.traceID = newID(16)
+	}
+
+	span.SetAttributes(keyValues...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan returns a context with the span as the parent of new spans. This is used to
+// continue a trace in a context that was not derived from the context of the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (s *Span) SetAttributes(keyValues ...any) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+		s.attributes[key] = keyValues[i+1]
+	}
+}
+
+// End ends the span, marking it as failed if err is not nil.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.lock.Unlock()
+
+	s.tracer.export(s)
+}
+
+// TraceParent returns the W3C traceparent value for the span in the context.
+func TraceParent(ctx context.Context) string {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", span.traceID, span.spanID)
+}
+
+// Inject sets the traceparent header for the span in the context.
+func Inject(ctx context.Context, header http.Header) {
+	if traceParent := TraceParent(ctx); traceParent != "" {
+		header.Set("traceparent", traceParent)
+	}
+}
+
+// WithTraceParent returns a context that will make new spans children of the remote span in the
+// W3C traceparent value. Invalid values are ignored.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, remoteParent{
+		traceID: parts[1],
+		spanID:  parts[2],
+	})
+}
+
+func newID(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic("failed to generate span ID: " + err.Error())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,205 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+var current atomic.Pointer[Tracer]
+
+func global() *Tracer {
+	return current.Load()
+}
+
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+	Close() error
+}
+
+type Options struct {
+	// Endpoint is the OTLP/HTTP endpoint, spans are posted to Endpoint + "/v1/traces"
+	Endpoint string
+	Headers  map[string]string
+	// File is a path to append spans to as lines of OTLP JSON
+	File          string
+	ServiceName   string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.Endpoint = complete.Last(o.Endpoint, other.Endpoint)
+	result.Headers = complete.MergeMap(o.Headers, other.Headers)
+	result.File = complete.Last(o.File, other.File)
+	result.ServiceName = complete.Last(o.ServiceName, other.ServiceName)
+	result.BatchSize = complete.Last(o.BatchSize, other.BatchSize)
+	result.FlushInterval = complete.Last(o.FlushInterval, other.FlushInterval)
+	return
+}
+
+func (o Options) Complete() Options {
+	if o.ServiceName == "" {
+		o.ServiceName = "nanobot"
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = 512
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	return o
+}
+
+// Tracer batches ended spans and sends them to the exporters in the background.
+type Tracer struct {
+	exporters     []Exporter
+	batchSize     int
+	flushInterval time.Duration
+	spans         chan *Span
+	flush         chan chan struct{}
+	// stop is closed by Close, spans ended after it are dropped. The spans channel is never closed
+	// because spans started before Close can still be ended.
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Setup installs a global tracer if an endpoint or file is configured. The returned function
+// flushes all pending spans and must be called before exiting.
+func Setup(opts ...Options) (func(ctx context.Context) error, error) {
+	opt := complete.Complete(opts...)
+
+	var exporters []Exporter
+	if opt.Endpoint != "" {
+		exporters = append(exporters, NewOTLPExporter(opt.Endpoint, opt.Headers, opt.ServiceName))
+	}
+	if opt.File != "" {
+		exporter, err := NewFileExporter(opt.File, opt.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	if len(exporters) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	t := &Tracer{
+		exporters:     exporters,
+		batchSize:     opt.BatchSize,
+		flushInterval: opt.FlushInterval,
+		spans:         make(chan *Span, opt.BatchSize*4),
+		flush:         make(chan chan struct{}),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go t.run()
+
+	current.Store(t)
+	return func(ctx context.Context) error {
+		current.CompareAndSwap(t, nil)
+		return t.Close(ctx)
+	}, nil
+}
+
+func (t *Tracer) export(span *Span) {
+	select {
+	case <-t.stop:
+		log.Debugf(context.Background(), "dropping span %s, tracer is closed", span.name)
+		return
+	default:
+	}
+	select {
+	case t.spans <- span:
+	default:
+		log.Debugf(context.Background(), "dropping span %s, export queue is full", span.name)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		for _, exporter := range t.exporters {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := exporter.Export(ctx, batch); err != nil {
+				log.Errorf(ctx, "failed to export %d spans: %v", len(batch), err)
+			}
+			cancel()
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-t.spans:
+			batch = append(batch, span)
+			if len(batch) >= t.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-t.flush:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			send()
+			close(done)
+		case <-t.stop:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			send()
+			return
+		}
+	}
+}
+
+// Flush blocks until all spans ended so far have been exported.
+func (t *Tracer) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracer) Close(ctx context.Context) error {
+	err := t.Flush(ctx)
+	t.closeOnce.Do(func() {
+		close(t.stop)
+	})
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	errs := []error{err}
+	for _, exporter := range t.exporters {
+		errs = append(errs, exporter.Close())
+	}
+	return errors.Join(errs...)
+}
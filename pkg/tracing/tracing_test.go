@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []otlpRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("X-Key") != "value" {
+			t.Errorf("unexpected request %s %s", req.URL.Path, req.Header)
+		}
+		var body otlpRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		lock.Lock()
+		requests = append(requests, body)
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	shutdown, err := Setup(Options{
+		Endpoint:    srv.URL,
+		Headers:     map[string]string{"X-Key": "value"},
+		ServiceName: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Start(context.Background(), "parent", "count", 3, "ok", true)
+	_, child := Start(ctx, "child", "name", "value")
+	child.End(errors.New("failed"))
+	parent.End(nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	resourceSpans := requests[0].ResourceSpans[0]
+	if name := resourceSpans.Resource.Attributes[0]; name.Key != "service.name" || *name.Value.StringValue != "test" {
+		t.Errorf("unexpected resource attributes %+v", resourceSpans.Resource.Attributes)
+	}
+
+	spans := resourceSpans.ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "parent" {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	childSpan, parentSpan := spans[0], spans[1]
+	if childSpan.TraceID != parentSpan.TraceID || childSpan.ParentSpanID != parentSpan.SpanID || parentSpan.ParentSpanID != "" {
+		t.Errorf("child %+v is not a child of %+v", childSpan, parentSpan)
+	}
+	if childSpan.Status.Code != statusCodeError || childSpan.Status.Message != "failed" || parentSpan.Status.Code != statusCodeOK {
+		t.Errorf("unexpected status %+v and %+v", childSpan.Status, parentSpan.Status)
+	}
+	for _, attr := range parentSpan.Attributes {
+		switch attr.Key {
+		case "count":
+			if attr.Value.IntValue == nil || *attr.Value.IntValue != "3" {
+				t.Errorf("unexpected count %+v", attr.Value)
+			}
+		case "ok":
+			if attr.Value.BoolValue == nil || !*attr.Value.BoolValue {
+				t.Errorf("unexpected ok %+v", attr.Value)
+			}
+		}
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	shutdown, err := Setup(Options{File: t.TempDir() + "/trace.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = shutdown(context.Background())
+	}()
+
+	ctx, span := Start(WithTraceParent(context.Background(), "00-"+traceID+"-"+spanID+"-01"), "remote child")
+	defer span.End(nil)
+	if span.traceID != traceID || span.parentSpanID != spanID {
+		t.Errorf("expected span to continue trace %s from %s, got %s from %s", traceID, spanID, span.traceID, span.parentSpanID)
+	}
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if want := "00-" + traceID + "-" + span.spanID + "-01"; header.Get("traceparent") != want {
+		t.Errorf("expected traceparent %s, got %s", want, header.Get("traceparent"))
+	}
+
+	for _, invalid := range []string{"", "garbage", "00-" + traceID + "-" + spanID, "00-" + strings.Repeat("x", 32) + "-" + spanID + "-01"} {
+		_, span := Start(WithTraceParent(context.Background(), invalid), "root")
+		if span.traceID == traceID || span.parentSpanID != "" {
+			t.Errorf("expected %q to be ignored", invalid)
+		}
+		span.End(nil)
+	}
+}
+
+func TestEndAfterClose(t *testing.T) {
+	shutdown, err := Setup(Options{File: t.TempDir() + "/trace.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Start(context.Background(), "late")
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Must not panic
+	span.End(nil)
+}
@@ -0,0 +1,80 @@
+package triggers
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// call runs the flow or agent of a trigger in a new session with the environment of the server.
+// The input of the trigger is evaluated with data.
+func call(ctx context.Context, r *runtime.Runtime, env map[string]string, recorder mcp.Recorder, name string, trigger types.Trigger, data map[string]any) (*mcp.CallToolResult, error) {
+	session, err := mcp.NewLocalSession(ctx, "trigger-"+name+"-"+uuid.String(), nil, recorder)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	defer r.Service().CloseSession(session.ID())
+
+	maps.Copy(session.EnvMap(), env)
+	if err := runtime.ReconcileEnv(session, r.GetConfig()); err != nil {
+		return nil, err
+	}
+	ctx = mcp.WithSession(ctx, session)
+
+	if trigger.Timeout != "" {
+		timeout, err := time.ParseDuration(trigger.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	input, err := expr.EvalAny(ctx, session.EnvMap(), data, trigger.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	target := trigger.Flow
+	if trigger.Agent != "" {
+		target = trigger.Agent
+		if prompt, ok := input.(string); ok {
+			input = types.SampleCallRequest{
+				Prompt: prompt,
+			}
+		}
+	}
+	if input == nil {
+		input = map[string]any{}
+	}
+
+	return r.Service().Call(ctx, target, "", input, tools.CallOptions{
+		ProgressToken: uuid.String(),
+		LogData: map[string]any{
+			"trigger": name,
+		},
+	})
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	var text []string
+	for _, content := range result.Content {
+		if content.Text != "" {
+			text = append(text, content.Text)
+		}
+	}
+	if len(text) == 0 {
+		return "the call returned an error result"
+	}
+	return strings.Join(text, "\n")
+}
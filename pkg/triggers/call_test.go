@@ -0,0 +1,88 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// newMCPServer starts an MCP server with a ping tool and returns its URL and the number of event
+// streams clients have open.
+func newMCPServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	handler := mcp.NewHTTPServer(nil, mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		switch msg.Method {
+		case "initialize":
+			var init mcp.InitializeRequest
+			if err := json.Unmarshal(msg.Params, &init); err != nil {
+				msg.SendError(ctx, err)
+				return
+			}
+			_ = msg.Reply(ctx, mcp.InitializeResult{ProtocolVersion: init.ProtocolVersion})
+		case "tools/list":
+			_ = msg.Reply(ctx, mcp.ListToolsResult{Tools: []mcp.Tool{{
+				Name:        "ping",
+				InputSchema: json.RawMessage(`{"type": "object"}`),
+			}}})
+		case "tools/call":
+			_ = msg.Reply(ctx, mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "pong"}}})
+		}
+	}))
+
+	streams := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			streams.Add(1)
+			defer streams.Add(-1)
+		}
+		handler.ServeHTTP(rw, req)
+	}))
+	t.Cleanup(func() {
+		// Close waits for the event streams of servers that were left running
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return srv.URL, streams
+}
+
+func TestCallClosesServers(t *testing.T) {
+	url, streams := newMCPServer(t)
+	r := runtime.NewRuntime(llm.Config{}, types.Config{
+		MCPServers: map[string]mcp.Server{
+			"srv": {BaseURL: url},
+		},
+		Flows: map[string]types.Flow{
+			"ping": {Steps: []types.Step{{Tool: "srv/ping"}}},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for range 3 {
+		result, err := call(ctx, r, nil, nil, "ping", types.Trigger{Flow: "ping"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError || resultText(result) != "pong" {
+			t.Fatalf("expected pong, got %v", resultText(result))
+		}
+	}
+
+	for streams.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected the MCP servers of the trigger runs to be closed, %d are still connected", streams.Load())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
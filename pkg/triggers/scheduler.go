@@ -0,0 +1,270 @@
+package triggers
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// maxMissed bounds how many missed runs are counted after a long downtime.
+const maxMissed = 1000
+
+type Options struct {
+	Recorder mcp.Recorder
+	// Store persists the schedule and last run of triggers. Without it missed runs are not detected
+	// after a restart.
+	Store *Store
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.Recorder = complete.Last(o.Recorder, other.Recorder)
+	result.Store = complete.Last(o.Store, other.Store)
+	return
+}
+
+// Scheduler runs the triggers of the config of a runtime.
+type Scheduler struct {
+	runtime *runtime.Runtime
+	env     map[string]string
+	opt     Options
+	wg      sync.WaitGroup
+}
+
+func NewScheduler(r *runtime.Runtime, env map[string]string, opts ...Options) *Scheduler {
+	return &Scheduler{
+		runtime: r,
+		env:     env,
+		opt:     complete.Complete(opts...),
+	}
+}
+
+// Run schedules the enabled triggers until ctx is canceled and then waits for running triggers
+// to stop. Webhook triggers are served by the WebhookServer instead.
+func (s *Scheduler) Run(ctx context.Context) {
+	triggers := s.runtime.GetConfig().Triggers
+	for _, name := range slices.Sorted(maps.Keys(triggers)) {
+		trigger := triggers[name]
+		if trigger.Webhook != nil {
+			continue
+		}
+		if trigger.Disabled {
+			log.Infof(ctx, "trigger %s is disabled", name)
+			continue
+		}
+		t := &scheduled{
+			Scheduler: s,
+			name:      name,
+			trigger:   trigger,
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			t.loop(log.WithFields(log.WithComponent(ctx, "trigger"), "trigger", name))
+		}()
+	}
+
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+type scheduled struct {
+	*Scheduler
+	name    string
+	trigger types.Trigger
+
+	lock    sync.Mutex
+	state   State
+	running int
+	queued  *time.Time
+}
+
+// spec identifies the schedule so a persisted next run is ignored when the schedule changed.
+func (t *scheduled) spec() string {
+	return strings.Join([]string{t.trigger.Schedule, t.trigger.Every, t.trigger.Timezone}, "|")
+}
+
+func (t *scheduled) loop(ctx context.Context) {
+	if t.opt.Store != nil {
+		state, err := t.opt.Store.Load(t.name)
+		if err != nil {
+			log.Errorf(ctx, "failed to load state of trigger %s: %v", t.name, err)
+		}
+		t.state = state
+	}
+	t.state.Name = t.name
+
+	now := time.Now()
+	next := time.Time{}
+	if t.state.Schedule == t.spec() && !t.state.NextRun.IsZero() {
+		if t.state.NextRun.After(now) {
+			next = t.state.NextRun
+		} else {
+			t.missed(ctx, t.state.NextRun, now)
+		}
+	}
+
+	for {
+		if next.IsZero() {
+			var err error
+			next, err = t.trigger.Next(time.Now())
+			if err != nil {
+				log.Errorf(ctx, "failed to schedule trigger %s: %v", t.name, err)
+				return
+			}
+		}
+
+		t.update(ctx, func(state *State) {
+			state.Schedule = t.spec()
+			state.NextRun = next
+		})
+		log.Debugf(ctx, "next run of trigger %s at %s", t.name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		t.fire(ctx, next)
+		next = time.Time{}
+	}
+}
+
+func (t *scheduled) missed(ctx context.Context, since, now time.Time) {
+	count := 0
+	for due := since; !due.After(now) && count < maxMissed; count++ {
+		next, err := t.trigger.Next(due)
+		if err != nil {
+			break
+		}
+		due = next
+	}
+
+	if t.trigger.Missed == types.MissedRun {
+		log.Infof(ctx, "trigger %s missed %d run(s) since %s, running it now", t.name, count, since.Format(time.RFC3339))
+		t.fire(ctx, since)
+		return
+	}
+	log.Warnf(ctx, "trigger %s missed %d run(s) since %s while nanobot was not running", t.name, count, since.Format(time.RFC3339))
+}
+
+// fire starts a run in the background according to the overlap policy of the trigger.
+func (t *scheduled) fire(ctx context.Context, scheduled time.Time) {
+	t.lock.Lock()
+	if t.running > 0 {
+		switch t.trigger.Overlap {
+		case types.OverlapAllow:
+		case types.OverlapQueue:
+			t.queued = &scheduled
+			t.lock.Unlock()
+			log.Infof(ctx, "trigger %s is still running, queued the run scheduled at %s", t.name, scheduled.Format(time.RFC3339))
+			return
+		default:
+			t.lock.Unlock()
+			metrics.TriggerRuns.Inc(t.name, StatusSkipped)
+			log.Warnf(ctx, "trigger %s is still running, skipped the run scheduled at %s", t.name, scheduled.Format(time.RFC3339))
+			return
+		}
+	}
+	t.running++
+	t.lock.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for {
+			t.execute(ctx, scheduled)
+
+			t.lock.Lock()
+			if t.queued == nil || ctx.Err() != nil {
+				t.running--
+				t.lock.Unlock()
+				return
+			}
+			scheduled = *t.queued
+			t.queued = nil
+			t.lock.Unlock()
+		}
+	}()
+}
+
+func (t *scheduled) execute(ctx context.Context, scheduled time.Time) {
+	start := time.Now()
+	log.Infof(ctx, "running trigger %s scheduled at %s", t.name, scheduled.Format(time.RFC3339))
+
+	result, err := t.call(ctx, scheduled, start)
+	if err == nil && result != nil && result.IsError {
+		err = errors.New(resultText(result))
+	}
+	duration := time.Since(start)
+
+	metrics.TriggerRuns.Inc(t.name, metrics.Status(err))
+	t.update(ctx, func(state *State) {
+		state.LastRun = start
+		state.LastDuration = duration
+		state.Runs++
+		state.LastStatus = StatusSucceeded
+		state.LastError = ""
+		if err != nil {
+			state.LastStatus = StatusFailed
+			state.LastError = err.Error()
+			state.Failures++
+		}
+	})
+
+	if err != nil {
+		log.Errorf(ctx, "trigger %s failed after %s: %v", t.name, duration.Round(time.Millisecond), err)
+	} else {
+		log.Infof(ctx, "trigger %s succeeded in %s", t.name, duration.Round(time.Millisecond))
+	}
+}
+
+func (t *scheduled) call(ctx context.Context, scheduled, start time.Time) (*mcp.CallToolResult, error) {
+	last := t.snapshot()
+	triggerData := map[string]any{
+		"name":       t.name,
+		"scheduled":  scheduled.Format(time.RFC3339),
+		"time":       start.Format(time.RFC3339),
+		"lastRun":    "",
+		"lastStatus": last.LastStatus,
+	}
+	if !last.LastRun.IsZero() {
+		triggerData["lastRun"] = last.LastRun.Format(time.RFC3339)
+	}
+
+	return call(ctx, t.runtime, t.env, t.opt.Recorder, t.name, t.trigger, map[string]any{
+		"trigger": triggerData,
+	})
+}
+
+func (t *scheduled) snapshot() State {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.state
+}
+
+func (t *scheduled) update(ctx context.Context, fn func(*State)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	fn(&t.state)
+	if t.opt.Store == nil {
+		return
+	}
+	if err := t.opt.Store.Save(t.state); err != nil {
+		log.Errorf(ctx, "failed to save state of trigger %s: %v", t.name, err)
+	}
+}
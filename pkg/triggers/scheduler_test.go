@@ -0,0 +1,145 @@
+package triggers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func newScheduler(t *testing.T, triggers map[string]types.Trigger) *Scheduler {
+	t.Helper()
+	r := runtime.NewRuntime(llm.Config{}, types.Config{
+		Flows: map[string]types.Flow{
+			"busy": {Steps: []types.Step{{
+				Script: `const end = Date.now() + 100; while (Date.now() < end) {} return "done"`,
+			}}},
+			"check": {Steps: []types.Step{{
+				Script: `if (input.name !== "nightly") throw new Error("unexpected input " + JSON.stringify(input)); return "ok"`,
+			}}},
+		},
+		Triggers: triggers,
+	})
+	return NewScheduler(r, map[string]string{}, Options{
+		Store: NewStore(t.TempDir()),
+	})
+}
+
+func TestExecute(t *testing.T) {
+	trigger := types.Trigger{
+		Every: "1h",
+		Flow:  "check",
+		Input: map[string]any{"name": "${trigger.name}"},
+	}
+	s := newScheduler(t, nil)
+	ctx := context.Background()
+
+	ok := &scheduled{Scheduler: s, name: "nightly", trigger: trigger, state: State{Name: "nightly"}}
+	ok.execute(ctx, time.Now())
+	if state := ok.snapshot(); state.Runs != 1 || state.Failures != 0 || state.LastStatus != StatusSucceeded || state.LastRun.IsZero() {
+		t.Errorf("expected a successful run, got %+v", state)
+	}
+
+	failed := &scheduled{Scheduler: s, name: "other", trigger: trigger, state: State{Name: "other"}}
+	failed.execute(ctx, time.Now())
+	state := failed.snapshot()
+	if state.Runs != 1 || state.Failures != 1 || state.LastStatus != StatusFailed || !strings.Contains(state.LastError, "unexpected input") {
+		t.Errorf("expected a failed run, got %+v", state)
+	}
+
+	stored, err := s.opt.Store.Load("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Runs != 1 || stored.Failures != 1 || stored.LastError != state.LastError {
+		t.Errorf("expected the state to be saved, got %+v", stored)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		overlap string
+		runs    int
+	}{
+		{overlap: "", runs: 1},
+		{overlap: types.OverlapSkip, runs: 1},
+		// the third run replaces the queued second run
+		{overlap: types.OverlapQueue, runs: 2},
+		{overlap: types.OverlapAllow, runs: 3},
+	}
+	for _, tt := range tests {
+		t.Run("overlap="+tt.overlap, func(t *testing.T) {
+			s := newScheduler(t, nil)
+			trigger := &scheduled{
+				Scheduler: s,
+				name:      "busy",
+				state:     State{Name: "busy"},
+				trigger: types.Trigger{
+					Every:   "1h",
+					Flow:    "busy",
+					Overlap: tt.overlap,
+				},
+			}
+
+			for range 3 {
+				trigger.fire(context.Background(), time.Now())
+			}
+			s.wg.Wait()
+
+			if state := trigger.snapshot(); state.Runs != tt.runs || state.Failures != 0 {
+				t.Errorf("expected %d successful runs, got %+v", tt.runs, state)
+			}
+		})
+	}
+}
+
+func TestMissed(t *testing.T) {
+	tests := []struct {
+		name     string
+		missed   string
+		schedule string
+		runs     int
+	}{
+		{name: "skip", missed: types.MissedSkip, schedule: "|1h|", runs: 0},
+		{name: "run", missed: types.MissedRun, schedule: "|1h|", runs: 1},
+		{name: "schedule changed", missed: types.MissedRun, schedule: "|2h|", runs: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler(t, map[string]types.Trigger{
+				"nightly": {
+					Every:  "1h",
+					Flow:   "check",
+					Input:  map[string]any{"name": "${trigger.name}"},
+					Missed: tt.missed,
+				},
+			})
+			if err := s.opt.Store.Save(State{
+				Name:     "nightly",
+				Schedule: tt.schedule,
+				NextRun:  time.Now().Add(-3 * time.Hour),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			s.Run(ctx)
+
+			state, err := s.opt.Store.Load("nightly")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.Runs != tt.runs || state.Failures != 0 {
+				t.Errorf("expected %d successful runs, got %+v", tt.runs, state)
+			}
+			if state.Schedule != "|1h|" || time.Until(state.NextRun) < 59*time.Minute {
+				t.Errorf("expected the next run to be scheduled an hour from now, got %+v", state)
+			}
+		})
+	}
+}
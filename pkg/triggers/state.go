@@ -0,0 +1,111 @@
+package triggers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// State is the persisted schedule and last run of a trigger.
+type State struct {
+	Name string `json:"name"`
+	// Schedule identifies the schedule NextRun was computed from
+	Schedule     string        `json:"schedule,omitempty"`
+	NextRun      time.Time     `json:"nextRun,omitzero"`
+	LastRun      time.Time     `json:"lastRun,omitzero"`
+	LastStatus   string        `json:"lastStatus,omitempty"`
+	LastError    string        `json:"lastError,omitempty"`
+	LastDuration time.Duration `json:"lastDuration,omitempty"`
+	Runs         int           `json:"runs,omitempty"`
+	Failures     int           `json:"failures,omitempty"`
+}
+
+// Store persists the state of triggers as one JSON file per trigger in a directory.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
+
+// DefaultDir returns the triggers directory in the nanobot user cache directory.
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "nanobot", "triggers"), nil
+}
+
+func (s *Store) file(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid trigger name %q", name)
+	}
+	return filepath.Join(s.dir, name+".json"), nil
+}
+
+// Load returns the state of the trigger, or an empty state if the trigger never ran.
+func (s *Store) Load(name string) (State, error) {
+	state := State{
+		Name: name,
+	}
+	file, err := s.file(name)
+	if err != nil {
+		return state, err
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return state, fmt.Errorf("failed to read state of trigger %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse state of trigger %s: %w", name, err)
+	}
+	return state, nil
+}
+
+func (s *Store) Save(state State) error {
+	file, err := s.file(state.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create trigger state directory %s: %w", s.dir, err)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state of trigger %s: %w", state.Name, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+state.Name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create trigger state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write trigger state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write trigger state file: %w", err)
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,54 @@
+package triggers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	state, err := s.Load("nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Name != "nightly" || state.Runs != 0 || !state.LastRun.IsZero() {
+		t.Errorf("expected an empty state for a trigger that never ran, got %+v", state)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	state = State{
+		Name:         "nightly",
+		Schedule:     "0 2 * * *||",
+		NextRun:      now.Add(time.Hour),
+		LastRun:      now,
+		LastStatus:   StatusFailed,
+		LastError:    "boom",
+		LastDuration: time.Second,
+		Runs:         3,
+		Failures:     1,
+	}
+	if err := s.Save(state); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.Load("nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.NextRun.Equal(state.NextRun) || !loaded.LastRun.Equal(state.LastRun) {
+		t.Errorf("expected the times to round trip, got %+v", loaded)
+	}
+	loaded.NextRun, loaded.LastRun = state.NextRun, state.LastRun
+	if loaded != state {
+		t.Errorf("expected %+v, got %+v", state, loaded)
+	}
+
+	for _, name := range []string{"", ".", "..", "../other", `a\b`} {
+		if _, err := s.Load(name); err == nil {
+			t.Errorf("expected an error loading invalid name %q", name)
+		}
+		if err := s.Save(State{Name: name}); err == nil {
+			t.Errorf("expected an error saving invalid name %q", name)
+		}
+	}
+}
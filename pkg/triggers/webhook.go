@@ -0,0 +1,284 @@
+package triggers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/metrics"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+const (
+	StatusRunning = "running"
+
+	maxWebhookBody = 10 << 20
+	// maxWebhookRuns bounds how many finished runs are kept for the status endpoint.
+	maxWebhookRuns = 1000
+)
+
+// WebhookRun is the status of a run started by a webhook.
+type WebhookRun struct {
+	ID       string    `json:"id"`
+	Trigger  string    `json:"trigger"`
+	Status   string    `json:"status"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+	Output   any       `json:"output,omitempty"`
+}
+
+// WebhookServer serves the webhook triggers of the config of a runtime under /webhooks/.
+type WebhookServer struct {
+	runtime *runtime.Runtime
+	env     map[string]string
+	opt     Options
+	mux     *http.ServeMux
+
+	lock  sync.Mutex
+	runs  map[string]*WebhookRun
+	order []string
+}
+
+func NewWebhookServer(r *runtime.Runtime, env map[string]string, opts ...Options) *WebhookServer {
+	s := &WebhookServer{
+		runtime: r,
+		env:     env,
+		opt:     complete.Complete(opts...),
+		mux:     http.NewServeMux(),
+		runs:    map[string]*WebhookRun{},
+	}
+	s.mux.HandleFunc("GET /webhooks/runs/{id}", s.status)
+	s.mux.HandleFunc("POST /webhooks/{path...}", s.trigger)
+	return s
+}
+
+func (s *WebhookServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(rw, req)
+}
+
+func writeJSON(rw http.ResponseWriter, code int, obj any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(obj)
+}
+
+func writeError(rw http.ResponseWriter, code int, err error) {
+	writeJSON(rw, code, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func (s *WebhookServer) status(rw http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	run, ok := s.runs[req.PathValue("id")]
+	var result WebhookRun
+	if ok {
+		result = *run
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("run %s not found", req.PathValue("id")))
+		return
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+// lookup returns the enabled webhook trigger for the path.
+func (s *WebhookServer) lookup(path string) (string, types.Trigger, bool) {
+	triggers := s.runtime.GetConfig().Triggers
+	for _, name := range slices.Sorted(maps.Keys(triggers)) {
+		trigger := triggers[name]
+		if trigger.Webhook != nil && !trigger.Disabled && trigger.WebhookPath(name) == path {
+			return name, trigger, true
+		}
+	}
+	return "", types.Trigger{}, false
+}
+
+func (s *WebhookServer) trigger(rw http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(req.PathValue("path"), "/")
+	name, trigger, ok := s.lookup(path)
+	if !ok {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("webhook %s not found", path))
+		return
+	}
+	ctx := log.WithFields(log.WithComponent(req.Context(), "trigger"), "trigger", name)
+
+	body, err := io.ReadAll(http.MaxBytesReader(rw, req.Body, maxWebhookBody))
+	if err != nil {
+		writeError(rw, http.StatusRequestEntityTooLarge, fmt.Errorf("failed to read request body: %w", err))
+		return
+	}
+
+	if err := s.verify(ctx, trigger.Webhook, req.Header, body); err != nil {
+		log.Warnf(ctx, "rejected webhook request for trigger %s: %v", name, err)
+		metrics.TriggerRuns.Inc(name, "unauthorized")
+		writeError(rw, http.StatusUnauthorized, err)
+		return
+	}
+
+	run := s.start(name)
+	data := map[string]any{
+		"trigger": map[string]any{
+			"name": name,
+			"time": run.Started.Format(time.RFC3339),
+			"run":  run.ID,
+		},
+		"request": requestData(req, body),
+	}
+
+	if trigger.Webhook.Mode == types.WebhookModeAsync {
+		go s.execute(context.WithoutCancel(ctx), run.ID, name, trigger, data)
+		writeJSON(rw, http.StatusAccepted, map[string]string{
+			"id":        run.ID,
+			"status":    StatusRunning,
+			"statusUrl": "/webhooks/runs/" + run.ID,
+		})
+		return
+	}
+
+	result := s.execute(ctx, run.ID, name, trigger, data)
+	code := http.StatusOK
+	if result.Status == StatusFailed {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(rw, code, result)
+}
+
+// verify checks the HMAC-SHA256 signature of the body if the webhook has a secret.
+func (s *WebhookServer) verify(ctx context.Context, webhook *types.Webhook, header http.Header, body []byte) error {
+	if webhook.Secret == "" {
+		return nil
+	}
+	secret, err := expr.EvalString(ctx, s.env, webhook.Secret)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New("webhook secret is empty")
+	}
+
+	headerName := webhook.SignatureHeader
+	if headerName == "" {
+		headerName = types.DefaultSignatureHeader
+	}
+	signature := strings.TrimPrefix(header.Get(headerName), "sha256=")
+	if signature == "" {
+		return fmt.Errorf("missing signature header %s", headerName)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature in header %s", headerName)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func requestData(req *http.Request, body []byte) map[string]any {
+	headers := map[string]any{}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ", ")
+	}
+	query := map[string]any{}
+	for k, v := range req.URL.Query() {
+		query[k] = v[0]
+	}
+
+	var parsed any = string(body)
+	if len(body) > 0 && json.Valid(body) {
+		_ = json.Unmarshal(body, &parsed)
+	}
+
+	return map[string]any{
+		"method":  req.Method,
+		"path":    req.URL.Path,
+		"headers": headers,
+		"query":   query,
+		"body":    parsed,
+	}
+}
+
+// start registers a new run, dropping the oldest finished runs once there are too many.
+func (s *WebhookServer) start(name string) WebhookRun {
+	run := &WebhookRun{
+		ID:      uuid.String(),
+		Trigger: name,
+		Status:  StatusRunning,
+		Started: time.Now(),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.runs[run.ID] = run
+	s.order = append(s.order, run.ID)
+	for i := 0; len(s.runs) > maxWebhookRuns && i < len(s.order); {
+		if old := s.runs[s.order[i]]; old.Status != StatusRunning {
+			delete(s.runs, s.order[i])
+			s.order = slices.Delete(s.order, i, i+1)
+			continue
+		}
+		i++
+	}
+	return *run
+}
+
+func (s *WebhookServer) execute(ctx context.Context, id, name string, trigger types.Trigger, data map[string]any) WebhookRun {
+	log.Infof(ctx, "running trigger %s for webhook run %s", name, id)
+
+	result, err := call(ctx, s.runtime, s.env, s.opt.Recorder, name, trigger, data)
+	var output any
+	if err == nil && result != nil && result.IsError {
+		err = errors.New(resultText(result))
+	} else if err == nil && result != nil && len(result.Content) > 0 {
+		text := resultText(result)
+		if json.Valid([]byte(text)) {
+			_ = json.Unmarshal([]byte(text), &output)
+		} else {
+			output = text
+		}
+	}
+	metrics.TriggerRuns.Inc(name, metrics.Status(err))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	run := s.runs[id]
+	if run == nil {
+		run = &WebhookRun{ID: id, Trigger: name}
+	}
+	run.Finished = time.Now()
+	run.Status = StatusSucceeded
+	run.Output = output
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		log.Errorf(ctx, "trigger %s failed for webhook run %s: %v", name, id, err)
+	} else {
+		log.Infof(ctx, "trigger %s succeeded for webhook run %s in %s", name, id, run.Finished.Sub(run.Started).Round(time.Millisecond))
+	}
+	return *run
+}
@@ -0,0 +1,229 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/llm"
+	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const webhookSecret = "s3cret"
+
+func newWebhookServer(t *testing.T) *WebhookServer {
+	t.Helper()
+	r := runtime.NewRuntime(llm.Config{}, types.Config{
+		Flows: map[string]types.Flow{
+			"echo": {Steps: []types.Step{{
+				Script: `if (!input.event) throw new Error("missing event"); return input.event`,
+			}}},
+		},
+		Triggers: map[string]types.Trigger{
+			"push": {
+				Webhook: &types.Webhook{Path: "/github/push/", Secret: "${HOOK_SECRET}"},
+				Flow:    "echo",
+				Input:   map[string]any{"event": "${request.body.event}"},
+			},
+			"async": {
+				Webhook: &types.Webhook{Mode: types.WebhookModeAsync},
+				Flow:    "echo",
+				Input:   map[string]any{"event": "${request.body.event}"},
+			},
+			"disabled": {
+				Webhook:  &types.Webhook{},
+				Flow:     "echo",
+				Disabled: true,
+			},
+		},
+	})
+	return NewWebhookServer(r, map[string]string{"HOOK_SECRET": webhookSecret})
+}
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(s *WebhookServer, path, body string, header map[string]string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, req)
+
+	result := map[string]any{}
+	_ = json.Unmarshal(rw.Body.Bytes(), &result)
+	return rw, result
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := `{"event":"opened"}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		header map[string]string
+		code   int
+		result string
+	}{
+		{
+			name:   "valid",
+			path:   "/webhooks/github/push",
+			body:   body,
+			header: map[string]string{types.DefaultSignatureHeader: sign(body)},
+			code:   http.StatusOK,
+			result: "opened",
+		},
+		{
+			name:   "valid without prefix",
+			path:   "/webhooks/github/push",
+			body:   body,
+			header: map[string]string{types.DefaultSignatureHeader: strings.TrimPrefix(sign(body), "sha256=")},
+			code:   http.StatusOK,
+			result: "opened",
+		},
+		{
+			name:   "invalid",
+			path:   "/webhooks/github/push",
+			body:   body,
+			header: map[string]string{types.DefaultSignatureHeader: sign(`{"event":"closed"}`)},
+			code:   http.StatusUnauthorized,
+			result: "invalid signature",
+		},
+		{
+			name:   "not hex",
+			path:   "/webhooks/github/push",
+			body:   body,
+			header: map[string]string{types.DefaultSignatureHeader: "sha256=xyz"},
+			code:   http.StatusUnauthorized,
+			result: "invalid signature in header",
+		},
+		{
+			name:   "missing header",
+			path:   "/webhooks/github/push",
+			body:   body,
+			code:   http.StatusUnauthorized,
+			result: "missing signature header " + types.DefaultSignatureHeader,
+		},
+		{
+			name:   "failed flow",
+			path:   "/webhooks/github/push",
+			body:   `{"event":""}`,
+			header: map[string]string{types.DefaultSignatureHeader: sign(`{"event":""}`)},
+			code:   http.StatusInternalServerError,
+			result: "missing event",
+		},
+		{
+			name:   "unknown path",
+			path:   "/webhooks/github",
+			body:   body,
+			code:   http.StatusNotFound,
+			result: "webhook github not found",
+		},
+		{
+			name:   "disabled",
+			path:   "/webhooks/disabled",
+			body:   body,
+			code:   http.StatusNotFound,
+			result: "webhook disabled not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, result := post(newWebhookServer(t), tt.path, tt.body, tt.header)
+			if rw.Code != tt.code {
+				t.Errorf("expected status %d, got %d: %s", tt.code, rw.Code, rw.Body.String())
+			}
+			got := fmt.Sprint(result["output"])
+			if tt.code != http.StatusOK {
+				got = fmt.Sprint(result["error"])
+			}
+			if !strings.Contains(got, tt.result) {
+				t.Errorf("expected %q in the response, got %s", tt.result, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookBodyLimit(t *testing.T) {
+	rw, result := post(newWebhookServer(t), "/webhooks/async", strings.Repeat("x", maxWebhookBody+1), nil)
+	if rw.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d: %v", http.StatusRequestEntityTooLarge, rw.Code, result)
+	}
+}
+
+func TestWebhookAsync(t *testing.T) {
+	s := newWebhookServer(t)
+	rw, result := post(s, "/webhooks/async", `{"event":"opened"}`, nil)
+	if rw.Code != http.StatusAccepted || result["status"] != StatusRunning {
+		t.Fatalf("expected the run to be accepted, got %d: %v", rw.Code, result)
+	}
+	if result["statusUrl"] != "/webhooks/runs/"+fmt.Sprint(result["id"]) {
+		t.Errorf("expected the status URL of run %v, got %v", result["id"], result["statusUrl"])
+	}
+
+	var run WebhookRun
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, fmt.Sprint(result["statusUrl"]), nil))
+		if rw.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rw.Code, rw.Body.String())
+		}
+		if err := json.Unmarshal(rw.Body.Bytes(), &run); err != nil {
+			t.Fatal(err)
+		}
+		if run.Status != StatusRunning {
+			break
+		}
+	}
+	if run.Status != StatusSucceeded || run.Output != "opened" || run.Trigger != "async" || run.Finished.IsZero() {
+		t.Errorf("expected the run to succeed, got %+v", run)
+	}
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/webhooks/runs/unknown", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for an unknown run, got %d", http.StatusNotFound, rw.Code)
+	}
+}
+
+func TestWebhookRunEviction(t *testing.T) {
+	s := newWebhookServer(t)
+	running := s.start("push")
+	first := s.start("push")
+	for id, run := range s.runs {
+		if id != running.ID {
+			run.Status = StatusSucceeded
+		}
+	}
+
+	var last WebhookRun
+	for range maxWebhookRuns - 1 {
+		last = s.start("push")
+		s.runs[last.ID].Status = StatusSucceeded
+	}
+
+	if len(s.runs) != maxWebhookRuns || len(s.order) != maxWebhookRuns {
+		t.Errorf("expected %d runs, got %d runs and %d ordered", maxWebhookRuns, len(s.runs), len(s.order))
+	}
+	if _, ok := s.runs[running.ID]; !ok {
+		t.Error("expected the running run to be kept")
+	}
+	if _, ok := s.runs[first.ID]; ok {
+		t.Error("expected the oldest finished run to be evicted")
+	}
+	if _, ok := s.runs[last.ID]; !ok {
+		t.Error("expected the newest run to be kept")
+	}
+}
@@ -0,0 +1,430 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+)
+
+// Diagnostic is a problem found by statically analyzing a flow. Diagnostics are warnings, the
+// flow may still run successfully.
+type Diagnostic struct {
+	Flow    string `json:"flow,omitempty"`
+	Step    string `json:"step,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	var buf strings.Builder
+	buf.WriteString("flow " + d.Flow)
+	if d.Step != "" {
+		buf.WriteString(" " + d.Step)
+	}
+	if d.Field != "" {
+		buf.WriteString(" " + d.Field)
+	}
+	return buf.String() + ": " + d.Message
+}
+
+type AnalyzeOptions struct {
+	// ToolSchema returns the input schema of a tool reference such as server/tool, if known.
+	// Tool schemas are only available from running MCP servers so tool inputs are not checked
+	// without it.
+	ToolSchema func(tool string) (json.RawMessage, bool)
+	// Env is the env the config runs with. Only the env vars set in it or declared in the config
+	// are known, other upper case references are reported like any unknown reference.
+	Env map[string]string
+}
+
+// Analyze checks the ${...} expressions of the steps of all flows for references to unknown
+// step IDs, variables and fields, and the input of steps against the schema of what they call.
+func (c Config) Analyze(opts AnalyzeOptions) (result []Diagnostic) {
+	env := make(map[string]string, len(c.Env)+len(opts.Env))
+	for key := range opts.Env {
+		env[key] = ""
+	}
+	for key := range c.Env {
+		env[key] = ""
+	}
+
+	for _, flowName := range slices.Sorted(maps.Keys(c.Flows)) {
+		a := flowAnalyzer{
+			config: c,
+			opts:   opts,
+			env:    env,
+			name:   flowName,
+			flow:   c.Flows[flowName],
+			byID:   map[string]Step{},
+			known: map[string]bool{
+				"id":       true,
+				"flow":     true,
+				"input":    true,
+				"previous": true,
+				"error":    true,
+			},
+		}
+		a.collect(a.flow.Steps)
+		a.steps(a.flow.Steps, "steps", nil, nil)
+		if a.flow.Output != nil {
+			a.value("", "output", a.flow.Output, nil, nil)
+		}
+		result = append(result, a.diagnostics...)
+	}
+	return result
+}
+
+type flowAnalyzer struct {
+	config      Config
+	opts        AnalyzeOptions
+	env         map[string]string
+	name        string
+	flow        Flow
+	known       map[string]bool
+	byID        map[string]Step
+	diagnostics []Diagnostic
+}
+
+// collect finds the step IDs and variables set anywhere in the flow. Steps can reference any of
+// them since the data of a flow run is shared by all steps.
+func (a *flowAnalyzer) collect(steps []Step) {
+	for _, step := range steps {
+		if step.ID != "" {
+			a.known[step.ID] = true
+			a.byID[step.ID] = step
+		}
+		for key := range step.Set {
+			a.known[key] = true
+		}
+		if step.Script != "" {
+			if _, assigned, err := expr.ScriptReferences(step.Script); err == nil {
+				for _, name := range assigned {
+					a.known[name] = true
+				}
+			}
+		}
+		a.collect(step.Steps)
+		a.collect(step.Parallel.Steps)
+		if step.OnError != nil {
+			a.collect(step.OnError.Steps)
+		}
+		if step.Reduce != nil {
+			a.collect(step.Reduce.Steps)
+		}
+	}
+}
+
+func (a *flowAnalyzer) warn(step, field, format string, args ...any) {
+	a.diagnostics = append(a.diagnostics, Diagnostic{
+		Flow:    a.name,
+		Step:    step,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (a *flowAnalyzer) steps(steps []Step, path string, vars map[string]bool, previous *Step) {
+	for i, step := range steps {
+		a.step(step, fmt.Sprintf("%s[%d]", path, i), vars, previous)
+		previous = &steps[i]
+	}
+}
+
+func (a *flowAnalyzer) step(step Step, path string, vars map[string]bool, previous *Step) {
+	label := path
+	if step.ID != "" {
+		label = fmt.Sprintf("%s (%s)", path, step.ID)
+	}
+
+	if step.ForEach != nil {
+		a.value(label, "forEach", step.ForEach, vars, previous)
+	}
+	a.value(label, "while", step.While, vars, previous)
+
+	if step.Reduce != nil {
+		reduceVars := maps.Clone(vars)
+		if reduceVars == nil {
+			reduceVars = map[string]bool{}
+		}
+		reduceVars["results"] = true
+		a.value(label, "reduce", step.Reduce.Expression, reduceVars, previous)
+		a.steps(step.Reduce.Steps, path+".reduce", reduceVars, nil)
+	}
+
+	if step.ForEach != nil || step.While != "" {
+		vars = maps.Clone(vars)
+		if vars == nil {
+			vars = map[string]bool{}
+		}
+		if step.ForEachVar != "" {
+			vars[step.ForEachVar] = true
+		} else {
+			vars["item"] = true
+		}
+		vars["index"] = true
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(step.Set)) {
+		a.value(label, "set."+key, step.Set[key], vars, previous)
+	}
+	a.value(label, "if", step.If, vars, previous)
+	a.value(label, "input", step.Input, vars, previous)
+	if step.Ask != nil {
+		a.value(label, "ask.message", step.Ask.Message, vars, previous)
+	}
+	if step.Retry != nil {
+		a.value(label, "retry.retryOn", step.Retry.RetryOn, vars, previous)
+	}
+	if step.Script != "" {
+		a.script(label, step.Script, vars, previous)
+	}
+	a.input(label, step)
+
+	// The previous result is not known statically for the first of nested steps
+	a.steps(step.Steps, path+".steps", vars, nil)
+	a.steps(step.Parallel.Steps, path+".parallel", vars, nil)
+	if step.OnError != nil {
+		a.steps(step.OnError.Steps, path+".onError", vars, nil)
+	}
+}
+
+// value checks all the expressions in a string or the strings nested in a list or object.
+func (a *flowAnalyzer) value(step, field string, value any, vars map[string]bool, previous *Step) {
+	switch v := value.(type) {
+	case string:
+		a.expressions(step, field, v, vars, previous)
+	case []any:
+		for i, item := range v {
+			a.value(step, fmt.Sprintf("%s[%d]", field, i), item, vars, previous)
+		}
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			a.value(step, field+"."+key, v[key], vars, previous)
+		}
+	}
+}
+
+func (a *flowAnalyzer) expressions(step, field, value string, vars map[string]bool, previous *Step) {
+	for _, e := range expr.Expressions(value) {
+		if a.isEnv(e) {
+			continue
+		}
+		refs, err := expr.References(e)
+		if err != nil {
+			a.warn(step, field, "invalid expression %q: %v", e, err)
+			continue
+		}
+		for _, ref := range refs {
+			a.reference(step, field, ref, vars, previous)
+		}
+	}
+}
+
+func (a *flowAnalyzer) script(step, src string, vars map[string]bool, previous *Step) {
+	refs, _, err := expr.ScriptReferences(src)
+	if err != nil {
+		a.warn(step, "script", "invalid script: %v", err)
+		return
+	}
+	for _, ref := range refs {
+		a.reference(step, "script", ref, vars, previous)
+	}
+}
+
+func (a *flowAnalyzer) isEnv(name string) bool {
+	_, ok := expr.Lookup(a.env, name)
+	return ok || secrets.IsName(name)
+}
+
+func (a *flowAnalyzer) reference(step, field string, ref expr.Reference, vars map[string]bool, previous *Step) {
+	switch {
+	case vars[ref.Name]:
+		return
+	case ref.Name == "input":
+		if len(ref.Path) > 0 {
+			a.checkField(step, field, ref, a.flow.Input.ToSchema(), ref.Path[0], "flow input")
+		}
+		return
+	case ref.Name == "previous":
+		if previous != nil {
+			a.stepOutput(step, field, ref, *previous)
+		}
+		return
+	case a.known[ref.Name]:
+		if target, ok := a.byID[ref.Name]; ok {
+			a.stepOutput(step, field, ref, target)
+		}
+		return
+	case a.isEnv(ref.Name), expr.Globals()[ref.Name]:
+		return
+	}
+
+	candidates := slices.Collect(maps.Keys(a.known))
+	for name := range vars {
+		candidates = append(candidates, name)
+	}
+	for name := range a.env {
+		candidates = append(candidates, name)
+	}
+	a.warn(step, field, "unknown reference %q%s", ref.Name, suggest(ref.Name, candidates))
+}
+
+// stepOutput checks a reference to the result of a step, which is {content, isError, output}.
+func (a *flowAnalyzer) stepOutput(step, field string, ref expr.Reference, target Step) {
+	if len(ref.Path) == 0 {
+		return
+	}
+	switch ref.Path[0] {
+	case "content", "isError":
+		return
+	case "output":
+	default:
+		a.warn(step, field, "%s has no field %q, a step result has content, isError and output%s",
+			ref.Name, ref.Path[0], suggest(ref.Path[0], []string{"content", "isError", "output"}))
+		return
+	}
+	if len(ref.Path) < 2 || target.ForEach != nil || target.While != "" {
+		return
+	}
+
+	var (
+		outputSchema json.RawMessage
+		what         = "output of " + ref.Name
+	)
+	switch {
+	case target.Parallel.IsGroup():
+		var ids []string
+		for _, branch := range target.Parallel.Steps {
+			ids = append(ids, branch.ID)
+		}
+		if !slices.Contains(ids, ref.Path[1]) {
+			a.warn(step, field, "%s has no field %q%s", what, ref.Path[1], suggest(ref.Path[1], ids))
+		}
+		return
+	case target.Ask != nil:
+		if !slices.Contains([]string{"action", "content"}, ref.Path[1]) {
+			a.warn(step, field, "%s has no field %q, an ask results in action and content", what, ref.Path[1])
+		}
+		return
+	case target.Agent.Output != nil:
+		outputSchema = target.Agent.Output.ToSchema()
+	case target.Agent.Name != "":
+		if agent, ok := a.config.Agents[target.Agent.Name]; ok && agent.Output != nil {
+			outputSchema = agent.Output.ToSchema()
+		}
+	}
+	a.checkField(step, field, ref, outputSchema, ref.Path[1], what)
+}
+
+func (a *flowAnalyzer) checkField(step, field string, ref expr.Reference, schema json.RawMessage, name, what string) {
+	properties, _, ok := schemaProperties(schema)
+	if !ok || slices.Contains(properties, name) {
+		return
+	}
+	a.warn(step, field, "%s has no field %q in %s%s", what, name, ref, suggest(name, properties))
+}
+
+// input checks the keys of an object input against the input schema of the agent, flow or tool
+// the step calls.
+func (a *flowAnalyzer) input(step string, s Step) {
+	var (
+		schema json.RawMessage
+		what   string
+	)
+	switch {
+	case s.Agent.Name != "":
+		schema, what = ChatInputSchema, "agent "+s.Agent.Name
+	case s.Flow != "":
+		if flow, ok := a.config.Flows[s.Flow]; ok {
+			schema, what = flow.Input.ToSchema(), "flow "+s.Flow
+		}
+	case s.Tool != "" && a.opts.ToolSchema != nil:
+		schema, _ = a.opts.ToolSchema(s.Tool)
+		what = "tool " + s.Tool
+	}
+
+	properties, required, ok := schemaProperties(schema)
+	if !ok {
+		return
+	}
+
+	input, isObject := s.Input.(map[string]any)
+	if !isObject {
+		// String inputs and expressions can only be checked at runtime, except agents which
+		// accept a plain string as the prompt
+		return
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(input)) {
+		if !slices.Contains(properties, key) {
+			a.warn(step, "input."+key, "%s does not accept input %q%s", what, key, suggest(key, properties))
+		}
+	}
+	for _, key := range required {
+		if _, ok := input[key]; !ok {
+			a.warn(step, "input", "%s requires input %q", what, key)
+		}
+	}
+}
+
+// schemaProperties returns the property names of an object schema. It returns false if the schema
+// does not declare properties, in which case any field is allowed.
+func schemaProperties(schema json.RawMessage) (properties, required []string, _ bool) {
+	if len(schema) == 0 {
+		return nil, nil, false
+	}
+	var parsed struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil || len(parsed.Properties) == 0 {
+		return nil, nil, false
+	}
+	properties = slices.Collect(maps.Keys(parsed.Properties))
+	sort.Strings(properties)
+	return properties, parsed.Required, true
+}
+
+// suggest returns a ", did you mean" hint for the closest candidate to name, if one is close.
+func suggest(name string, candidates []string) string {
+	var (
+		best     string
+		bestDist = min(len(name)/3+1, len(name)-1)
+	)
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); d <= bestDist && (best == "" || d < bestDist) {
+			best, bestDist = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,102 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	config := Config{
+		Env: map[string]EnvDef{"API_KEY": {}},
+		Flows: map[string]Flow{
+			"child": {
+				Input: InputSchema{Fields: map[string]Field{"topic": {}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		steps []Step
+		input InputSchema
+		opts  AnalyzeOptions
+		// warnings are the messages expected in order, none if empty
+		warnings []string
+	}{
+		{
+			name: "known references",
+			steps: []Step{
+				{ID: "first", Tool: "server/tool", Input: "topic ${input.topic} key ${API_KEY} ${api_key}"},
+				{Tool: "server/tool", Input: "output ${first.output} ${previous.content} ${HOME} ${secret:token}"},
+			},
+			input: InputSchema{Fields: map[string]Field{"topic": {}}},
+			opts:  AnalyzeOptions{Env: map[string]string{"HOME": "/root"}},
+		},
+		{
+			name:     "unknown step",
+			steps:    []Step{{ID: "first", Tool: "server/tool"}, {Tool: "server/tool", Input: "${frist.output}"}},
+			warnings: []string{`unknown reference "frist", did you mean "first"?`},
+		},
+		{
+			name:     "env typo",
+			steps:    []Step{{Tool: "server/tool", Input: "${API_KY}"}},
+			warnings: []string{`unknown reference "API_KY", did you mean "API_KEY"?`},
+		},
+		{
+			name:     "env not set",
+			steps:    []Step{{Tool: "server/tool", Input: "${HOME}"}},
+			warnings: []string{`unknown reference "HOME"`},
+		},
+		{
+			name:     "step result field",
+			steps:    []Step{{ID: "first", Tool: "server/tool"}, {Tool: "server/tool", Input: "${first.outptu}"}},
+			warnings: []string{`first has no field "outptu", a step result has content, isError and output, did you mean "output"?`},
+		},
+		{
+			name:     "flow input field",
+			steps:    []Step{{Tool: "server/tool", Input: "${input.topc}"}},
+			input:    InputSchema{Fields: map[string]Field{"topic": {}}},
+			warnings: []string{`flow input has no field "topc" in input.topc, did you mean "topic"?`},
+		},
+		{
+			name:  "flow step input",
+			steps: []Step{{Flow: "child", Input: map[string]any{"topc": "x"}}},
+			warnings: []string{
+				`flow child does not accept input "topc", did you mean "topic"?`,
+				`flow child requires input "topic"`,
+			},
+		},
+		{
+			name:  "tool step input",
+			steps: []Step{{Tool: "server/tool", Input: map[string]any{"query": "x", "limit": 1}}},
+			opts: AnalyzeOptions{ToolSchema: func(string) (json.RawMessage, bool) {
+				return json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}}}`), true
+			}},
+			warnings: []string{`tool server/tool does not accept input "limit"`},
+		},
+		{
+			name:  "loop variables",
+			steps: []Step{{ForEach: "${input.items}", Tool: "server/tool", Input: "item ${item} ${index}"}, {Tool: "server/tool", Input: "${item}"}},
+			warnings: []string{
+				`unknown reference "item"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config
+			c.Flows = map[string]Flow{"child": config.Flows["child"], "test": {Input: tt.input, Steps: tt.steps}}
+
+			var warnings []string
+			for _, d := range c.Analyze(tt.opts) {
+				if d.Flow == "test" {
+					warnings = append(warnings, d.Message)
+				}
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("expected warnings:\n%s\ngot:\n%s", strings.Join(tt.warnings, "\n"), strings.Join(warnings, "\n"))
+			}
+		})
+	}
+}
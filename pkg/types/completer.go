@@ -89,6 +89,12 @@ func (c *CompletionOutput) ToInput() CompletionInput {
 type CompletionResponse struct {
 	Output []CompletionOutput `json:"output,omitempty"`
 	Model  string             `json:"model,omitempty"`
+	Usage  *Usage             `json:"usage,omitempty"`
+}
+
+type Usage struct {
+	InputTokens  int `json:"inputTokens,omitempty"`
+	OutputTokens int `json:"outputTokens,omitempty"`
 }
 
 type ToolCallResult struct {
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/cron"
+	"github.com/nanobot-ai/nanobot/pkg/expr"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 )
 
@@ -17,42 +21,82 @@ type Config struct {
 	Agents     map[string]Agent      `json:"agents,omitempty"`
 	MCPServers map[string]mcp.Server `json:"mcpServers,omitempty"`
 	Flows      map[string]Flow       `json:"flows,omitempty"`
+	Triggers   map[string]Trigger    `json:"triggers,omitempty"`
 	Profiles   map[string]Config     `json:"profiles,omitempty"`
 }
 
+// PathError is an error about the value at Path in the config, such as ["agents", "main"]. Path is
+// relative to the PathError it is nested in, if any.
+type PathError struct {
+	Path []string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
 func (c Config) Validate(allowLocal bool) error {
 	var (
 		errs      []error
 		seenNames = map[string]string{}
 	)
 	if strings.HasPrefix(strings.TrimSpace(c.Extends), "/") {
-		errs = append(errs, fmt.Errorf("extends cannot be an absolute path: %s", c.Extends))
+		errs = append(errs, &PathError{
+			Path: []string{"extends"},
+			Err:  fmt.Errorf("extends cannot be an absolute path: %s", c.Extends),
+		})
 	}
 
 	for agentName, agent := range c.Agents {
+		path := []string{"agents", agentName}
 		if err := checkDup(seenNames, "agents", agentName); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &PathError{Path: path, Err: err})
 		}
 		if err := agent.validate(agentName, c); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &PathError{Path: path, Err: err})
 		}
 	}
 
 	for mcpServerName, mcpServer := range c.MCPServers {
+		path := []string{"mcpServers", mcpServerName}
 		if err := checkDup(seenNames, "mcpServers", mcpServerName); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &PathError{Path: path, Err: err})
 		}
 		if err := validateMCPServer(mcpServerName, mcpServer, allowLocal); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &PathError{Path: path, Err: err})
 		}
 	}
 
 	for flowName, flow := range c.Flows {
+		path := []string{"flows", flowName}
 		if err := checkDup(seenNames, "flows", flowName); err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &PathError{Path: path, Err: err})
 		}
 		if err := flow.validate(flowName, c); err != nil {
-			errs = append(errs, fmt.Errorf("error validating flow %q: %w", flowName, err))
+			errs = append(errs, &PathError{Path: path, Err: fmt.Errorf("error validating flow %q: %w", flowName, err)})
+		}
+	}
+
+	for envName, envDef := range c.Env {
+		if err := envDef.validate(); err != nil {
+			errs = append(errs, &PathError{
+				Path: []string{"env", envName},
+				Err:  fmt.Errorf("error validating env %q: %w", envName, err),
+			})
+		}
+	}
+
+	for triggerName, trigger := range c.Triggers {
+		if err := trigger.validate(c); err != nil {
+			errs = append(errs, &PathError{
+				Path: []string{"triggers", triggerName},
+				Err:  fmt.Errorf("error validating trigger %q: %w", triggerName, err),
+			})
 		}
 	}
 
@@ -83,6 +127,11 @@ type EnvDef struct {
 	Optional       bool       `json:"optional,omitempty"`
 	Sensitive      *bool      `json:"sensitive,omitempty"`
 	UseBearerToken bool       `json:"useBearerToken,omitempty"`
+	// Type is string, int, bool, url or enum, the default is string
+	Type    string `json:"type,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	// List allows a comma separated list of values, each of which is validated
+	List bool `json:"list,omitempty"`
 }
 
 func (e *EnvDef) UnmarshalJSON(data []byte) error {
@@ -102,14 +151,159 @@ type Flow struct {
 	Description string      `json:"description,omitempty"`
 	Input       InputSchema `json:"input,omitempty"`
 	OutputRole  string      `json:"outputRole,omitempty"`
-	Steps       []Step      `json:"steps,omitzero"`
+	// Output is an expression that shapes the result of the flow, by default the output of the last step is returned
+	Output any    `json:"output,omitempty"`
+	Steps  []Step `json:"steps,omitzero"`
 }
 
 func (f Flow) validate(flowName string, c Config) error {
 	var errs []error
 	for i, step := range f.Steps {
 		if err := step.validate(c); err != nil {
-			errs = append(errs, fmt.Errorf("error validating step %d in flow %q: %w", i, flowName, err))
+			errs = append(errs, stepError("steps", i, fmt.Errorf("error validating step %d in flow %q: %w", i, flowName, err)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+	OverlapAllow = "allow"
+
+	MissedSkip = "skip"
+	MissedRun  = "run"
+
+	WebhookModeSync  = "sync"
+	WebhookModeAsync = "async"
+
+	DefaultSignatureHeader = "X-Hub-Signature-256"
+)
+
+// Trigger runs a flow or agent on a cron schedule, at a fixed interval or when a webhook is called
+// while nanobot is serving.
+type Trigger struct {
+	Description string `json:"description,omitempty"`
+	// Schedule is a five field cron expression or a descriptor such as @daily
+	Schedule string `json:"schedule,omitempty"`
+	// Every is a fixed interval such as 15m, the first run is one interval after startup
+	Every   string   `json:"every,omitempty"`
+	Webhook *Webhook `json:"webhook,omitempty"`
+	// Timezone is the IANA time zone of the schedule, defaults to the local time zone
+	Timezone string `json:"timezone,omitempty"`
+	Flow     string `json:"flow,omitempty"`
+	Agent    string `json:"agent,omitempty"`
+	Input    any    `json:"input,omitempty"`
+	// Overlap is what happens when the previous run is still running: skip (default), queue or allow
+	Overlap string `json:"overlap,omitempty"`
+	// Missed is what happens to runs that were due while nanobot was not running: skip (default)
+	// or run, which runs once at startup
+	Missed   string `json:"missed,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// Webhook serves a trigger at POST /webhooks/{path} on the HTTP server.
+type Webhook struct {
+	// Path defaults to the name of the trigger
+	Path string `json:"path,omitempty"`
+	// Secret is the HMAC-SHA256 key requests must be signed with, usually a reference to an env
+	// variable such as ${GITHUB_WEBHOOK_SECRET}. Requests are not verified if it is not set.
+	Secret string `json:"secret,omitempty"`
+	// SignatureHeader is the header with the hex signature of the body, optionally prefixed with
+	// sha256=. Defaults to X-Hub-Signature-256.
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+	// Mode is sync (default) to respond with the result, or async to respond with 202 and the ID of
+	// the run right away
+	Mode string `json:"mode,omitempty"`
+}
+
+// WebhookPath returns the path of the webhook trigger under /webhooks/.
+func (t Trigger) WebhookPath(name string) string {
+	if t.Webhook == nil {
+		return ""
+	}
+	if path := strings.Trim(t.Webhook.Path, "/"); path != "" {
+		return path
+	}
+	return name
+}
+
+// Next returns the first time after t the trigger is due.
+func (t Trigger) Next(after time.Time) (time.Time, error) {
+	loc := time.Local
+	if t.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(t.Timezone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone %q: %w", t.Timezone, err)
+		}
+	}
+
+	if t.Every != "" {
+		every, err := time.ParseDuration(t.Every)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid interval %q: %w", t.Every, err)
+		}
+		if every < time.Second {
+			return time.Time{}, fmt.Errorf("invalid interval %q: must be at least 1s", t.Every)
+		}
+		return after.Add(every).In(loc), nil
+	}
+
+	schedule, err := cron.Parse(t.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(after.In(loc))
+	if next.IsZero() {
+		return next, fmt.Errorf("cron expression %q never matches", t.Schedule)
+	}
+	return next, nil
+}
+
+func (t Trigger) validate(c Config) error {
+	var (
+		errs  []error
+		kinds int
+	)
+	for _, set := range []bool{t.Schedule != "", t.Every != "", t.Webhook != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		errs = append(errs, fmt.Errorf("exactly one of schedule, every or webhook must be set"))
+	} else if t.Webhook != nil {
+		switch t.Webhook.Mode {
+		case "", WebhookModeSync, WebhookModeAsync:
+		default:
+			errs = append(errs, fmt.Errorf("invalid webhook mode %q, must be %s or %s", t.Webhook.Mode, WebhookModeSync, WebhookModeAsync))
+		}
+		if path := strings.Trim(t.Webhook.Path, "/"); path == "runs" || strings.HasPrefix(path, "runs/") {
+			errs = append(errs, fmt.Errorf("invalid webhook path %q, /webhooks/runs/ is reserved for the status of runs", t.Webhook.Path))
+		}
+	} else if _, err := t.Next(time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+	if (t.Flow == "") == (t.Agent == "") {
+		errs = append(errs, fmt.Errorf("exactly one of flow or agent must be set"))
+	}
+	_, _, refErrs := validateReferences(c, nil, ignoreEmptyStringList(t.Agent), ignoreEmptyStringList(t.Flow))
+	errs = append(errs, refErrs...)
+	switch t.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		errs = append(errs, fmt.Errorf("invalid overlap %q, must be %s, %s or %s", t.Overlap, OverlapSkip, OverlapQueue, OverlapAllow))
+	}
+	switch t.Missed {
+	case "", MissedSkip, MissedRun:
+	default:
+		errs = append(errs, fmt.Errorf("invalid missed %q, must be %s or %s", t.Missed, MissedSkip, MissedRun))
+	}
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("invalid timeout %q: %w", t.Timeout, err))
 		}
 	}
 	return errors.Join(errs...)
@@ -124,12 +318,160 @@ type Step struct {
 	While      string         `json:"while,omitempty"`
 	ForEach    any            `json:"forEach,omitempty"`
 	ForEachVar string         `json:"forEachVar,omitempty"`
+	Reduce     *Reduce        `json:"reduce,omitempty"`
 	Set        map[string]any `json:"set,omitempty"`
 	Input      any            `json:"input,omitempty"`
-	Parallel   bool
+	Parallel   Parallel       `json:"parallel,omitzero"`
+	// MaxIterations bounds the number of loops of while, defaults to 1000
+	MaxIterations int      `json:"maxIterations,omitempty"`
+	Retry         *Retry   `json:"retry,omitempty"`
+	Timeout       string   `json:"timeout,omitempty"`
+	OnError       *OnError `json:"onError,omitempty"`
+	Ask           *Ask     `json:"ask,omitempty"`
+	// Script is a block of javascript whose return value is the output of the step
+	Script string `json:"script,omitempty"`
+	Steps  []Step `json:"steps,omitzero"`
+}
+
+const (
+	AskDefaultAccept  = "accept"
+	AskDefaultDecline = "decline"
+)
+
+// Ask pauses the flow until the user answers. Without fields or a schema the user is asked to
+// approve the message.
+type Ask struct {
+	Message string           `json:"message,omitempty"`
+	Fields  map[string]Field `json:"fields,omitempty"`
+	Schema  json.RawMessage  `json:"schema,omitzero"`
+	// Timeout is how long to wait for an answer, defaults to 15m
+	Timeout string `json:"timeout,omitempty"`
+	// Default is the answer used when the user does not answer in time or can not be asked. If
+	// not set the step fails.
+	Default string `json:"default,omitempty"`
+}
+
+func (a *Ask) UnmarshalJSON(data []byte) error {
+	if data[0] == '"' && data[len(data)-1] == '"' {
+		return json.Unmarshal(data, &a.Message)
+	}
+	type Alias Ask
+	return json.Unmarshal(data, (*Alias)(a))
+}
+
+func (a Ask) IsApproval() bool {
+	return len(a.Fields) == 0 && len(a.Schema) == 0
+}
+
+func (a Ask) ToSchema() json.RawMessage {
+	if len(a.Schema) > 0 {
+		return a.Schema
+	}
+	data, _ := json.Marshal(BuildSimpleSchema("", "", a.Fields))
+	return data
+}
+
+const (
+	JoinAll  = "all"
+	JoinAny  = "any"
+	JoinRace = "race"
+)
+
+// Parallel is either a bool that runs the loops of forEach in parallel, a list of steps, or an
+// object with steps and join options. The steps of a group run concurrently.
+type Parallel struct {
+	Enabled bool `json:"-"`
+	// Join is all (default), any or race. All waits for every step, any for the first step that
+	// succeeds and race for the first step that completes.
+	Join string `json:"join,omitempty"`
+	// FailFast cancels the remaining steps as soon as a step fails when joining all
+	FailFast bool   `json:"failFast,omitempty"`
+	Steps    []Step `json:"steps,omitzero"`
+}
+
+func (p *Parallel) UnmarshalJSON(data []byte) error {
+	switch data[0] {
+	case 't', 'f':
+		return json.Unmarshal(data, &p.Enabled)
+	case '[':
+		return json.Unmarshal(data, &p.Steps)
+	}
+	type Alias Parallel
+	return json.Unmarshal(data, (*Alias)(p))
+}
+
+func (p Parallel) MarshalJSON() ([]byte, error) {
+	if len(p.Steps) == 0 {
+		return json.Marshal(p.Enabled)
+	}
+	type Alias Parallel
+	return json.Marshal(Alias(p))
+}
+
+func (p Parallel) IsGroup() bool {
+	return len(p.Steps) > 0
+}
+
+type Retry struct {
+	// Attempts is the total number of times the step is run, including the first
+	Attempts int `json:"attempts,omitempty"`
+	// Backoff is the delay before the first retry, doubled on each following retry up to a minute
+	Backoff string `json:"backoff,omitempty"`
+	// RetryOn is an expression evaluated with ${error} set, the step is only retried if it is true
+	RetryOn string `json:"retryOn,omitempty"`
+}
+
+const (
+	OnErrorFail     = "fail"
+	OnErrorContinue = "continue"
+)
+
+// OnError is either the string "fail" or "continue", or an object with fallback steps
+type OnError struct {
+	Action string `json:"action,omitempty"`
+	Steps  []Step `json:"steps,omitzero"`
+}
+
+func (o *OnError) UnmarshalJSON(data []byte) error {
+	if data[0] == '"' && data[len(data)-1] == '"' {
+		return json.Unmarshal(data, &o.Action)
+	}
+	type Alias OnError
+	return json.Unmarshal(data, (*Alias)(o))
+}
+
+func (o OnError) MarshalJSON() ([]byte, error) {
+	if len(o.Steps) == 0 {
+		return json.Marshal(o.Action)
+	}
+	type Alias OnError
+	return json.Marshal(Alias(o))
+}
+
+// Reduce folds the outputs of the loops of forEach or while into the output of the step. It is
+// either an expression or an object with steps, both can use results, the list of outputs in the
+// order of the items.
+type Reduce struct {
+	Expression string `json:"expression,omitempty"`
 	Steps      []Step `json:"steps,omitzero"`
 }
 
+func (r *Reduce) UnmarshalJSON(data []byte) error {
+	if data[0] == '"' && data[len(data)-1] == '"' {
+		return json.Unmarshal(data, &r.Expression)
+	}
+	type Alias Reduce
+	return json.Unmarshal(data, (*Alias)(r))
+}
+
+func (r Reduce) MarshalJSON() ([]byte, error) {
+	if len(r.Steps) == 0 {
+		return json.Marshal(r.Expression)
+	}
+	type Alias Reduce
+	return json.Marshal(Alias(r))
+}
+
 func ignoreEmptyStringList(s string) []string {
 	if s == "" {
 		return nil
@@ -137,11 +479,89 @@ func ignoreEmptyStringList(s string) []string {
 	return []string{s}
 }
 
+// stepError is the error of the i-th step of a list of nested steps under key.
+func stepError(key string, i int, err error) error {
+	return &PathError{Path: []string{key, strconv.Itoa(i)}, Err: err}
+}
+
 func (s Step) validate(c Config) error {
 	_, _, errs := validateReferences(c, ignoreEmptyStringList(s.Tool), ignoreEmptyStringList(s.Agent.Name), ignoreEmptyStringList(s.Flow))
 	for i, step := range s.Steps {
 		if err := step.validate(c); err != nil {
-			errs = append(errs, fmt.Errorf("error validating nested step %d: %w", i, err))
+			errs = append(errs, stepError("steps", i, fmt.Errorf("error validating nested step %d: %w", i, err)))
+		}
+	}
+	if s.Timeout != "" {
+		if _, err := time.ParseDuration(s.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("invalid timeout %q: %w", s.Timeout, err))
+		}
+	}
+	if s.Retry != nil && s.Retry.Backoff != "" {
+		if _, err := time.ParseDuration(s.Retry.Backoff); err != nil {
+			errs = append(errs, fmt.Errorf("invalid retry backoff %q: %w", s.Retry.Backoff, err))
+		}
+	}
+	if s.Parallel.IsGroup() {
+		if s.Tool != "" || s.Agent.Name != "" || s.Flow != "" || len(s.Steps) > 0 || s.Ask != nil {
+			errs = append(errs, fmt.Errorf("parallel steps can not be combined with a tool, agent, flow, ask or steps"))
+		}
+		switch s.Parallel.Join {
+		case "", JoinAll, JoinAny, JoinRace:
+		default:
+			errs = append(errs, fmt.Errorf("invalid parallel join %q, must be %s, %s or %s", s.Parallel.Join, JoinAll, JoinAny, JoinRace))
+		}
+		for i, step := range s.Parallel.Steps {
+			if err := step.validate(c); err != nil {
+				errs = append(errs, stepError("parallel", i, fmt.Errorf("error validating parallel step %d: %w", i, err)))
+			}
+		}
+	}
+	if s.Ask != nil {
+		if s.Tool != "" || s.Agent.Name != "" || s.Flow != "" || len(s.Steps) > 0 {
+			errs = append(errs, fmt.Errorf("ask can not be combined with a tool, agent, flow or steps"))
+		}
+		if s.Ask.Timeout != "" {
+			if _, err := time.ParseDuration(s.Ask.Timeout); err != nil {
+				errs = append(errs, fmt.Errorf("invalid ask timeout %q: %w", s.Ask.Timeout, err))
+			}
+		}
+		switch s.Ask.Default {
+		case "", AskDefaultAccept, AskDefaultDecline:
+		default:
+			errs = append(errs, fmt.Errorf("invalid ask default %q, must be %s or %s", s.Ask.Default, AskDefaultAccept, AskDefaultDecline))
+		}
+	}
+	if s.Script != "" {
+		if s.Tool != "" || s.Agent.Name != "" || s.Flow != "" || len(s.Steps) > 0 || s.Ask != nil || s.Parallel.IsGroup() || s.Input != nil {
+			errs = append(errs, fmt.Errorf("script can not be combined with a tool, agent, flow, ask, steps, parallel steps or input"))
+		}
+		if _, _, err := expr.ScriptReferences(s.Script); err != nil {
+			errs = append(errs, fmt.Errorf("invalid script: %w", err))
+		}
+	}
+	if s.Reduce != nil {
+		if s.ForEach == nil && s.While == "" {
+			errs = append(errs, fmt.Errorf("reduce requires forEach or while"))
+		}
+		if (s.Reduce.Expression == "") == (len(s.Reduce.Steps) == 0) {
+			errs = append(errs, fmt.Errorf("reduce must be an expression or a list of steps"))
+		}
+		for i, step := range s.Reduce.Steps {
+			if err := step.validate(c); err != nil {
+				errs = append(errs, stepError("reduce", i, fmt.Errorf("error validating reduce step %d: %w", i, err)))
+			}
+		}
+	}
+	if s.OnError != nil {
+		switch s.OnError.Action {
+		case "", OnErrorFail, OnErrorContinue:
+		default:
+			errs = append(errs, fmt.Errorf("invalid onError %q, must be %s, %s or a list of steps", s.OnError.Action, OnErrorFail, OnErrorContinue))
+		}
+		for i, step := range s.OnError.Steps {
+			if err := step.validate(c); err != nil {
+				errs = append(errs, stepError("onError", i, fmt.Errorf("error validating onError step %d: %w", i, err)))
+			}
 		}
 	}
 	return errors.Join(errs...)
@@ -0,0 +1,166 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+)
+
+const (
+	EnvTypeString = "string"
+	EnvTypeInt    = "int"
+	EnvTypeBool   = "bool"
+	EnvTypeURL    = "url"
+	EnvTypeEnum   = "enum"
+)
+
+// EnvProblem is an env variable that is missing or has an invalid value. They are sent to clients
+// in the missingEnv and invalidEnv data of the error initializing a session, so that clients can
+// ask the user for the values.
+type EnvProblem struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Default     string     `json:"default"`
+	Options     StringList `json:"options,omitempty"`
+	Type        string     `json:"type,omitempty"`
+	Pattern     string     `json:"pattern,omitempty"`
+	List        bool       `json:"list,omitempty"`
+	Sensitive   bool       `json:"sensitive,omitempty"`
+	// Error is why the value is invalid
+	Error string `json:"error,omitempty"`
+}
+
+func (e EnvDef) Problem(name string, err error) EnvProblem {
+	p := EnvProblem{
+		Name:        name,
+		Description: e.Description,
+		Default:     e.Default,
+		Options:     e.Options,
+		Type:        e.Type,
+		Pattern:     e.Pattern,
+		List:        e.List,
+		Sensitive:   e.Sensitive == nil || *e.Sensitive,
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+// Def returns the definition of the env variable, to check new values with.
+func (p EnvProblem) Def() EnvDef {
+	return EnvDef{
+		Description: p.Description,
+		Default:     p.Default,
+		Options:     p.Options,
+		Sensitive:   &p.Sensitive,
+		Type:        p.Type,
+		Pattern:     p.Pattern,
+		List:        p.List,
+	}
+}
+
+// Check returns an error if the value does not have the type of the env variable, is not one of
+// its options or does not match its pattern. Each value of a list is checked.
+func (e EnvDef) Check(value string) error {
+	if !e.List {
+		return e.checkValue(value)
+	}
+	for _, item := range strings.Split(value, ",") {
+		if err := e.checkValue(strings.TrimSpace(item)); err != nil {
+			return fmt.Errorf("invalid item %q: %w", strings.TrimSpace(item), err)
+		}
+	}
+	return nil
+}
+
+func (e EnvDef) checkValue(value string) error {
+	switch e.Type {
+	case EnvTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+	case EnvTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+	case EnvTypeURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be a URL such as https://example.com")
+		}
+	}
+
+	if len(e.Options) > 0 && !slices.Contains(e.Options, value) {
+		return fmt.Errorf("must be one of %s", strings.Join(e.Options, ", "))
+	}
+
+	if e.Pattern != "" {
+		re, err := compilePattern(e.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", e.Pattern, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %s", e.Pattern)
+		}
+	}
+	return nil
+}
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// patterns caches the compiled patterns of env definitions, which are checked on every session.
+var patterns sync.Map
+
+// compilePattern returns the compiled pattern, anchored to match the whole value.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(pattern); ok {
+		return cached.(compiledPattern).re, cached.(compiledPattern).err
+	}
+	// Compiled without the anchors first so errors point into the pattern
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		re, err = regexp.Compile("^(?:" + pattern + ")$")
+	}
+	patterns.Store(pattern, compiledPattern{re: re, err: err})
+	return re, err
+}
+
+func (e EnvDef) validate() error {
+	switch e.Type {
+	case "", EnvTypeString, EnvTypeInt, EnvTypeBool, EnvTypeURL:
+	case EnvTypeEnum:
+		if len(e.Options) == 0 {
+			return fmt.Errorf("env of type enum must have options")
+		}
+	default:
+		return fmt.Errorf("invalid type %q, must be one of string, int, bool, url or enum", e.Type)
+	}
+
+	if e.Pattern != "" {
+		if _, err := compilePattern(e.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", e.Pattern, err)
+		}
+	}
+
+	for _, option := range e.Options {
+		if err := e.checkValue(option); err != nil {
+			return fmt.Errorf("invalid option %q: %w", option, err)
+		}
+	}
+
+	if e.Default != "" && !secrets.IsReference(e.Default) {
+		if err := e.Check(e.Default); err != nil {
+			return fmt.Errorf("invalid default %q: %w", e.Default, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package types
+
+import "testing"
+
+func TestEnvDefCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		def   EnvDef
+		value string
+		valid bool
+	}{
+		{name: "string", def: EnvDef{}, value: "anything", valid: true},
+		{name: "int", def: EnvDef{Type: EnvTypeInt}, value: "8080", valid: true},
+		{name: "not int", def: EnvDef{Type: EnvTypeInt}, value: "80x"},
+		{name: "bool", def: EnvDef{Type: EnvTypeBool}, value: "false", valid: true},
+		{name: "not bool", def: EnvDef{Type: EnvTypeBool}, value: "maybe"},
+		{name: "url", def: EnvDef{Type: EnvTypeURL}, value: "https://example.com/api", valid: true},
+		{name: "not url", def: EnvDef{Type: EnvTypeURL}, value: "example.com"},
+		{name: "option", def: EnvDef{Type: EnvTypeEnum, Options: StringList{"fast", "slow"}}, value: "slow", valid: true},
+		{name: "not option", def: EnvDef{Options: StringList{"fast", "slow"}}, value: "medium"},
+		{name: "pattern", def: EnvDef{Pattern: "[a-z]+"}, value: "abc", valid: true},
+		{name: "pattern is anchored", def: EnvDef{Pattern: "[a-z]+"}, value: "abc1"},
+		{name: "list", def: EnvDef{Type: EnvTypeInt, List: true}, value: "1, 2,3", valid: true},
+		{name: "invalid item", def: EnvDef{Type: EnvTypeInt, List: true}, value: "1,x"},
+		{name: "list of options", def: EnvDef{Options: StringList{"a", "b"}, List: true}, value: "a,b", valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.def.Check(tt.value)
+			if tt.valid && err != nil {
+				t.Errorf("expected %q to be valid: %v", tt.value, err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expected %q to be invalid", tt.value)
+			}
+		})
+	}
+}
+
+func TestEnvDefValidate(t *testing.T) {
+	invalid := []EnvDef{
+		{Type: "float"},
+		{Type: EnvTypeEnum},
+		{Pattern: "[a-"},
+		{Type: EnvTypeInt, Default: "x"},
+		{Type: EnvTypeInt, Options: StringList{"1", "two"}},
+	}
+	for _, def := range invalid {
+		if err := def.validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", def)
+		}
+	}
+	if err := (EnvDef{Type: EnvTypeURL, List: true, Default: "http://a,http://b"}).validate(); err != nil {
+		t.Errorf("expected a list default to be valid: %v", err)
+	}
+}
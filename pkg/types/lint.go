@@ -0,0 +1,218 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Warning is a problem with a config that is valid but likely not what was intended, such as an
+// agent that nothing uses.
+type Warning struct {
+	// Path is the path of the value in the config, such as ["agents", "main", "toolChoice"]
+	Path    []string `json:"path,omitempty"`
+	Message string   `json:"message"`
+}
+
+// Lint checks for agents, flows, MCP servers and env variables that are not used, entrypoints
+// that can not be reached and tool choices of flow steps the agent does not have.
+func (c Config) Lint() (result []Warning) {
+	used := map[string]bool{}
+	c.references(used)
+	for _, profile := range c.Profiles {
+		profile.references(used)
+	}
+	scripts := c.scripts()
+
+	unused := func(section, kind string, names []string) {
+		for _, name := range slices.Sorted(slices.Values(names)) {
+			if used[name] || mentioned(name, scripts) {
+				continue
+			}
+			result = append(result, Warning{
+				Path:    []string{section, name},
+				Message: fmt.Sprintf("%s %q is not used by publish, an agent, a flow or a trigger", kind, name),
+			})
+		}
+	}
+	unused("agents", "agent", slices.Collect(maps.Keys(c.Agents)))
+	unused("flows", "flow", slices.Collect(maps.Keys(c.Flows)))
+	unused("mcpServers", "MCP server", slices.Collect(maps.Keys(c.MCPServers)))
+
+	result = append(result, c.lintEnv()...)
+	result = append(result, c.lintEntrypoint()...)
+
+	for _, flowName := range slices.Sorted(maps.Keys(c.Flows)) {
+		walkSteps(c.Flows[flowName].Steps, []string{"flows", flowName, "steps"}, func(step Step, path []string) {
+			if step.Agent.Name == "" || step.Agent.ToolChoice == "" {
+				return
+			}
+			if msg := c.checkToolChoice(step.Agent.Name, step.Agent.ToolChoice); msg != "" {
+				result = append(result, Warning{
+					Path:    append(path, "agent", "toolChoice"),
+					Message: msg,
+				})
+			}
+		})
+	}
+	return result
+}
+
+// references adds the names of the agents, flows and MCP servers the config refers to.
+func (c Config) references(used map[string]bool) {
+	add := func(refs ...string) {
+		for _, ref := range refs {
+			if ref != "" {
+				used[ParseToolRef(ref).Server] = true
+			}
+		}
+	}
+
+	add(c.Publish.Entrypoint)
+	add(c.Publish.Tools...)
+	add(c.Publish.Prompts...)
+	add(c.Publish.Resources...)
+	add(c.Publish.ResourceTemplates...)
+	add(c.Publish.MCPServers...)
+	add(c.Publish.Introduction.MCPServer)
+
+	for _, agent := range c.Agents {
+		add(agent.Tools...)
+		add(agent.Agents...)
+		add(agent.Flows...)
+		add(agent.Instructions.MCPServer)
+	}
+	for _, flow := range c.Flows {
+		walkSteps(flow.Steps, nil, func(step Step, _ []string) {
+			add(step.Tool, step.Agent.Name, step.Flow)
+		})
+	}
+	for _, trigger := range c.Triggers {
+		add(trigger.Flow, trigger.Agent)
+	}
+}
+
+// scripts returns the scripts of all steps, which can call any agent, flow or tool.
+func (c Config) scripts() (result []string) {
+	for _, flow := range c.Flows {
+		walkSteps(flow.Steps, nil, func(step Step, _ []string) {
+			if step.Script != "" {
+				result = append(result, step.Script)
+			}
+		})
+	}
+	return result
+}
+
+// mentioned reports if a script has the name in a string such as call('name/tool', ...).
+func mentioned(name string, scripts []string) bool {
+	for _, script := range scripts {
+		for _, quote := range []string{`'`, `"`, "`"} {
+			if strings.Contains(script, quote+name+quote) || strings.Contains(script, quote+name+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// walkSteps calls fn for the steps and all their nested steps with the path of the step.
+func walkSteps(steps []Step, path []string, fn func(step Step, path []string)) {
+	for i, step := range steps {
+		stepPath := append(slices.Clip(path), strconv.Itoa(i))
+		fn(step, stepPath)
+		walkSteps(step.Steps, append(slices.Clip(stepPath), "steps"), fn)
+		walkSteps(step.Parallel.Steps, append(slices.Clip(stepPath), "parallel"), fn)
+		if step.OnError != nil {
+			walkSteps(step.OnError.Steps, append(slices.Clip(stepPath), "onError"), fn)
+		}
+		if step.Reduce != nil {
+			walkSteps(step.Reduce.Steps, append(slices.Clip(stepPath), "reduce"), fn)
+		}
+	}
+}
+
+func (c Config) lintEnv() (result []Warning) {
+	if len(c.Env) == 0 {
+		return nil
+	}
+
+	withoutEnv := c
+	withoutEnv.Env = nil
+	data, err := json.Marshal(withoutEnv)
+	if err != nil {
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Env)) {
+		if c.Env[name].UseBearerToken {
+			// Set from the bearer token of requests, its use may be implicit
+			continue
+		}
+		word := regexp.MustCompile(`(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(name) + `([^A-Za-z0-9_]|$)`)
+		if !word.Match(data) {
+			result = append(result, Warning{
+				Path:    []string{"env", name},
+				Message: fmt.Sprintf("env %q is not used by any value in the config", name),
+			})
+		}
+	}
+	return result
+}
+
+func (c Config) lintEntrypoint() []Warning {
+	if c.Publish.Entrypoint == "" {
+		p := c.Publish
+		if len(p.Tools)+len(p.Prompts)+len(p.Resources)+len(p.ResourceTemplates)+len(p.MCPServers) == 0 && len(c.Triggers) == 0 {
+			return []Warning{{
+				Path:    []string{"publish"},
+				Message: "nothing is published, set publish.entrypoint or publish.tools to chat with or call the nanobot",
+			}}
+		}
+		return nil
+	}
+
+	ref := ParseToolRef(c.Publish.Entrypoint)
+	_, isAgent := c.Agents[ref.Server]
+	_, isFlow := c.Flows[ref.Server]
+	_, isServer := c.MCPServers[ref.Server]
+	switch {
+	case (isAgent || isFlow) && ref.Tool != "":
+		return []Warning{{
+			Path:    []string{"publish", "entrypoint"},
+			Message: fmt.Sprintf("entrypoint %q can not be reached, agents and flows have no tools, use %q", c.Publish.Entrypoint, ref.Server),
+		}}
+	case !isAgent && !isFlow && !isServer:
+		return []Warning{{
+			Path:    []string{"publish", "entrypoint"},
+			Message: fmt.Sprintf("entrypoint %q can not be reached, it is not an agent, flow or MCP server%s", c.Publish.Entrypoint, suggest(ref.Server, slices.Concat(slices.Collect(maps.Keys(c.Agents)), slices.Collect(maps.Keys(c.Flows))))),
+		}}
+	}
+	return nil
+}
+
+// checkToolChoice returns why the tool choice is not valid for the agent, if it is not.
+func (c Config) checkToolChoice(agentName, toolChoice string) string {
+	agent, ok := c.Agents[agentName]
+	if !ok {
+		return ""
+	}
+	switch toolChoice {
+	case "none", "auto":
+		return ""
+	}
+	unknownNames, names, _ := validateReferences(c, agent.Tools, agent.Agents, agent.Flows)
+	if unknownNames {
+		// The tools of whole MCP servers are only known once they run
+		return ""
+	}
+	if _, ok := names[toolChoice]; ok {
+		return ""
+	}
+	return fmt.Sprintf("tool choice %q is not a tool of agent %q, it must be none, auto or one of its tools%s",
+		toolChoice, agentName, suggest(toolChoice, slices.Collect(maps.Keys(names))))
+}
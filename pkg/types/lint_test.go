@@ -0,0 +1,104 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected []Warning
+	}{
+		{
+			name: "nothing published",
+			config: Config{
+				Agents: map[string]Agent{"main": {}},
+			},
+			expected: []Warning{
+				{Path: []string{"agents", "main"}, Message: `agent "main" is not used by publish, an agent, a flow or a trigger`},
+				{Path: []string{"publish"}, Message: "nothing is published, set publish.entrypoint or publish.tools to chat with or call the nanobot"},
+			},
+		},
+		{
+			name: "triggers are published",
+			config: Config{
+				Flows:    map[string]Flow{"nightly": {}},
+				Triggers: map[string]Trigger{"nightly": {Every: "1h", Flow: "nightly"}},
+			},
+		},
+		{
+			name: "used by agents, flows and scripts",
+			config: Config{
+				Publish: Publish{Entrypoint: "main"},
+				Agents: map[string]Agent{
+					"main":   {Agents: StringList{"helper"}, Flows: StringList{"run"}},
+					"helper": {Tools: StringList{"server/search"}},
+					"other":  {},
+				},
+				Flows: map[string]Flow{
+					"run": {Steps: []Step{{Script: `return call('other', {}) && call("tools/list")`}}},
+				},
+				MCPServers: map[string]mcp.Server{"server": {}, "tools": {}},
+			},
+		},
+		{
+			name: "env",
+			config: Config{
+				Publish: Publish{Entrypoint: "main"},
+				Agents:  map[string]Agent{"main": {Instructions: DynamicInstructions{Instructions: "key ${API_KEY}"}}},
+				Env: map[string]EnvDef{
+					"API_KEY":    {},
+					"API":        {},
+					"AUTH_TOKEN": {UseBearerToken: true},
+				},
+			},
+			expected: []Warning{
+				{Path: []string{"env", "API"}, Message: `env "API" is not used by any value in the config`},
+			},
+		},
+		{
+			name: "entrypoint with a tool of an agent",
+			config: Config{
+				Publish: Publish{Entrypoint: "main/chat"},
+				Agents:  map[string]Agent{"main": {}},
+			},
+			expected: []Warning{
+				{Path: []string{"publish", "entrypoint"}, Message: `entrypoint "main/chat" can not be reached, agents and flows have no tools, use "main"`},
+			},
+		},
+		{
+			name: "tool choice",
+			config: Config{
+				Publish: Publish{Entrypoint: "run"},
+				Agents: map[string]Agent{
+					"main": {Flows: StringList{"lookup"}},
+					"open": {Tools: StringList{"server"}},
+				},
+				Flows: map[string]Flow{
+					"lookup": {},
+					"run": {Steps: []Step{
+						{Agent: AgentCall{Name: "main", ToolChoice: "lookup"}},
+						{Agent: AgentCall{Name: "main", ToolChoice: "auto"}},
+						{Agent: AgentCall{Name: "open", ToolChoice: "anything"}},
+						{Steps: []Step{{Agent: AgentCall{Name: "main", ToolChoice: "lookpu"}}}},
+					}},
+				},
+				MCPServers: map[string]mcp.Server{"server": {}},
+			},
+			expected: []Warning{
+				{Path: []string{"flows", "run", "steps", "3", "steps", "0", "agent", "toolChoice"}, Message: `tool choice "lookpu" is not a tool of agent "main", it must be none, auto or one of its tools, did you mean "lookup"?`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if warnings := tt.config.Lint(); !reflect.DeepEqual(warnings, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, warnings)
+			}
+		})
+	}
+}